@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubelens/pkg/client"
+)
+
+func runGet(ctx context.Context, out io.Writer, args []string) error {
+	fs, g := newFlagSet("get", "get pods|workloads|nodes|events [flags]")
+	var opts client.ListOptions
+	fs.StringVar(&opts.Namespace, "n", "", "namespace, all namespaces if empty")
+	fs.StringVar(&opts.Namespace, "namespace", "", "namespace, all namespaces if empty")
+	fs.StringVar(&opts.LabelSelector, "l", "", "label selector")
+	fs.StringVar(&opts.LabelSelector, "selector", "", "label selector")
+	fs.StringVar(&opts.FieldSelector, "field-selector", "", "field selector")
+	fs.StringVar(&opts.Status, "status", "", "comma separated status values to keep")
+	fs.StringVar(&opts.Sort, "sort", "", "comma separated columns to sort by, prefix with - for descending")
+	fs.IntVar(&opts.Limit, "limit", 0, "maximum number of items")
+	fs.StringVar(&opts.Continue, "continue", "", "continue token of the previous page")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError(fs, "expected one resource type: %s", joinOr(resourceNames()))
+	}
+	resource, ok := resourceAliases[strings.ToLower(positional[0])]
+	if !ok {
+		return usageError(fs, "unknown resource type %q", positional[0])
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+
+	var (
+		result       interface{}
+		tbl          *table
+		continueFrom string
+	)
+	switch resource {
+	case "pods":
+		list, err := c.ListPods(ctx, &opts)
+		if err != nil {
+			return err
+		}
+		tbl = &table{header: []string{"NAMESPACE", "NAME", "READY", "STATUS", "RESTARTS", "AGE", "NODE"}}
+		for _, p := range list.Items {
+			tbl.add(p.Namespace, p.Name, p.Ready, p.Status, strconv.Itoa(int(p.Restarts)), p.Age, p.Node)
+		}
+		result, continueFrom = list, list.Continue
+	case "workloads":
+		list, err := c.ListWorkloads(ctx, &opts)
+		if err != nil {
+			return err
+		}
+		tbl = &table{header: []string{"NAMESPACE", "NAME", "KIND", "READY", "AGE"}}
+		for _, w := range list.Items {
+			tbl.add(w.Namespace, w.Name, w.Kind, w.Ready, w.Age)
+		}
+		result, continueFrom = list, list.Continue
+	case "nodes":
+		list, err := c.ListNodes(ctx, &opts)
+		if err != nil {
+			return err
+		}
+		tbl = &table{header: []string{"NAME", "STATUS", "ROLES", "AGE", "VERSION", "INTERNAL-IP"}}
+		for _, n := range list.Items {
+			tbl.add(n.Name, n.Status, n.Roles, n.Age, n.Version, n.InternalIP)
+		}
+		result, continueFrom = list, list.Continue
+	case "events":
+		list, err := c.ListEvents(ctx, &opts)
+		if err != nil {
+			return err
+		}
+		tbl = &table{header: []string{"NAMESPACE", "AGE", "TYPE", "REASON", "OBJECT", "MESSAGE"}}
+		for _, e := range list.Items {
+			tbl.add(e.Namespace, e.Age, e.Type, e.Reason, e.Object, e.Message)
+		}
+		result, continueFrom = list, list.Continue
+	}
+
+	if err := printResult(out, g.output, result, tbl); err != nil {
+		return err
+	}
+	if continueFrom != "" && (g.output == "" || g.output == "table") {
+		fmt.Fprintf(os.Stderr, "\nMore results available, use --continue %s\n", continueFrom)
+	}
+	return nil
+}
+
+func runLogs(ctx context.Context, out io.Writer, args []string) error {
+	fs, g := newFlagSet("logs", "logs POD [-n namespace] [-c container] [--tail N] [-f]")
+	var (
+		namespace string
+		opts      client.LogOptions
+		follow    bool
+	)
+	fs.StringVar(&namespace, "n", "default", "namespace")
+	fs.StringVar(&namespace, "namespace", "default", "namespace")
+	fs.StringVar(&opts.Container, "c", "", "container, defaults to the only container")
+	fs.StringVar(&opts.Container, "container", "", "container, defaults to the only container")
+	fs.Int64Var(&opts.TailLines, "tail", 100, "number of lines from the end")
+	fs.BoolVar(&follow, "f", false, "follow the logs")
+	fs.BoolVar(&follow, "follow", false, "follow the logs")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError(fs, "expected one pod name")
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+
+	if !follow {
+		logs, err := c.GetPodLogs(ctx, namespace, positional[0], &opts)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(out, logs)
+		return err
+	}
+
+	stream, err := c.StreamPodLogs(ctx, namespace, positional[0], &opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	if _, err := io.Copy(out, stream); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func runRestart(ctx context.Context, out io.Writer, args []string) error {
+	fs, g := newFlagSet("restart", "restart KIND/NAME [-n namespace]")
+	var namespace string
+	fs.StringVar(&namespace, "n", "default", "namespace")
+	fs.StringVar(&namespace, "namespace", "default", "namespace")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError(fs, "expected one KIND/NAME, e.g. deployment/web")
+	}
+	kindName, name, ok := strings.Cut(positional[0], "/")
+	kind := workloadKinds[strings.ToLower(kindName)]
+	if !ok || name == "" || kind == "" {
+		return usageError(fs, "invalid workload %q, expected deployment/NAME, statefulset/NAME or daemonset/NAME", positional[0])
+	}
+
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	resp, err := c.RestartWorkload(ctx, namespace, name, kind)
+	if err != nil {
+		return err
+	}
+
+	tbl := &table{header: []string{"MESSAGE"}}
+	tbl.add(resp.Message)
+	return printResult(out, g.output, resp, tbl)
+}
+
+func runSummary(ctx context.Context, out io.Writer, args []string) error {
+	fs, g := newFlagSet("summary", "summary")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	s, err := c.GetSummary(ctx)
+	if err != nil {
+		return err
+	}
+
+	tbl := &table{header: []string{"RESOURCE", "TOTAL", "HEALTHY"}}
+	tbl.add("pods", strconv.Itoa(s.TotalPods), strconv.Itoa(s.RunningPods)+" running")
+	tbl.add("nodes", strconv.Itoa(s.TotalNodes), strconv.Itoa(s.ReadyNodes)+" ready")
+	tbl.add("workloads", strconv.Itoa(s.TotalWorkloads), "")
+	tbl.add("services", strconv.Itoa(s.TotalServices), "")
+	return printResult(out, g.output, s, tbl)
+}
+
+func runNotifications(ctx context.Context, out io.Writer, args []string) error {
+	fs, g := newFlagSet("notifications", "notifications")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	list, err := c.ListNotifications(ctx)
+	if err != nil {
+		return err
+	}
+
+	tbl := &table{header: []string{"LEVEL", "NAMESPACE", "OBJECT", "AGE", "MESSAGE"}}
+	for _, n := range list.Items {
+		tbl.add(n.Level, n.Namespace, n.Object, since(n.CreatedAt), n.Message)
+	}
+	return printResult(out, g.output, list, tbl)
+}
+
+func runConfig(ctx context.Context, out io.Writer, args []string) error {
+	fs := newPlainFlagSet("config", "config view | config set [--server URL] [--token TOKEN]")
+	var server, token string
+	fs.StringVar(&server, "server", "", "KubeLens server URL")
+	fs.StringVar(&token, "token", "", "bearer token, - to remove it")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return usageError(fs, "expected view or set")
+	}
+	switch positional[0] {
+	case "view":
+		token := "<none>"
+		if cfg.Token != "" {
+			token = "<redacted>"
+		}
+		fmt.Fprintf(out, "file:   %s\nserver: %s\ntoken:  %s\n", path, cfg.Server, token)
+		return nil
+	case "set":
+		if server == "" && token == "" {
+			return usageError(fs, "nothing to set, pass --server and/or --token")
+		}
+		if server != "" {
+			if _, err := client.New(server); err != nil {
+				return err
+			}
+			cfg.Server = server
+		}
+		switch token {
+		case "":
+		case "-":
+			cfg.Token = ""
+		default:
+			cfg.Token = token
+		}
+		if err := saveConfig(path, cfg); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "saved %s\n", path)
+		return nil
+	}
+	return usageError(fs, "unknown config command %q", positional[0])
+}
+
+func runCompletion(ctx context.Context, out io.Writer, args []string) error {
+	fs := newPlainFlagSet("completion", "completion bash|zsh|fish")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError(fs, "expected a shell: bash, zsh or fish")
+	}
+	script, err := completionScript(positional[0])
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, script)
+	return err
+}
+
+// since formats the time elapsed since t like the server's age column
+func since(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := time.Since(t)
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"kubelens/internal/k8s"
+	"kubelens/pkg/client"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestServer serves the KubeLens API from a fake clientset holding objects
+func newTestServer(t *testing.T, objects ...runtime.Object) (*httptest.Server, *kubefake.Clientset) {
+	t.Helper()
+	c, err := k8s.NewFakeClient(objects...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	k8s.RegisterRoutes(r.Group(client.APIPrefix), k8s.NewHandler(c).Routes())
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	// Keep the user's config file out of the tests
+	t.Setenv("KUBELENS_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	return srv, c.Clientset.(*kubefake.Clientset)
+}
+
+func TestRunLogsContainer(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-1"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}}},
+	}
+	for _, follow := range []bool{false, true} {
+		srv, fake := newTestServer(t, pod)
+		args := []string{"logs", "web-1", "-n", "shop", "-c", "sidecar", "--tail", "5", "--server", srv.URL}
+		if follow {
+			args = append(args, "-f")
+		}
+		var out bytes.Buffer
+		if err := run(context.Background(), &out, args); err != nil {
+			t.Fatalf("follow=%v: %v", follow, err)
+		}
+		// The fake clientset serves a fixed body for every log request
+		if out.String() != "fake logs" {
+			t.Errorf("follow=%v: output %q", follow, out.String())
+		}
+
+		var options *corev1.PodLogOptions
+		for _, action := range fake.Actions() {
+			if action.GetSubresource() == "log" {
+				options = action.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+			}
+		}
+		if options == nil || options.Container != "sidecar" || *options.TailLines != 5 || options.Follow != follow {
+			t.Errorf("follow=%v: log options %+v", follow, options)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const bashCompletion = `# bash completion for kubelens, load with: source <(kubelens completion bash)
+_kubelens() {
+    local cur prev
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+        -o|--output)
+            COMPREPLY=($(compgen -W "table json yaml" -- "$cur"))
+            return ;;
+    esac
+
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "{{commands}}" -- "$cur"))
+        return
+    fi
+
+    case "${COMP_WORDS[1]}" in
+        get)        COMPREPLY=($(compgen -W "{{resources}}" -- "$cur")) ;;
+        restart)    COMPREPLY=($(compgen -W "deployment/ statefulset/ daemonset/" -- "$cur")); compopt -o nospace ;;
+        config)     COMPREPLY=($(compgen -W "view set" -- "$cur")) ;;
+        completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")) ;;
+    esac
+}
+complete -F _kubelens kubelens
+`
+
+const zshCompletion = `#compdef kubelens
+# zsh completion for kubelens, load with: source <(kubelens completion zsh)
+_kubelens() {
+    if (( CURRENT == 2 )); then
+        compadd {{commands}}
+        return
+    fi
+    case "${words[CURRENT-1]}" in
+        -o|--output) compadd table json yaml; return ;;
+    esac
+    case "${words[2]}" in
+        get)        compadd {{resources}} ;;
+        restart)    compadd -S '' deployment/ statefulset/ daemonset/ ;;
+        config)     compadd view set ;;
+        completion) compadd bash zsh fish ;;
+    esac
+}
+compdef _kubelens kubelens
+`
+
+const fishCompletion = `# fish completion for kubelens, load with: kubelens completion fish | source
+complete -c kubelens -f
+complete -c kubelens -n "__fish_use_subcommand" -a "{{commands}}"
+complete -c kubelens -n "__fish_seen_subcommand_from get" -a "{{resources}}"
+complete -c kubelens -n "__fish_seen_subcommand_from restart" -a "deployment/ statefulset/ daemonset/"
+complete -c kubelens -n "__fish_seen_subcommand_from config" -a "view set"
+complete -c kubelens -n "__fish_seen_subcommand_from completion" -a "bash zsh fish"
+complete -c kubelens -s o -l output -x -a "table json yaml"
+complete -c kubelens -l server -x
+complete -c kubelens -l token -x
+`
+
+// completionScript returns the completion script of a shell
+func completionScript(shell string) (string, error) {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return "", fmt.Errorf("unsupported shell %q, want bash, zsh or fish", shell)
+	}
+
+	var names []string
+	for _, cmd := range commands() {
+		names = append(names, cmd.name)
+	}
+	r := strings.NewReplacer(
+		"{{commands}}", strings.Join(names, " "),
+		"{{resources}}", strings.Join(resourceNames(), " "),
+	)
+	return r.Replace(script), nil
+}
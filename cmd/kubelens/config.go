@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the CLI configuration file, ~/.config/kubelens/config.yaml by default
+type Config struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+}
+
+const defaultServer = "http://localhost:8082"
+
+// configPath returns $KUBELENS_CONFIG or the default location
+func configPath() (string, error) {
+	if p := os.Getenv("KUBELENS_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kubelens", "config.yaml"), nil
+}
+
+// loadConfig reads the config file; a missing file yields the defaults
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(raw, cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	if cfg.Server == "" {
+		cfg.Server = defaultServer
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the config file readable by the owner only, as it may hold a token
+func saveConfig(path string, cfg *Config) error {
+	raw, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o600)
+}
@@ -0,0 +1,184 @@
+// Command kubelens is a command-line client for a KubeLens server. It offers the
+// same gated read and restart operations as the web UI to users without
+// kubeconfig access.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"kubelens/pkg/client"
+)
+
+// command is a kubelens subcommand
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, out io.Writer, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{"get", "get pods|workloads|nodes|events [flags]", "List resources", runGet},
+		{"logs", "logs POD [-n namespace] [-c container] [--tail N] [-f]", "Print or follow the logs of a pod", runLogs},
+		{"restart", "restart KIND/NAME [-n namespace]", "Rolling restart of a deployment, statefulset or daemonset", runRestart},
+		{"summary", "summary", "Show cluster summary counts", runSummary},
+		{"notifications", "notifications", "List notifications", runNotifications},
+		{"config", "config view | config set [--server URL] [--token TOKEN]", "Show or change the configuration file", runConfig},
+		{"completion", "completion bash|zsh|fish", "Print a shell completion script", runCompletion},
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Stdout, os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, out io.Writer, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(os.Stderr)
+		if len(args) == 0 {
+			return flag.ErrHelp
+		}
+		return nil
+	}
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(ctx, out, args[1:])
+		}
+	}
+	printUsage(os.Stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "kubelens talks to a KubeLens server.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  kubelens %-57s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Every command accepts --server, --token and -o table|json|yaml. They default to")
+	fmt.Fprintln(w, "$KUBELENS_SERVER, $KUBELENS_TOKEN and the config file ($KUBELENS_CONFIG or")
+	fmt.Fprintln(w, "~/.config/kubelens/config.yaml).")
+}
+
+// globalFlags are accepted by every command
+type globalFlags struct {
+	server string
+	token  string
+	output string
+}
+
+// newPlainFlagSet returns a flag set for commands that do not call the server
+func newPlainFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubelens %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// newFlagSet returns a flag set with the global flags registered
+func newFlagSet(name, usage string) (*flag.FlagSet, *globalFlags) {
+	fs := newPlainFlagSet(name, usage)
+	g := &globalFlags{}
+	fs.StringVar(&g.server, "server", "", "KubeLens server URL")
+	fs.StringVar(&g.token, "token", "", "bearer token")
+	fs.StringVar(&g.output, "o", "table", "output format: table, json or yaml")
+	fs.StringVar(&g.output, "output", "table", "output format: table, json or yaml")
+	return fs, g
+}
+
+// parseArgs parses flags that may appear before, between or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// client builds an API client from the flags, the environment and the config file, in that order
+func (g *globalFlags) client() (*client.Client, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	server := firstNonEmpty(g.server, os.Getenv("KUBELENS_SERVER"), cfg.Server)
+	token := firstNonEmpty(g.token, os.Getenv("KUBELENS_TOKEN"), cfg.Token)
+	return client.New(server, client.WithToken(token), client.WithUserAgent("kubelens-cli"))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// resourceAliases maps the names accepted by get to their canonical form
+var resourceAliases = map[string]string{
+	"pod": "pods", "pods": "pods", "po": "pods",
+	"workload": "workloads", "workloads": "workloads", "wl": "workloads",
+	"node": "nodes", "nodes": "nodes", "no": "nodes",
+	"event": "events", "events": "events", "ev": "events",
+}
+
+// workloadKinds maps the kinds accepted by restart to their API form
+var workloadKinds = map[string]string{
+	"deployment": "Deployment", "deployments": "Deployment", "deploy": "Deployment",
+	"statefulset": "StatefulSet", "statefulsets": "StatefulSet", "sts": "StatefulSet",
+	"daemonset": "DaemonSet", "daemonsets": "DaemonSet", "ds": "DaemonSet",
+}
+
+func resourceNames() []string {
+	names := make([]string, 0, len(resourceAliases))
+	for name, canonical := range resourceAliases {
+		if name == canonical {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) error {
+	fs.Usage()
+	return fmt.Errorf(format, args...)
+}
+
+func joinOr(values []string) string {
+	return strings.Join(values, "|")
+}
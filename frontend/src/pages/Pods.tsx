@@ -5,6 +5,7 @@ interface Pod {
   name: string;
   namespace: string;
   status: string;
+  phase: string;
   ready: string;
   restarts: number;
   age: string;
@@ -82,21 +83,47 @@ const Pods: React.FC = () => {
     fetchData();
   }, []);
 
-  const getStatusBadge = (status: string) => {
-    const statusMap: { [key: string]: string } = {
-      'Running': 'success',
-      'Pending': 'warning',
-      'Failed': 'error',
-      'Succeeded': 'success',
-      'Unknown': 'default'
-    };
-    return (
-      <span className={`status-badge status-${statusMap[status] || 'default'}`}>
-        {status}
-      </span>
-    );
+  // status 是 kubectl 显示的状态（如 CrashLoopBackOff、Init:0/2），未知的状态按 phase 着色
+  const phaseColors: { [key: string]: string } = {
+    'Running': 'success',
+    'Succeeded': 'success',
+    'Pending': 'warning',
+    'Failed': 'error',
+    'Unknown': 'default'
   };
 
+  const statusColors: { [key: string]: string } = {
+    ...phaseColors,
+    'Completed': 'success',
+    'ContainerCreating': 'warning',
+    'PodInitializing': 'warning',
+    'SchedulingGated': 'warning',
+    'NotReady': 'warning',
+    'Terminating': 'warning',
+    'OOMKilled': 'error',
+    'Evicted': 'error'
+  };
+
+  const getStatusColor = (pod: Pod) => {
+    const status = pod.status;
+    if (statusColors[status]) {
+      return statusColors[status];
+    }
+    if (/^Init:\d+\/\d+$/.test(status)) {
+      return 'warning';
+    }
+    if (/^(Init:|ExitCode:|Signal:)/.test(status) || /(Error|BackOff)$/.test(status)) {
+      return 'error';
+    }
+    return phaseColors[pod.phase] || 'default';
+  };
+
+  const getStatusBadge = (pod: Pod) => (
+    <span className={`status-badge status-${getStatusColor(pod)}`}>
+      {pod.status}
+    </span>
+  );
+
   const filteredPods = pods.filter(pod => {
     const matchesSearch = pod.name.toLowerCase().includes(searchTerm.toLowerCase()) ||
                          pod.namespace.toLowerCase().includes(searchTerm.toLowerCase());
@@ -124,7 +151,7 @@ const Pods: React.FC = () => {
       key: 'status',
       title: '状态',
       width: '120px',
-      render: (value: string) => getStatusBadge(value)
+      render: (_: string, row: Pod) => getStatusBadge(row)
     },
     {
       key: 'ready',
@@ -215,11 +242,11 @@ const Pods: React.FC = () => {
         </div>
         <div className="kubelens-stat-card">
           <div className="kubelens-stat-label">等待中</div>
-          <div className="kubelens-stat-value">{filteredPods.filter(p => p.status === 'Pending').length}</div>
+          <div className="kubelens-stat-value">{filteredPods.filter(p => p.phase === 'Pending').length}</div>
         </div>
         <div className="kubelens-stat-card">
           <div className="kubelens-stat-label">失败</div>
-          <div className="kubelens-stat-value">{filteredPods.filter(p => p.status === 'Failed').length}</div>
+          <div className="kubelens-stat-value">{filteredPods.filter(p => p.phase === 'Failed').length}</div>
         </div>
       </div>
 
@@ -251,13 +278,7 @@ const Pods: React.FC = () => {
                 <div className="kubelens-pod-info">
                   <div className="kubelens-pod-meta">
                     <span className="kubelens-namespace-badge">{selectedPod.namespace}</span>
-                    <span className={`status-badge status-${
-                      selectedPod.status === 'Running' ? 'success' : 
-                      selectedPod.status === 'Pending' ? 'warning' : 
-                      selectedPod.status === 'Failed' ? 'error' : 'default'
-                    }`}>
-                      {selectedPod.status}
-                    </span>
+                    {getStatusBadge(selectedPod)}
                   </div>
                   <div className="kubelens-pod-details">
                     <span>节点: {selectedPod.node}</span>
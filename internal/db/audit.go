@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kubelens/pkg/api"
+)
+
+// RecordAudit appends entries to the audit log
+func (s *Store) RecordAudit(ctx context.Context, entries []api.AuditEntry) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record audit: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO audit_log (created_at, actor, action, kind, namespace, name, dry_run, detail, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`)
+	if err != nil {
+		return fmt.Errorf("failed to record audit: %w", err)
+	}
+	defer stmt.Close()
+	for _, e := range entries {
+		if _, err := stmt.ExecContext(ctx, e.Time, e.Actor, e.Action, e.Kind, e.Namespace, e.Name, e.DryRun, e.Detail, e.Error); err != nil {
+			return fmt.Errorf("failed to record audit: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListAudit returns the audit entries recorded since a time, newest first
+func (s *Store) ListAudit(ctx context.Context, since time.Time) ([]api.AuditEntry, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT created_at, actor, action, kind, namespace, name, dry_run, COALESCE(detail, ''), COALESCE(error, '')
+		FROM audit_log WHERE created_at >= $1 ORDER BY created_at DESC, id DESC`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []api.AuditEntry
+	for rows.Next() {
+		var e api.AuditEntry
+		if err := rows.Scan(&e.Time, &e.Actor, &e.Action, &e.Kind, &e.Namespace, &e.Name, &e.DryRun, &e.Detail, &e.Error); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"kubelens/pkg/api"
+)
+
+// dateFormat is the layout of WorkloadCost.Date
+const dateFormat = "2006-01-02"
+
+// AddDailyCosts adds costs to the daily totals of their workloads, creating the
+// day's row on first use. Labels and the pod count follow the latest costs.
+func (s *Store) AddDailyCosts(ctx context.Context, costs []api.WorkloadCost) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to add costs: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO cost_daily (day, namespace, workload_kind, workload, labels, pods, cpu_core_hours, memory_gb_hours, cpu_cost, memory_cost)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (day, namespace, workload_kind, workload) DO UPDATE SET
+			labels = EXCLUDED.labels,
+			pods = EXCLUDED.pods,
+			cpu_core_hours = cost_daily.cpu_core_hours + EXCLUDED.cpu_core_hours,
+			memory_gb_hours = cost_daily.memory_gb_hours + EXCLUDED.memory_gb_hours,
+			cpu_cost = cost_daily.cpu_cost + EXCLUDED.cpu_cost,
+			memory_cost = cost_daily.memory_cost + EXCLUDED.memory_cost`)
+	if err != nil {
+		return fmt.Errorf("failed to add costs: %w", err)
+	}
+	defer stmt.Close()
+	for _, cost := range costs {
+		labels, err := json.Marshal(cost.Labels)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, cost.Date, cost.Namespace, cost.WorkloadKind, cost.Workload, labels,
+			cost.Pods, cost.CPUCoreHours, cost.MemoryGBHours, cost.CPUCost, cost.MemoryCost); err != nil {
+			return fmt.Errorf("failed to add costs: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListDailyCosts returns the daily workload costs from one day to another, both included
+func (s *Store) ListDailyCosts(ctx context.Context, from, to time.Time) ([]api.WorkloadCost, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT day, namespace, workload_kind, workload, labels, pods, cpu_core_hours, memory_gb_hours, cpu_cost, memory_cost
+		FROM cost_daily WHERE day >= $1 AND day <= $2 ORDER BY day, namespace, workload_kind, workload`,
+		from.Format(dateFormat), to.Format(dateFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list costs: %w", err)
+	}
+	defer rows.Close()
+
+	var costs []api.WorkloadCost
+	for rows.Next() {
+		var cost api.WorkloadCost
+		var day time.Time
+		var labels []byte
+		if err := rows.Scan(&day, &cost.Namespace, &cost.WorkloadKind, &cost.Workload, &labels, &cost.Pods,
+			&cost.CPUCoreHours, &cost.MemoryGBHours, &cost.CPUCost, &cost.MemoryCost); err != nil {
+			return nil, err
+		}
+		cost.Date = day.Format(dateFormat)
+		if len(labels) > 0 {
+			if err := json.Unmarshal(labels, &cost.Labels); err != nil {
+				return nil, err
+			}
+		}
+		costs = append(costs, cost)
+	}
+	return costs, rows.Err()
+}
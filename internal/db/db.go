@@ -25,7 +25,7 @@ func New(pgURL string) (*Store, error) {
 
 func (s *Store) Close() error { return s.DB.Close() }
 
-// Example table to store cluster snapshots or favorites
+// EnsureSchema creates the tables KubeLens uses
 func (s *Store) EnsureSchema(ctx context.Context) error {
 	_, err := s.DB.ExecContext(ctx, `
 CREATE TABLE IF NOT EXISTS cluster_events (
@@ -37,6 +37,75 @@ CREATE TABLE IF NOT EXISTS cluster_events (
 	level TEXT,
 	message TEXT
 );
+ALTER TABLE cluster_events ADD COLUMN IF NOT EXISTS uid TEXT;
+ALTER TABLE cluster_events ADD COLUMN IF NOT EXISTS reason TEXT;
+ALTER TABLE cluster_events ADD COLUMN IF NOT EXISTS count INT NOT NULL DEFAULT 1;
+CREATE UNIQUE INDEX IF NOT EXISTS cluster_events_uid_idx ON cluster_events (uid);
+CREATE INDEX IF NOT EXISTS cluster_events_object_idx ON cluster_events (namespace, kind, name, created_at);
+
+CREATE TABLE IF NOT EXISTS resource_changes (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	kind TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	name TEXT NOT NULL,
+	action TEXT NOT NULL,
+	manager TEXT,
+	generation BIGINT,
+	fields JSONB
+);
+CREATE INDEX IF NOT EXISTS resource_changes_object_idx ON resource_changes (namespace, kind, name, created_at);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	reason TEXT NOT NULL,
+	object_count INT NOT NULL,
+	data BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS snapshots_created_at_idx ON snapshots (created_at);
+
+CREATE TABLE IF NOT EXISTS container_usage (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	namespace TEXT NOT NULL,
+	pod TEXT NOT NULL,
+	container TEXT NOT NULL,
+	workload_kind TEXT NOT NULL,
+	workload TEXT NOT NULL,
+	cpu_millicores BIGINT NOT NULL,
+	memory_bytes BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS container_usage_namespace_idx ON container_usage (namespace, created_at);
+CREATE INDEX IF NOT EXISTS container_usage_created_at_idx ON container_usage (created_at);
+
+CREATE TABLE IF NOT EXISTS cost_daily (
+	day DATE NOT NULL,
+	namespace TEXT NOT NULL,
+	workload_kind TEXT NOT NULL,
+	workload TEXT NOT NULL,
+	labels JSONB,
+	pods INT NOT NULL,
+	cpu_core_hours DOUBLE PRECISION NOT NULL,
+	memory_gb_hours DOUBLE PRECISION NOT NULL,
+	cpu_cost DOUBLE PRECISION NOT NULL,
+	memory_cost DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (day, namespace, workload_kind, workload)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	actor TEXT NOT NULL,
+	action TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	name TEXT NOT NULL,
+	dry_run BOOLEAN NOT NULL,
+	detail TEXT,
+	error TEXT
+);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
 `)
 	return err
 }
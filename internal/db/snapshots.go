@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kubelens/pkg/api"
+)
+
+// ErrNotFound is returned when a stored record does not exist
+var ErrNotFound = errors.New("not found")
+
+// SaveSnapshot stores a compressed snapshot and returns it with its ID and size
+func (s *Store) SaveSnapshot(ctx context.Context, info api.SnapshotInfo, data []byte) (api.SnapshotInfo, error) {
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO snapshots (created_at, reason, object_count, data) VALUES ($1, $2, $3, $4) RETURNING id`,
+		info.CreatedAt, info.Reason, info.Objects, data,
+	).Scan(&info.ID)
+	if err != nil {
+		return api.SnapshotInfo{}, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	info.Size = int64(len(data))
+	return info, nil
+}
+
+// ListSnapshots returns the stored snapshots, newest first
+func (s *Store) ListSnapshots(ctx context.Context) ([]api.SnapshotInfo, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, created_at, reason, object_count, octet_length(data) FROM snapshots ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var infos []api.SnapshotInfo
+	for rows.Next() {
+		var info api.SnapshotInfo
+		if err := rows.Scan(&info.ID, &info.CreatedAt, &info.Reason, &info.Objects, &info.Size); err != nil {
+			return nil, err
+		}
+		info.CreatedAt = info.CreatedAt.UTC()
+		infos = append(infos, info)
+	}
+	return infos, rows.Err()
+}
+
+// GetSnapshot returns a snapshot and its compressed data
+func (s *Store) GetSnapshot(ctx context.Context, id int64) (api.SnapshotInfo, []byte, error) {
+	info := api.SnapshotInfo{ID: id}
+	var data []byte
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT created_at, reason, object_count, data FROM snapshots WHERE id = $1`, id,
+	).Scan(&info.CreatedAt, &info.Reason, &info.Objects, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return info, nil, fmt.Errorf("snapshot %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to load snapshot %d: %w", id, err)
+	}
+	info.CreatedAt = info.CreatedAt.UTC()
+	info.Size = int64(len(data))
+	return info, data, nil
+}
+
+// DeleteSnapshotsBefore removes snapshots taken before t
+func (s *Store) DeleteSnapshotsBefore(ctx context.Context, t time.Time) (int, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM snapshots WHERE created_at < $1`, t)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete snapshots: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DiskSnapshotStore keeps snapshots as files in a directory. The metadata is
+// encoded in the file name, e.g. 000042_20240312T030000Z_scheduled_1834.json.gz,
+// so listing does not need to read the files.
+type DiskSnapshotStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+const diskTimeFormat = "20060102T150405Z"
+
+// NewDiskSnapshotStore creates dir if needed and returns a store writing to it
+func NewDiskSnapshotStore(dir string) (*DiskSnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskSnapshotStore{dir: dir}, nil
+}
+
+func (d *DiskSnapshotStore) SaveSnapshot(ctx context.Context, info api.SnapshotInfo, data []byte) (api.SnapshotInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	infos, err := d.list()
+	if err != nil {
+		return api.SnapshotInfo{}, err
+	}
+	info.ID = 1
+	for _, existing := range infos {
+		if existing.info.ID >= info.ID {
+			info.ID = existing.info.ID + 1
+		}
+	}
+	info.Size = int64(len(data))
+	info.CreatedAt = info.CreatedAt.UTC().Truncate(time.Second)
+
+	name := fmt.Sprintf("%06d_%s_%s_%d.json.gz", info.ID, info.CreatedAt.UTC().Format(diskTimeFormat), info.Reason, info.Objects)
+	tmp := filepath.Join(d.dir, "."+name)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return api.SnapshotInfo{}, err
+	}
+	if err := os.Rename(tmp, filepath.Join(d.dir, name)); err != nil {
+		return api.SnapshotInfo{}, err
+	}
+	return info, nil
+}
+
+func (d *DiskSnapshotStore) ListSnapshots(ctx context.Context) ([]api.SnapshotInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	files, err := d.list()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]api.SnapshotInfo, 0, len(files))
+	for _, f := range files {
+		infos = append(infos, f.info)
+	}
+	return infos, nil
+}
+
+func (d *DiskSnapshotStore) GetSnapshot(ctx context.Context, id int64) (api.SnapshotInfo, []byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	files, err := d.list()
+	if err != nil {
+		return api.SnapshotInfo{}, nil, err
+	}
+	for _, f := range files {
+		if f.info.ID == id {
+			data, err := os.ReadFile(f.path)
+			return f.info, data, err
+		}
+	}
+	return api.SnapshotInfo{ID: id}, nil, fmt.Errorf("snapshot %d: %w", id, ErrNotFound)
+}
+
+func (d *DiskSnapshotStore) DeleteSnapshotsBefore(ctx context.Context, t time.Time) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	files, err := d.list()
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, f := range files {
+		if f.info.CreatedAt.Before(t) {
+			if err := os.Remove(f.path); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type snapshotFile struct {
+	path string
+	info api.SnapshotInfo
+}
+
+// list parses the snapshot file names, newest first; other files are ignored
+func (d *DiskSnapshotStore) list() ([]snapshotFile, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []snapshotFile
+	for _, entry := range entries {
+		base, ok := strings.CutSuffix(entry.Name(), ".json.gz")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		parts := strings.Split(base, "_")
+		if len(parts) != 4 {
+			continue
+		}
+		id, err1 := strconv.ParseInt(parts[0], 10, 64)
+		createdAt, err2 := time.Parse(diskTimeFormat, parts[1])
+		objects, err3 := strconv.Atoi(parts[3])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, snapshotFile{
+			path: filepath.Join(d.dir, entry.Name()),
+			info: api.SnapshotInfo{ID: id, CreatedAt: createdAt, Reason: parts[2], Objects: objects, Size: fi.Size()},
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].info.ID > files[j].info.ID })
+	return files, nil
+}
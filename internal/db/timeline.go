@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"kubelens/pkg/api"
+)
+
+// TimelineFilter selects the changes and events of a namespace or object.
+// Empty fields match everything.
+type TimelineFilter struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Since     time.Time
+	Type      string // change or event
+}
+
+// where builds the WHERE clause of a timeline query
+func (f TimelineFilter) where() (string, []interface{}) {
+	clauses := []string{"created_at >= $1"}
+	args := []interface{}{f.Since}
+	for _, c := range []struct{ column, value string }{
+		{"namespace", f.Namespace},
+		{"kind", f.Kind},
+		{"name", f.Name},
+	} {
+		if c.value != "" {
+			args = append(args, c.value)
+			clauses = append(clauses, fmt.Sprintf("%s = $%d", c.column, len(args)))
+		}
+	}
+	return "WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// RecordChange stores a spec change of a resource
+func (s *Store) RecordChange(ctx context.Context, change api.TimelineEntry) error {
+	fields, err := json.Marshal(change.Fields)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO resource_changes (created_at, kind, namespace, name, action, manager, generation, fields)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		change.Time, change.Kind, change.Namespace, change.Name, change.Action, change.Manager, change.Generation, fields,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record change: %w", err)
+	}
+	return nil
+}
+
+// ArchiveEvent stores a Kubernetes event, updating its count and time when
+// the event with the same uid was seen before
+func (s *Store) ArchiveEvent(ctx context.Context, uid string, event api.TimelineEntry) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO cluster_events (created_at, kind, name, namespace, level, message, uid, reason, count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (uid) DO UPDATE SET created_at = EXCLUDED.created_at, message = EXCLUDED.message, count = EXCLUDED.count`,
+		event.Time, event.Kind, event.Name, event.Namespace, event.Level, event.Message, uid, event.Reason, event.Count,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to archive event: %w", err)
+	}
+	return nil
+}
+
+// timelineQuery merges the recorded changes and archived events matching the
+// WHERE clause into the columns of a timeline entry
+const timelineQuery = `
+SELECT 'change' AS type, created_at, kind, namespace, name, action, COALESCE(manager, '') AS manager,
+	COALESCE(generation, 0) AS generation, fields, '' AS level, '' AS reason, '' AS message, 0 AS count
+FROM resource_changes %[1]s AND %[2]s
+UNION ALL
+SELECT 'event', created_at, kind, COALESCE(namespace, ''), name, '', '', 0, NULL,
+	COALESCE(level, ''), COALESCE(reason, ''), COALESCE(message, ''), count
+FROM cluster_events %[1]s AND %[3]s`
+
+// ListTimeline returns a page of the changes and events matching filter, newest
+// first, and the number of entries on all pages. Type is "change" or "event".
+func (s *Store) ListTimeline(ctx context.Context, filter TimelineFilter, limit, offset int) ([]api.TimelineEntry, int, error) {
+	where, args := filter.where()
+	changes, events := "TRUE", "TRUE"
+	switch filter.Type {
+	case "change":
+		events = "FALSE"
+	case "event":
+		changes = "FALSE"
+	}
+	query := fmt.Sprintf(timelineQuery, where, changes, events)
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+query+") timeline", args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count timeline: %w", err)
+	}
+	page := query + " ORDER BY created_at DESC"
+	if limit > 0 {
+		page += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		page += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	rows, err := s.DB.QueryContext(ctx, page, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list timeline: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []api.TimelineEntry
+	for rows.Next() {
+		var e api.TimelineEntry
+		var fields []byte
+		if err := rows.Scan(&e.Type, &e.Time, &e.Kind, &e.Namespace, &e.Name, &e.Action, &e.Manager,
+			&e.Generation, &fields, &e.Level, &e.Reason, &e.Message, &e.Count); err != nil {
+			return nil, 0, err
+		}
+		if len(fields) > 0 {
+			if err := json.Unmarshal(fields, &e.Fields); err != nil {
+				return nil, 0, err
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// DeleteTimelineBefore removes the changes recorded and the events last seen before t
+func (s *Store) DeleteTimelineBefore(ctx context.Context, t time.Time) (int, error) {
+	deleted := 0
+	for _, table := range []string{"resource_changes", "cluster_events"} {
+		res, err := s.DB.ExecContext(ctx, "DELETE FROM "+table+" WHERE created_at < $1", t)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete old %s: %w", table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"kubelens/pkg/api"
+)
+
+// RecordUsage stores a batch of container usage samples in one transaction
+func (s *Store) RecordUsage(ctx context.Context, samples []api.UsageSample) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO container_usage (created_at, namespace, pod, container, workload_kind, workload, cpu_millicores, memory_bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	defer stmt.Close()
+	for _, sample := range samples {
+		if _, err := stmt.ExecContext(ctx, sample.Time, sample.Namespace, sample.Pod, sample.Container,
+			sample.WorkloadKind, sample.Workload, sample.CPUUsage, sample.MemoryUsage); err != nil {
+			return fmt.Errorf("failed to record usage: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListUsage returns the samples taken since a time, of one namespace or all when namespace is empty
+func (s *Store) ListUsage(ctx context.Context, namespace string, since time.Time) ([]api.UsageSample, error) {
+	query := `SELECT created_at, namespace, pod, container, workload_kind, workload, cpu_millicores, memory_bytes
+		FROM container_usage WHERE created_at >= $1`
+	args := []interface{}{since}
+	if namespace != "" {
+		query += ` AND namespace = $2`
+		args = append(args, namespace)
+	}
+	rows, err := s.DB.QueryContext(ctx, query+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []api.UsageSample
+	for rows.Next() {
+		var sample api.UsageSample
+		if err := rows.Scan(&sample.Time, &sample.Namespace, &sample.Pod, &sample.Container,
+			&sample.WorkloadKind, &sample.Workload, &sample.CPUUsage, &sample.MemoryUsage); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// DeleteUsageBefore removes the samples taken before t
+func (s *Store) DeleteUsageBefore(ctx context.Context, t time.Time) (int, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM container_usage WHERE created_at < $1`, t)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete usage: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DefaultMemoryUsageSamples is how many samples a MemoryUsageStore keeps
+// unless USAGE_MEMORY_SAMPLES says otherwise, roughly 100MB
+const DefaultMemoryUsageSamples = 500000
+
+// MemoryUsageStore keeps usage samples in memory when there is no database;
+// the history is lost on restart, and the oldest samples are dropped once
+// there are more than the limit
+type MemoryUsageStore struct {
+	mu      sync.Mutex
+	limit   int
+	samples []api.UsageSample
+}
+
+// NewMemoryUsageStore returns an empty store keeping at most limit samples
+func NewMemoryUsageStore(limit int) *MemoryUsageStore {
+	return &MemoryUsageStore{limit: limit}
+}
+
+// RecordUsage appends a batch of samples, dropping the oldest over the limit
+func (m *MemoryUsageStore) RecordUsage(ctx context.Context, samples []api.UsageSample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, samples...)
+	if over := len(m.samples) - m.limit; over > 0 {
+		m.samples = append([]api.UsageSample(nil), m.samples[over:]...)
+	}
+	return nil
+}
+
+// ListUsage returns the samples taken since a time, of one namespace or all when namespace is empty
+func (m *MemoryUsageStore) ListUsage(ctx context.Context, namespace string, since time.Time) ([]api.UsageSample, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var samples []api.UsageSample
+	for _, sample := range m.samples {
+		if !sample.Time.Before(since) && (namespace == "" || sample.Namespace == namespace) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// DeleteUsageBefore removes the samples taken before t
+func (m *MemoryUsageStore) DeleteUsageBefore(ctx context.Context, t time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.samples[:0]
+	for _, sample := range m.samples {
+		if !sample.Time.Before(t) {
+			kept = append(kept, sample)
+		}
+	}
+	deleted := len(m.samples) - len(kept)
+	m.samples = kept
+	return deleted, nil
+}
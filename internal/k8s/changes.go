@@ -0,0 +1,290 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"time"
+
+	"kubelens/internal/db"
+	"kubelens/pkg/api"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Timeline entry types and change actions
+const (
+	TimelineChange = "change"
+	TimelineEvent  = "event"
+
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// ChangeStore records resource changes and archives events for the timeline
+type ChangeStore interface {
+	RecordChange(ctx context.Context, change api.TimelineEntry) error
+	ArchiveEvent(ctx context.Context, uid string, event api.TimelineEntry) error
+	ListTimeline(ctx context.Context, filter db.TimelineFilter, limit, offset int) ([]api.TimelineEntry, int, error)
+	DeleteTimelineBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+// timelinePruneInterval is how often changes and events older than the retention are deleted
+const timelinePruneInterval = time.Hour
+
+// RunChangeRecorder watches workloads, services, configmaps and nodes and records
+// their spec changes, and archives every event, until ctx is done. Objects that
+// already exist at start are not recorded as created. Changes and events older
+// than retain are deleted every hour; a zero retain keeps them forever.
+func (c *Client) RunChangeRecorder(ctx context.Context, store ChangeStore, retain time.Duration) {
+	factory := informers.NewSharedInformerFactory(c.Clientset, 0)
+	defer factory.Shutdown()
+
+	for _, informer := range []cache.SharedIndexInformer{
+		factory.Apps().V1().Deployments().Informer(),
+		factory.Apps().V1().StatefulSets().Informer(),
+		factory.Apps().V1().DaemonSets().Informer(),
+		factory.Core().V1().Services().Informer(),
+		factory.Core().V1().ConfigMaps().Informer(),
+		factory.Core().V1().Nodes().Informer(),
+	} {
+		if _, err := informer.AddEventHandler(changeHandler(ctx, store)); err != nil {
+			log.Printf("[warn] change recorder: %v", err)
+			return
+		}
+	}
+
+	archive := func(obj interface{}) {
+		if event, ok := obj.(*corev1.Event); ok {
+			if err := store.ArchiveEvent(ctx, string(event.UID), eventEntry(*event)); err != nil {
+				log.Printf("[warn] %v", err)
+			}
+		}
+	}
+	_, err := factory.Core().V1().Events().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    archive,
+		UpdateFunc: func(_, obj interface{}) { archive(obj) },
+	})
+	if err != nil {
+		log.Printf("[warn] change recorder: %v", err)
+		return
+	}
+
+	factory.Start(ctx.Done())
+	log.Printf("Recording resource changes")
+	if retain <= 0 {
+		<-ctx.Done()
+		return
+	}
+	ticker := time.NewTicker(timelinePruneInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := store.DeleteTimelineBefore(ctx, time.Now().Add(-retain)); err != nil {
+			log.Printf("[warn] change recorder: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func changeHandler(ctx context.Context, store ChangeStore) cache.ResourceEventHandler {
+	record := func(change api.TimelineEntry, ok bool) {
+		if !ok {
+			return
+		}
+		if err := store.RecordChange(ctx, change); err != nil {
+			log.Printf("[warn] %v", err)
+		}
+	}
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				record(detectChange(nil, obj))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) { record(detectChange(oldObj, newObj)) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			record(detectChange(obj, nil))
+		},
+	}
+}
+
+// detectChange compares the tracked fields of two versions of an object. A nil
+// oldObj is a creation, a nil newObj a deletion. ok is false when nothing
+// tracked changed, e.g. on status updates.
+func detectChange(oldObj, newObj interface{}) (change api.TimelineEntry, ok bool) {
+	var oldMeta, newMeta metav1.Object
+	var oldFields, newFields map[string]interface{}
+	var kind string
+	if oldObj != nil {
+		kind, oldMeta, oldFields = trackedFields(oldObj)
+	}
+	if newObj != nil {
+		kind, newMeta, newFields = trackedFields(newObj)
+	}
+	if kind == "" {
+		return change, false
+	}
+
+	obj := newMeta
+	change = api.TimelineEntry{Time: time.Now().UTC(), Type: TimelineChange, Action: ChangeUpdated}
+	switch {
+	case oldMeta == nil:
+		change.Action = ChangeCreated
+		change.Fields = diffFields(map[string]interface{}{}, newFields)
+	case newMeta == nil:
+		obj = oldMeta
+		change.Action = ChangeDeleted
+	default:
+		change.Fields = diffFields(oldFields, newFields)
+		if len(change.Fields) == 0 {
+			return change, false
+		}
+	}
+	change.ObjectRef = api.ObjectRef{Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	change.Generation = obj.GetGeneration()
+	if newMeta != nil {
+		change.Manager = lastManager(newMeta.GetManagedFields())
+	}
+	return change, true
+}
+
+// trackedFields flattens the fields of obj whose changes are recorded: the
+// generation, labels, images and replicas of workloads, the ports and selector
+// of services, a digest of configmap values and the taints of nodes
+func trackedFields(obj interface{}) (string, metav1.Object, map[string]interface{}) {
+	fields := make(map[string]interface{})
+	var kind string
+	var meta metav1.Object
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		kind, meta = "Deployment", o
+		if o.Spec.Replicas != nil {
+			fields["spec.replicas"] = *o.Spec.Replicas
+		}
+		podTemplateFields(o.Spec.Template, fields)
+	case *appsv1.StatefulSet:
+		kind, meta = "StatefulSet", o
+		if o.Spec.Replicas != nil {
+			fields["spec.replicas"] = *o.Spec.Replicas
+		}
+		podTemplateFields(o.Spec.Template, fields)
+	case *appsv1.DaemonSet:
+		kind, meta = "DaemonSet", o
+		podTemplateFields(o.Spec.Template, fields)
+	case *corev1.Service:
+		kind, meta = "Service", o
+		fields["spec.type"] = string(o.Spec.Type)
+		for key, value := range o.Spec.Selector {
+			fields["spec.selector."+key] = value
+		}
+		for _, port := range o.Spec.Ports {
+			name := port.Name
+			if name == "" {
+				name = fmt.Sprint(port.Port)
+			}
+			fields["spec.ports["+name+"]"] = fmt.Sprintf("%d:%s/%s", port.Port, port.TargetPort.String(), port.Protocol)
+		}
+	case *corev1.ConfigMap:
+		kind, meta = "ConfigMap", o
+		// Values are digested: they can be large and the timeline only needs to show that they changed
+		for key, value := range o.Data {
+			fields["data."+key] = valueDigest([]byte(value))
+		}
+		for key, value := range o.BinaryData {
+			fields["binaryData."+key] = valueDigest(value)
+		}
+	case *corev1.Node:
+		kind, meta = "Node", o
+		fields["spec.unschedulable"] = o.Spec.Unschedulable
+		for _, taint := range o.Spec.Taints {
+			fields["spec.taints["+taint.Key+":"+string(taint.Effect)+"]"] = taint.Value
+		}
+	default:
+		return "", nil, nil
+	}
+
+	if generation := meta.GetGeneration(); generation > 0 {
+		fields["metadata.generation"] = generation
+	}
+	for key, value := range meta.GetLabels() {
+		fields["metadata.labels."+key] = value
+	}
+	return kind, meta, fields
+}
+
+// podTemplateFields adds the container images of a pod template, keyed by container name
+func podTemplateFields(template corev1.PodTemplateSpec, fields map[string]interface{}) {
+	for _, container := range template.Spec.InitContainers {
+		fields["spec.template.spec.initContainers["+container.Name+"].image"] = container.Image
+	}
+	for _, container := range template.Spec.Containers {
+		fields["spec.template.spec.containers["+container.Name+"].image"] = container.Image
+	}
+}
+
+func valueDigest(value []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(value))[:19]
+}
+
+// lastManager returns the field manager of the most recent write to the object
+// itself; status writes by controllers are not what changed the spec
+func lastManager(entries []metav1.ManagedFieldsEntry) string {
+	var manager string
+	var latest time.Time
+	for _, entry := range entries {
+		if entry.Subresource != "" || entry.Time == nil {
+			continue
+		}
+		if manager == "" || !entry.Time.Time.Before(latest) {
+			manager, latest = entry.Manager, entry.Time.Time
+		}
+	}
+	return manager
+}
+
+// eventEntry converts an event to a timeline entry of its involved object
+func eventEntry(event corev1.Event) api.TimelineEntry {
+	return api.TimelineEntry{
+		Time: eventTime(event).UTC(),
+		Type: TimelineEvent,
+		ObjectRef: api.ObjectRef{
+			Kind:      event.InvolvedObject.Kind,
+			Namespace: event.Namespace,
+			Name:      event.InvolvedObject.Name,
+		},
+		Level:   event.Type,
+		Reason:  event.Reason,
+		Message: event.Message,
+		Count:   event.Count,
+	}
+}
+
+// Timeline returns a page of the recorded changes and archived events matching
+// filter, newest first, and the number of entries on all pages
+func Timeline(ctx context.Context, store ChangeStore, filter db.TimelineFilter, limit, offset int) ([]api.TimelineEntry, int, error) {
+	entries, total, err := store.ListTimeline(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if entries == nil {
+		entries = []api.TimelineEntry{}
+	}
+	for i := range entries {
+		entries[i].Time = entries[i].Time.UTC()
+		entries[i].Age = formatAge(entries[i].Time)
+	}
+	return entries, total, nil
+}
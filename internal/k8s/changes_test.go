@@ -0,0 +1,328 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"kubelens/internal/db"
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// memoryChangeStore is a ChangeStore for tests
+type memoryChangeStore struct {
+	mu      sync.Mutex
+	changes []api.TimelineEntry
+	events  map[string]api.TimelineEntry
+}
+
+func (m *memoryChangeStore) RecordChange(ctx context.Context, change api.TimelineEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.changes = append(m.changes, change)
+	return nil
+}
+
+func (m *memoryChangeStore) ArchiveEvent(ctx context.Context, uid string, event api.TimelineEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = make(map[string]api.TimelineEntry)
+	}
+	m.events[uid] = event
+	return nil
+}
+
+func (m *memoryChangeStore) ListTimeline(ctx context.Context, filter db.TimelineFilter, limit, offset int) ([]api.TimelineEntry, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var entries []api.TimelineEntry
+	if filter.Type != TimelineEvent {
+		for _, change := range m.changes {
+			change.Type = TimelineChange
+			entries = append(entries, change)
+		}
+	}
+	if filter.Type != TimelineChange {
+		for _, event := range m.events {
+			event.Type = TimelineEvent
+			entries = append(entries, event)
+		}
+	}
+	entries = filterTimeline(entries, filter)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	total := len(entries)
+	if offset > total {
+		offset = total
+	}
+	entries = entries[offset:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries, total, nil
+}
+
+func (m *memoryChangeStore) DeleteTimelineBefore(ctx context.Context, t time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var kept []api.TimelineEntry
+	for _, change := range m.changes {
+		if !change.Time.Before(t) {
+			kept = append(kept, change)
+		}
+	}
+	deleted := len(m.changes) - len(kept)
+	m.changes = kept
+	for uid, event := range m.events {
+		if event.Time.Before(t) {
+			delete(m.events, uid)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func filterTimeline(entries []api.TimelineEntry, filter db.TimelineFilter) []api.TimelineEntry {
+	var matched []api.TimelineEntry
+	for _, e := range entries {
+		if e.Time.Before(filter.Since) ||
+			filter.Namespace != "" && e.Namespace != filter.Namespace ||
+			filter.Kind != "" && e.Kind != filter.Kind ||
+			filter.Name != "" && e.Name != filter.Name {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return matched
+}
+
+func (m *memoryChangeStore) snapshot() ([]api.TimelineEntry, map[string]api.TimelineEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := make(map[string]api.TimelineEntry, len(m.events))
+	for uid, event := range m.events {
+		events[uid] = event
+	}
+	return append([]api.TimelineEntry(nil), m.changes...), events
+}
+
+func TestDetectChange(t *testing.T) {
+	old := testDeployment("shop", "web", 2, 2)
+	old.Generation = 3
+	old.Spec.Template.Spec.Containers = []corev1.Container{{Name: "web", Image: "nginx:1.24"}}
+
+	statusOnly := old.DeepCopy()
+	statusOnly.Status.ReadyReplicas = 1
+	if _, ok := detectChange(old, statusOnly); ok {
+		t.Error("status update recorded as a change")
+	}
+
+	updated := old.DeepCopy()
+	updated.Generation = 4
+	updated.Spec.Template.Spec.Containers[0].Image = "nginx:1.25"
+	updated.Labels = map[string]string{"tier": "frontend"}
+	earlier, later := metav1.NewTime(time.Now().Add(-time.Hour)), metav1.NewTime(time.Now())
+	updated.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate, Time: &earlier},
+		{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, Time: &later, Subresource: "status"},
+		{Manager: "argocd", Operation: metav1.ManagedFieldsOperationApply, Time: &later},
+	}
+
+	change, ok := detectChange(old, updated)
+	if !ok {
+		t.Fatal("change not detected")
+	}
+	if change.Action != ChangeUpdated || change.Manager != "argocd" || change.Generation != 4 ||
+		change.ObjectRef != (api.ObjectRef{Kind: "Deployment", Namespace: "shop", Name: "web"}) {
+		t.Errorf("unexpected change %+v", change)
+	}
+	var paths []string
+	for _, field := range change.Fields {
+		paths = append(paths, field.Path)
+	}
+	want := []string{"metadata.generation", "metadata.labels.tier", "spec.template.spec.containers[web].image"}
+	if !equalStrings(paths, want) {
+		t.Errorf("fields %v, want %v", paths, want)
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: objectMeta("shop", "settings", nil), Data: map[string]string{"mode": "fast"}}
+	edited := cm.DeepCopy()
+	edited.Data["mode"] = "safe"
+	change, ok = detectChange(cm, edited)
+	if !ok || len(change.Fields) != 1 || change.Fields[0].Path != "data.mode" || change.Fields[0].New == "safe" {
+		t.Errorf("configmap change %+v", change)
+	}
+
+	if change, ok := detectChange(cm, nil); !ok || change.Action != ChangeDeleted || len(change.Fields) != 0 {
+		t.Errorf("deletion %+v", change)
+	}
+	if _, ok := detectChange(nil, testPod("shop", "web-1", corev1.PodRunning, nil)); ok {
+		t.Error("pods are not tracked")
+	}
+}
+
+func TestRunChangeRecorder(t *testing.T) {
+	deploy := testDeployment("shop", "web", 1, 1)
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "web", Image: "nginx:1.24"}}
+	c := newFakeClient(t, deploy, testService("shop", "web"))
+	store := &memoryChangeStore{}
+	// Older than the retention, pruned when the recorder starts
+	store.RecordChange(context.Background(), api.TimelineEntry{
+		Time: time.Now().Add(-48 * time.Hour), ObjectRef: api.ObjectRef{Kind: "Deployment", Namespace: "shop", Name: "legacy"}, Action: ChangeCreated,
+	})
+
+	// Register watches from the reactor so that changes made once all seven
+	// informers are watching cannot be missed
+	fakeClientset := c.Clientset.(*fake.Clientset)
+	watching := make(chan string, 10)
+	fakeClientset.PrependWatchReactor("*", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		w, err := fakeClientset.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		watching <- action.GetResource().Resource
+		return true, w, err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.RunChangeRecorder(ctx, store, 24*time.Hour)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for i := 0; i < 7; i++ {
+		select {
+		case <-watching:
+		case <-time.After(5 * time.Second):
+			t.Fatal("informers did not start watching")
+		}
+	}
+
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "shop", Name: "web.1", UID: "e1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Deployment", Name: "web"},
+		Reason:         "ScalingReplicaSet",
+		Count:          1,
+	}
+	if _, err := c.Clientset.CoreV1().Events("shop").Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	apps := c.Clientset.AppsV1().Deployments("shop")
+	live, _ := apps.Get(ctx, "web", metav1.GetOptions{})
+	live.Spec.Template.Spec.Containers[0].Image = "nginx:1.25"
+	if _, err := apps.Update(ctx, live, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Clientset.CoreV1().Services("shop").Delete(ctx, "web", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: objectMeta("shop", "settings", nil)}
+	if _, err := c.Clientset.CoreV1().ConfigMaps("shop").Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		changes, events := store.snapshot()
+		return len(changes) == 3 && len(events) == 1 && changes[0].Name != "legacy"
+	})
+	changes, events := store.snapshot()
+	if e := events["e1"]; e.Kind != "Deployment" || e.Name != "web" || e.Reason != "ScalingReplicaSet" {
+		t.Errorf("archived event %+v", e)
+	}
+	actions := make(map[string]string)
+	for _, change := range changes {
+		actions[change.Kind+"/"+change.Name] = change.Action
+	}
+	want := map[string]string{"Deployment/web": ChangeUpdated, "Service/web": ChangeDeleted, "ConfigMap/settings": ChangeCreated}
+	for key, action := range want {
+		if actions[key] != action {
+			t.Errorf("%s: action %q, want %q (all: %v)", key, actions[key], action, actions)
+		}
+	}
+}
+
+func TestTimelineEndpoint(t *testing.T) {
+	now := time.Now().UTC()
+	store := &memoryChangeStore{}
+	store.RecordChange(context.Background(), api.TimelineEntry{
+		Time: now.Add(-time.Hour), ObjectRef: api.ObjectRef{Kind: "Deployment", Namespace: "shop", Name: "web"}, Action: ChangeUpdated,
+	})
+	store.RecordChange(context.Background(), api.TimelineEntry{
+		Time: now.Add(-48 * time.Hour), ObjectRef: api.ObjectRef{Kind: "Deployment", Namespace: "shop", Name: "web"}, Action: ChangeCreated,
+	})
+	store.ArchiveEvent(context.Background(), "e1", api.TimelineEntry{
+		Time: now.Add(-time.Minute), ObjectRef: api.ObjectRef{Kind: "Deployment", Namespace: "shop", Name: "web"},
+		Level: "Normal", Reason: "ScalingReplicaSet",
+	})
+	store.ArchiveEvent(context.Background(), "e2", api.TimelineEntry{
+		Time: now, ObjectRef: api.ObjectRef{Kind: "Pod", Namespace: "other", Name: "x"}, Reason: "Pulled",
+	})
+	h := NewHandler(newFakeClient(t), WithChangeStore(store))
+
+	w := serve(t, h, http.MethodGet, "/timeline?namespace=shop&kind=deploy&name=web")
+	var list api.ListResponse[api.TimelineEntry]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 2 || list.Items[0].Type != TimelineEvent || list.Items[1].Type != TimelineChange {
+		t.Errorf("unexpected timeline %+v", list.Items)
+	}
+
+	w = serve(t, h, http.MethodGet, "/timeline?since=72h&status=change")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 2 || list.Items[0].Action != ChangeUpdated {
+		t.Errorf("unexpected changes %+v", list.Items)
+	}
+
+	// Pages come from the store
+	w = serve(t, h, http.MethodGet, "/timeline?since=72h&limit=2")
+	list = api.ListResponse[api.TimelineEntry]{}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 4 || len(list.Items) != 2 || list.Items[0].Reason != "Pulled" || list.Continue == "" {
+		t.Fatalf("first page %+v", list)
+	}
+	w = serve(t, h, http.MethodGet, "/timeline?since=72h&limit=2&continue="+list.Continue)
+	list = api.ListResponse[api.TimelineEntry]{}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 || list.Items[1].Action != ChangeCreated || list.Continue != "" {
+		t.Errorf("second page %+v", list)
+	}
+
+	for _, query := range []string{"since=soon", "status=warning", "sort=name"} {
+		if w := serve(t, h, http.MethodGet, "/timeline?"+query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, w.Code)
+		}
+	}
+	if w := serve(t, NewHandler(newFakeClient(t)), http.MethodGet, "/timeline"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without store: status %d", w.Code)
+	}
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -3,13 +3,17 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"kubelens/pkg/api"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,9 +21,17 @@ import (
 	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
+// Client wraps the Kubernetes and metrics APIs. Any implementation of the
+// interfaces works, e.g. the client-go fake clientsets in tests.
 type Client struct {
-	Clientset     *kubernetes.Clientset
-	MetricsClient *metricsclientset.Clientset
+	Clientset     kubernetes.Interface
+	MetricsClient metricsclientset.Interface
+	// Config connects to the API server directly, for the proxy and exec; nil for fake clients
+	Config *rest.Config
+	// execer replaces pods/exec in tests
+	execer execFunc
+
+	searchIndex searchIndex
 }
 
 func NewClient() (*Client, error) {
@@ -41,6 +53,7 @@ func NewClient() (*Client, error) {
 	return &Client{
 		Clientset:     clientset,
 		MetricsClient: metricsClient,
+		Config:        config,
 	}, nil
 }
 
@@ -65,17 +78,18 @@ func getKubeConfig() (*rest.Config, error) {
 }
 
 // GetNamespaces returns a list of namespaces
-func (c *Client) GetNamespaces(ctx context.Context) ([]map[string]interface{}, error) {
-	nsList, err := c.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+func (c *Client) GetNamespaces(ctx context.Context, opts metav1.ListOptions) ([]api.Namespace, error) {
+	nsList, err := c.Clientset.CoreV1().Namespaces().List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	var namespaces []map[string]interface{}
+	var namespaces []api.Namespace
 	for _, ns := range nsList.Items {
-		namespaces = append(namespaces, map[string]interface{}{
-			"name": ns.Name,
-			"age":  formatAge(ns.CreationTimestamp.Time),
+		namespaces = append(namespaces, api.Namespace{
+			Name:      ns.Name,
+			Age:       formatAge(ns.CreationTimestamp.Time),
+			CreatedAt: ns.CreationTimestamp.UTC(),
 		})
 	}
 
@@ -83,158 +97,181 @@ func (c *Client) GetNamespaces(ctx context.Context) ([]map[string]interface{}, e
 }
 
 // GetWorkloads returns a list of workloads (Deployments, StatefulSets, DaemonSets)
-func (c *Client) GetWorkloads(ctx context.Context, namespace string) ([]map[string]interface{}, error) {
-	var workloads []map[string]interface{}
+func (c *Client) GetWorkloads(ctx context.Context, namespace string, opts metav1.ListOptions) ([]api.Workload, error) {
+	var workloads []api.Workload
 
 	// Get Deployments
-	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 	for _, deploy := range deployments.Items {
-		workloads = append(workloads, map[string]interface{}{
-			"name":      deploy.Name,
-			"namespace": deploy.Namespace,
-			"kind":      "Deployment",
-			"ready":     fmt.Sprintf("%d/%d", deploy.Status.ReadyReplicas, deploy.Status.Replicas),
-			"age":       formatAge(deploy.CreationTimestamp.Time),
-		})
+		workloads = append(workloads, newWorkload(deploy.ObjectMeta, "Deployment", deploy.Status.ReadyReplicas, deploy.Status.Replicas))
 	}
 
 	// Get StatefulSets
-	statefulSets, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	statefulSets, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 	for _, sts := range statefulSets.Items {
-		workloads = append(workloads, map[string]interface{}{
-			"name":      sts.Name,
-			"namespace": sts.Namespace,
-			"kind":      "StatefulSet",
-			"ready":     fmt.Sprintf("%d/%d", sts.Status.ReadyReplicas, sts.Status.Replicas),
-			"age":       formatAge(sts.CreationTimestamp.Time),
-		})
+		workloads = append(workloads, newWorkload(sts.ObjectMeta, "StatefulSet", sts.Status.ReadyReplicas, sts.Status.Replicas))
 	}
 
 	// Get DaemonSets
-	daemonSets, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	daemonSets, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 	for _, ds := range daemonSets.Items {
-		workloads = append(workloads, map[string]interface{}{
-			"name":      ds.Name,
-			"namespace": ds.Namespace,
-			"kind":      "DaemonSet",
-			"ready":     fmt.Sprintf("%d/%d", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled),
-			"age":       formatAge(ds.CreationTimestamp.Time),
-		})
+		workloads = append(workloads, newWorkload(ds.ObjectMeta, "DaemonSet", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled))
 	}
 
 	return workloads, nil
 }
 
+func newWorkload(meta metav1.ObjectMeta, kind string, ready, desired int32) api.Workload {
+	return api.Workload{
+		Name:            meta.Name,
+		Namespace:       meta.Namespace,
+		Kind:            kind,
+		Ready:           fmt.Sprintf("%d/%d", ready, desired),
+		ReadyReplicas:   ready,
+		DesiredReplicas: desired,
+		Age:             formatAge(meta.CreationTimestamp.Time),
+		CreatedAt:       meta.CreationTimestamp.UTC(),
+	}
+}
+
 // GetPods returns a list of pods
-func (c *Client) GetPods(ctx context.Context, namespace string) ([]map[string]interface{}, error) {
+func (c *Client) GetPods(ctx context.Context, namespace string, opts metav1.ListOptions) ([]api.Pod, error) {
 	var podsList *corev1.PodList
 	var err error
 
 	if namespace == "" {
-		podsList, err = c.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+		podsList, err = c.Clientset.CoreV1().Pods("").List(ctx, opts)
 	} else {
-		podsList, err = c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		podsList, err = c.Clientset.CoreV1().Pods(namespace).List(ctx, opts)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	var pods []map[string]interface{}
+	var pods []api.Pod
 	for _, pod := range podsList.Items {
-		pods = append(pods, map[string]interface{}{
-			"name":       pod.Name,
-			"namespace":  pod.Namespace,
-			"status":     string(pod.Status.Phase),
-			"ready":      fmt.Sprintf("%d/%d", countReadyContainers(pod), len(pod.Spec.Containers)),
-			"restarts":   countRestarts(pod),
-			"age":        formatAge(pod.CreationTimestamp.Time),
-			"node":       pod.Spec.NodeName,
-			"containers": getContainerImages(pod),
-		})
+		pods = append(pods, newPod(pod))
 	}
 
 	return pods, nil
 }
 
+func newPod(pod corev1.Pod) api.Pod {
+	ready := countReadyContainers(pod)
+	return api.Pod{
+		Name:            pod.Name,
+		Namespace:       pod.Namespace,
+		Status:          podStatus(pod),
+		Phase:           string(pod.Status.Phase),
+		Ready:           fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)),
+		ReadyContainers: ready,
+		TotalContainers: len(pod.Spec.Containers),
+		Restarts:        countRestarts(pod),
+		Age:             formatAge(pod.CreationTimestamp.Time),
+		CreatedAt:       pod.CreationTimestamp.UTC(),
+		Node:            pod.Spec.NodeName,
+		Containers:      getContainerImages(pod),
+	}
+}
+
 // GetServices returns a list of services
-func (c *Client) GetServices(ctx context.Context, namespace string) ([]map[string]interface{}, error) {
+func (c *Client) GetServices(ctx context.Context, namespace string, opts metav1.ListOptions) ([]api.Service, error) {
 	var svcList *corev1.ServiceList
 	var err error
 
 	if namespace == "" {
-		svcList, err = c.Clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+		svcList, err = c.Clientset.CoreV1().Services("").List(ctx, opts)
 	} else {
-		svcList, err = c.Clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+		svcList, err = c.Clientset.CoreV1().Services(namespace).List(ctx, opts)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	var svcs []map[string]interface{}
+	var svcs []api.Service
 	for _, svc := range svcList.Items {
-		var ports []map[string]interface{}
-		for _, port := range svc.Spec.Ports {
-			ports = append(ports, map[string]interface{}{
-				"port":       port.Port,
-				"targetPort": port.TargetPort.IntVal,
-				"nodePort":   port.NodePort,
-				"protocol":   string(port.Protocol),
-			})
-		}
-
-		svcs = append(svcs, map[string]interface{}{
-			"name":       svc.Name,
-			"namespace":  svc.Namespace,
-			"type":       string(svc.Spec.Type),
-			"clusterIP":  svc.Spec.ClusterIP,
-			"externalIP": getExternalIP(svc),
-			"ports":      ports,
-			"age":        formatAge(svc.CreationTimestamp.Time),
-		})
+		svcs = append(svcs, newService(svc))
 	}
 
 	return svcs, nil
 }
 
+func newService(svc corev1.Service) api.Service {
+	var ports []api.ServicePort
+	for _, port := range svc.Spec.Ports {
+		p := api.ServicePort{
+			Port:     port.Port,
+			NodePort: port.NodePort,
+			Protocol: string(port.Protocol),
+		}
+		switch {
+		case port.TargetPort.Type == intstr.String:
+			p.TargetPortName = port.TargetPort.StrVal
+		case port.TargetPort.IntVal == 0:
+			// An unset target port defaults to the service port
+			p.TargetPort = port.Port
+		default:
+			p.TargetPort = port.TargetPort.IntVal
+		}
+		ports = append(ports, p)
+	}
+
+	return api.Service{
+		Name:       svc.Name,
+		Namespace:  svc.Namespace,
+		Type:       string(svc.Spec.Type),
+		ClusterIP:  svc.Spec.ClusterIP,
+		ExternalIP: getExternalIP(svc),
+		Ports:      ports,
+		Age:        formatAge(svc.CreationTimestamp.Time),
+		CreatedAt:  svc.CreationTimestamp.UTC(),
+	}
+}
+
 // GetNodes returns a list of nodes
-func (c *Client) GetNodes(ctx context.Context) ([]map[string]interface{}, error) {
-	nodesList, err := c.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+func (c *Client) GetNodes(ctx context.Context, opts metav1.ListOptions) ([]api.Node, error) {
+	nodesList, err := c.Clientset.CoreV1().Nodes().List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	var nodes []map[string]interface{}
+	var nodes []api.Node
 	for _, node := range nodesList.Items {
-		nodes = append(nodes, map[string]interface{}{
-			"name":             node.Name,
-			"status":           getNodeStatus(node),
-			"roles":            getRoles(node),
-			"age":              formatAge(node.CreationTimestamp.Time),
-			"version":          node.Status.NodeInfo.KubeletVersion,
-			"internalIP":       getInternalIP(node),
-			"osImage":          node.Status.NodeInfo.OSImage,
-			"containerRuntime": node.Status.NodeInfo.ContainerRuntimeVersion,
-			"kernelVersion":    node.Status.NodeInfo.KernelVersion,
-			"architecture":     node.Status.NodeInfo.Architecture,
-		})
+		nodes = append(nodes, newNode(node))
 	}
 
 	return nodes, nil
 }
 
+func newNode(node corev1.Node) api.Node {
+	return api.Node{
+		Name:             node.Name,
+		Status:           getNodeStatus(node),
+		Roles:            getRoles(node),
+		Age:              formatAge(node.CreationTimestamp.Time),
+		CreatedAt:        node.CreationTimestamp.UTC(),
+		Version:          node.Status.NodeInfo.KubeletVersion,
+		InternalIP:       getInternalIP(node),
+		OSImage:          node.Status.NodeInfo.OSImage,
+		ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+		KernelVersion:    node.Status.NodeInfo.KernelVersion,
+		Architecture:     node.Status.NodeInfo.Architecture,
+	}
+}
+
 // GetNodeMetrics returns metrics for all nodes
-func (c *Client) GetNodeMetrics(ctx context.Context) ([]map[string]interface{}, error) {
+func (c *Client) GetNodeMetrics(ctx context.Context, opts metav1.ListOptions) ([]api.NodeMetric, error) {
 	// Get node metrics from metrics server
-	metricsList, err := c.MetricsClient.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	metricsList, err := c.MetricsClient.MetricsV1beta1().NodeMetricses().List(ctx, opts)
 	if err != nil {
 		log.Printf("Error getting node metrics: %v", err)
 		return nil, fmt.Errorf("failed to get node metrics: metrics server not available or not properly configured: %w", err)
@@ -242,12 +279,13 @@ func (c *Client) GetNodeMetrics(ctx context.Context) ([]map[string]interface{},
 
 	log.Printf("Retrieved %d node metrics", len(metricsList.Items))
 
-	var metrics []map[string]interface{}
+	var metrics []api.NodeMetric
 	for _, metric := range metricsList.Items {
-		metrics = append(metrics, map[string]interface{}{
-			"name":       metric.Name,
-			"cpuUsage":   metric.Usage.Cpu().MilliValue(),
-			"memoryUsage": metric.Usage.Memory().MilliValue(),
+		metrics = append(metrics, api.NodeMetric{
+			Name:        metric.Name,
+			CPUUsage:    metric.Usage.Cpu().MilliValue(),
+			MemoryUsage: metric.Usage.Memory().Value(),
+			Timestamp:   metric.Timestamp.UTC(),
 		})
 	}
 
@@ -255,55 +293,94 @@ func (c *Client) GetNodeMetrics(ctx context.Context) ([]map[string]interface{},
 	return metrics, nil
 }
 
+// GetPodMetrics returns metrics for all pods in a namespace
+func (c *Client) GetPodMetrics(ctx context.Context, namespace string, opts metav1.ListOptions) ([]api.PodMetric, error) {
+	metricsList, err := c.MetricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: metrics server not available or not properly configured: %w", err)
+	}
+
+	var metrics []api.PodMetric
+	for _, metric := range metricsList.Items {
+		podMetric := api.PodMetric{
+			Name:      metric.Name,
+			Namespace: metric.Namespace,
+			Timestamp: metric.Timestamp.UTC(),
+		}
+		for _, container := range metric.Containers {
+			cpu := container.Usage.Cpu().MilliValue()
+			memory := container.Usage.Memory().Value()
+			podMetric.CPUUsage += cpu
+			podMetric.MemoryUsage += memory
+			podMetric.Containers = append(podMetric.Containers, api.ContainerMetric{
+				Name:        container.Name,
+				CPUUsage:    cpu,
+				MemoryUsage: memory,
+			})
+		}
+		metrics = append(metrics, podMetric)
+	}
+
+	return metrics, nil
+}
+
 // GetEvents returns a list of events
-func (c *Client) GetEvents(ctx context.Context, namespace string) ([]map[string]interface{}, error) {
+func (c *Client) GetEvents(ctx context.Context, namespace string, opts metav1.ListOptions) ([]api.Event, error) {
 	var eventList *corev1.EventList
 	var err error
 
 	if namespace == "" {
-		eventList, err = c.Clientset.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+		eventList, err = c.Clientset.CoreV1().Events("").List(ctx, opts)
 	} else {
-		eventList, err = c.Clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+		eventList, err = c.Clientset.CoreV1().Events(namespace).List(ctx, opts)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	var events []map[string]interface{}
+	var events []api.Event
 	for _, event := range eventList.Items {
-		events = append(events, map[string]interface{}{
-			"type":      event.Type,
-			"reason":    event.Reason,
-			"object":    fmt.Sprintf("%s/%s", event.InvolvedObject.Kind, event.InvolvedObject.Name),
-			"message":   event.Message,
-			"age":       formatAge(event.CreationTimestamp.Time),
-			"namespace": event.Namespace,
-		})
+		events = append(events, newEvent(event))
 	}
 
 	return events, nil
 }
 
+func newEvent(event corev1.Event) api.Event {
+	return api.Event{
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Object:    fmt.Sprintf("%s/%s", event.InvolvedObject.Kind, event.InvolvedObject.Name),
+		Message:   event.Message,
+		Count:     event.Count,
+		Age:       formatAge(event.CreationTimestamp.Time),
+		CreatedAt: event.CreationTimestamp.UTC(),
+		LastSeen:  eventTime(event).UTC(),
+		Namespace: event.Namespace,
+	}
+}
+
 // GetConfigMaps returns a list of configmaps
-func (c *Client) GetConfigMaps(ctx context.Context, namespace string) ([]map[string]interface{}, error) {
+func (c *Client) GetConfigMaps(ctx context.Context, namespace string, opts metav1.ListOptions) ([]api.ConfigMap, error) {
 	var cmList *corev1.ConfigMapList
 	var err error
 
 	if namespace == "" {
-		cmList, err = c.Clientset.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{})
+		cmList, err = c.Clientset.CoreV1().ConfigMaps("").List(ctx, opts)
 	} else {
-		cmList, err = c.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+		cmList, err = c.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, opts)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	var cms []map[string]interface{}
+	var cms []api.ConfigMap
 	for _, cm := range cmList.Items {
-		cms = append(cms, map[string]interface{}{
-			"name":      cm.Name,
-			"namespace": cm.Namespace,
-			"age":       formatAge(cm.CreationTimestamp.Time),
+		cms = append(cms, api.ConfigMap{
+			Name:      cm.Name,
+			Namespace: cm.Namespace,
+			Age:       formatAge(cm.CreationTimestamp.Time),
+			CreatedAt: cm.CreationTimestamp.UTC(),
 		})
 	}
 
@@ -311,18 +388,19 @@ func (c *Client) GetConfigMaps(ctx context.Context, namespace string) ([]map[str
 }
 
 // GetPVs returns a list of persistent volumes
-func (c *Client) GetPVs(ctx context.Context) ([]map[string]interface{}, error) {
-	pvList, err := c.Clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+func (c *Client) GetPVs(ctx context.Context, opts metav1.ListOptions) ([]api.PersistentVolume, error) {
+	pvList, err := c.Clientset.CoreV1().PersistentVolumes().List(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	var pvs []map[string]interface{}
+	var pvs []api.PersistentVolume
 	for _, pv := range pvList.Items {
-		pvs = append(pvs, map[string]interface{}{
-			"name":  pv.Name,
-			"phase": string(pv.Status.Phase),
-			"age":   formatAge(pv.CreationTimestamp.Time),
+		pvs = append(pvs, api.PersistentVolume{
+			Name:      pv.Name,
+			Phase:     string(pv.Status.Phase),
+			Age:       formatAge(pv.CreationTimestamp.Time),
+			CreatedAt: pv.CreationTimestamp.UTC(),
 		})
 	}
 
@@ -330,26 +408,27 @@ func (c *Client) GetPVs(ctx context.Context) ([]map[string]interface{}, error) {
 }
 
 // GetPVCs returns a list of persistent volume claims
-func (c *Client) GetPVCs(ctx context.Context, namespace string) ([]map[string]interface{}, error) {
+func (c *Client) GetPVCs(ctx context.Context, namespace string, opts metav1.ListOptions) ([]api.PersistentVolumeClaim, error) {
 	var pvcList *corev1.PersistentVolumeClaimList
 	var err error
 
 	if namespace == "" {
-		pvcList, err = c.Clientset.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{})
+		pvcList, err = c.Clientset.CoreV1().PersistentVolumeClaims("").List(ctx, opts)
 	} else {
-		pvcList, err = c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+		pvcList, err = c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	var pvcs []map[string]interface{}
+	var pvcs []api.PersistentVolumeClaim
 	for _, pvc := range pvcList.Items {
-		pvcs = append(pvcs, map[string]interface{}{
-			"name":      pvc.Name,
-			"namespace": pvc.Namespace,
-			"status":    string(pvc.Status.Phase),
-			"age":       formatAge(pvc.CreationTimestamp.Time),
+		pvcs = append(pvcs, api.PersistentVolumeClaim{
+			Name:      pvc.Name,
+			Namespace: pvc.Namespace,
+			Status:    string(pvc.Status.Phase),
+			Age:       formatAge(pvc.CreationTimestamp.Time),
+			CreatedAt: pvc.CreationTimestamp.UTC(),
 		})
 	}
 
@@ -446,10 +525,10 @@ func getExternalIP(svc corev1.Service) string {
 	return "<none>"
 }
 
-// GetPodLogs 获取指定Pod的日志
-func (c *Client) GetPodLogs(ctx context.Context, namespace, podName string, tailLines *int64) (string, error) {
+// GetPodLogs 获取指定Pod的日志，container 为空时由 API Server 选择容器
+func (c *Client) GetPodLogs(ctx context.Context, namespace, podName, container string, tailLines *int64) (string, error) {
 	// 设置日志获取选项
-	options := &corev1.PodLogOptions{}
+	options := &corev1.PodLogOptions{Container: container}
 	if tailLines != nil {
 		options.TailLines = tailLines
 	}
@@ -478,6 +557,15 @@ func (c *Client) GetPodLogs(ctx context.Context, namespace, podName string, tail
 	return logs.String(), nil
 }
 
+// StreamPodLogs opens a log stream of a pod; the caller must close it
+func (c *Client) StreamPodLogs(ctx context.Context, namespace, podName string, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+	stream, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(podName, options).Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log stream: %w", err)
+	}
+	return stream, nil
+}
+
 // RestartWorkload 重启工作负载
 // kind: Deployment, StatefulSet, DaemonSet
 func (c *Client) RestartWorkload(ctx context.Context, namespace, name, kind string) error {
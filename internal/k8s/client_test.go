@@ -0,0 +1,887 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"kubelens/pkg/api"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+var testCreated = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+
+func newFakeClient(t *testing.T, objects ...runtime.Object) *Client {
+	t.Helper()
+	c, err := NewFakeClient(objects...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func objectMeta(namespace, name string, labels map[string]string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels, CreationTimestamp: testCreated}
+}
+
+func testPod(namespace, name string, phase corev1.PodPhase, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: objectMeta(namespace, name, labels),
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{
+				{Name: "app", Image: "nginx:1.25"},
+				{Name: "sidecar", Image: "envoy:1.28"},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", Ready: true, RestartCount: 2},
+				{Name: "sidecar", Ready: phase == corev1.PodRunning, RestartCount: 1},
+			},
+		},
+	}
+}
+
+func testNode(name string, ready corev1.ConditionStatus, labels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: objectMeta("", name, labels),
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			Addresses:  []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}},
+			NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: "v1.28.0", Architecture: "amd64"},
+		},
+	}
+}
+
+func testDeployment(namespace, name string, ready, desired int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: objectMeta(namespace, name, nil),
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: ready, Replicas: desired},
+	}
+}
+
+func testStatefulSet(namespace, name string, ready, desired int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: objectMeta(namespace, name, nil),
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: ready, Replicas: desired},
+	}
+}
+
+func testDaemonSet(namespace, name string, ready, desired int32) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: objectMeta(namespace, name, nil),
+		Status:     appsv1.DaemonSetStatus{NumberReady: ready, DesiredNumberScheduled: desired},
+	}
+}
+
+func testService(namespace, name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: objectMeta(namespace, name, map[string]string{"app": name}),
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP: "10.96.0.10",
+			Ports:     []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP}},
+		},
+	}
+}
+
+// keys returns the sorted keys of items; fake clientsets list in map order
+func keys[T any](items []T, key func(T) string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, key(item))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGetNamespaces(t *testing.T) {
+	c := newFakeClient(t,
+		&corev1.Namespace{ObjectMeta: objectMeta("", "default", nil)},
+		&corev1.Namespace{ObjectMeta: objectMeta("", "shop", map[string]string{"team": "shop"})},
+	)
+	tests := []struct {
+		name string
+		opts metav1.ListOptions
+		want []string
+	}{
+		{"all", metav1.ListOptions{}, []string{"default", "shop"}},
+		{"label selector", metav1.ListOptions{LabelSelector: "team=shop"}, []string{"shop"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetNamespaces(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := keys(got, func(n api.Namespace) string { return n.Name }); !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+			for _, ns := range got {
+				if ns.Age != "2h" || !ns.CreatedAt.Equal(testCreated.Time.UTC()) {
+					t.Errorf("%s: age %q createdAt %v", ns.Name, ns.Age, ns.CreatedAt)
+				}
+			}
+		})
+	}
+}
+
+func TestGetWorkloads(t *testing.T) {
+	c := newFakeClient(t,
+		testDeployment("shop", "web", 2, 3),
+		testStatefulSet("shop", "db", 1, 1),
+		testDaemonSet("kube-system", "proxy", 4, 5),
+	)
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{"all namespaces", "", []string{"DaemonSet/kube-system/proxy 4/5", "Deployment/shop/web 2/3", "StatefulSet/shop/db 1/1"}},
+		{"namespace", "shop", []string{"Deployment/shop/web 2/3", "StatefulSet/shop/db 1/1"}},
+		{"empty namespace", "other", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetWorkloads(context.Background(), tt.namespace, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := keys(got, func(w api.Workload) string {
+				return w.Kind + "/" + w.Namespace + "/" + w.Name + " " + w.Ready
+			})
+			if !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPods(t *testing.T) {
+	c := newFakeClient(t,
+		testPod("shop", "web-1", corev1.PodRunning, map[string]string{"app": "web"}),
+		testPod("shop", "db-0", corev1.PodPending, map[string]string{"app": "db"}),
+		testPod("kube-system", "dns", corev1.PodRunning, nil),
+	)
+	tests := []struct {
+		name      string
+		namespace string
+		opts      metav1.ListOptions
+		want      []string
+	}{
+		{"all namespaces", "", metav1.ListOptions{}, []string{"kube-system/dns", "shop/db-0", "shop/web-1"}},
+		{"namespace", "shop", metav1.ListOptions{}, []string{"shop/db-0", "shop/web-1"}},
+		{"label selector", "", metav1.ListOptions{LabelSelector: "app=web"}, []string{"shop/web-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetPods(context.Background(), tt.namespace, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := keys(got, func(p api.Pod) string { return p.Namespace + "/" + p.Name }); !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	pods, err := c.GetPods(context.Background(), "shop", metav1.ListOptions{LabelSelector: "app=db"})
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("pods %v, err %v", pods, err)
+	}
+	p := pods[0]
+	if p.Status != "Pending" || p.Ready != "1/2" || p.ReadyContainers != 1 || p.TotalContainers != 2 ||
+		p.Restarts != 3 || p.Node != "node-1" || !equalStrings(p.Containers, []string{"nginx:1.25", "envoy:1.28"}) {
+		t.Errorf("unexpected pod %+v", p)
+	}
+}
+
+func TestGetServices(t *testing.T) {
+	lb := testService("shop", "gateway")
+	lb.Spec.Type = corev1.ServiceTypeLoadBalancer
+	lb.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.7"}}
+	c := newFakeClient(t, testService("shop", "web"), lb, testService("default", "kubernetes"))
+
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{"all namespaces", "", []string{"default/kubernetes ClusterIP <none>", "shop/gateway LoadBalancer 203.0.113.7", "shop/web ClusterIP <none>"}},
+		{"namespace", "shop", []string{"shop/gateway LoadBalancer 203.0.113.7", "shop/web ClusterIP <none>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetServices(context.Background(), tt.namespace, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := keys(got, func(s api.Service) string {
+				return s.Namespace + "/" + s.Name + " " + s.Type + " " + s.ExternalIP
+			})
+			if !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+			for _, svc := range got {
+				if len(svc.Ports) != 1 || svc.Ports[0].Port != 80 || svc.Ports[0].TargetPort != 8080 || svc.Ports[0].Protocol != "TCP" {
+					t.Errorf("%s: ports %+v", svc.Name, svc.Ports)
+				}
+			}
+		})
+	}
+}
+
+func TestGetNodes(t *testing.T) {
+	c := newFakeClient(t,
+		testNode("cp-1", corev1.ConditionTrue, map[string]string{"node-role.kubernetes.io/control-plane": ""}),
+		testNode("worker-1", corev1.ConditionTrue, nil),
+		testNode("worker-2", corev1.ConditionFalse, nil),
+		&corev1.Node{ObjectMeta: objectMeta("", "worker-3", nil)},
+	)
+	tests := []struct {
+		name string
+		opts metav1.ListOptions
+		want []string
+	}{
+		{"all", metav1.ListOptions{}, []string{
+			"cp-1 Ready control-plane 10.0.0.1",
+			"worker-1 Ready <none> 10.0.0.1",
+			"worker-2 NotReady <none> 10.0.0.1",
+			"worker-3 Unknown <none> <none>",
+		}},
+		{"label selector", metav1.ListOptions{LabelSelector: "node-role.kubernetes.io/control-plane"}, []string{
+			"cp-1 Ready control-plane 10.0.0.1",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetNodes(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := keys(got, func(n api.Node) string {
+				return n.Name + " " + n.Status + " " + n.Roles + " " + n.InternalIP
+			})
+			if !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetNodeMetrics(t *testing.T) {
+	c := newFakeClient(t,
+		&metricsv1beta1.NodeMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+			Usage: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1500m"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			},
+		},
+	)
+	got, err := c.GetNodeMetrics(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "worker-1" || got[0].CPUUsage != 1500 || got[0].MemoryUsage != 2<<30 {
+		t.Errorf("unexpected metrics %+v", got)
+	}
+}
+
+func TestGetPodMetrics(t *testing.T) {
+	usage := func(cpu, memory string) corev1.ResourceList {
+		return corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}
+	}
+	c := newFakeClient(t,
+		&metricsv1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-1"},
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{Name: "app", Usage: usage("200m", "128Mi")},
+				{Name: "sidecar", Usage: usage("50m", "32Mi")},
+			},
+		},
+		&metricsv1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "dns"},
+			Containers: []metricsv1beta1.ContainerMetrics{{Name: "coredns", Usage: usage("10m", "16Mi")}},
+		},
+	)
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{"all namespaces", "", []string{"kube-system/dns", "shop/web-1"}},
+		{"namespace", "shop", []string{"shop/web-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetPodMetrics(context.Background(), tt.namespace, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := keys(got, func(m api.PodMetric) string { return m.Namespace + "/" + m.Name }); !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+			for _, m := range got {
+				if m.Name == "web-1" && (m.CPUUsage != 250 || m.MemoryUsage != 160<<20 || len(m.Containers) != 2) {
+					t.Errorf("unexpected web-1 metrics %+v", m)
+				}
+			}
+		})
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	lastSeen := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	c := newFakeClient(t,
+		&corev1.Event{
+			ObjectMeta:     objectMeta("shop", "web-1.1", nil),
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-1"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+			Count:          5,
+			LastTimestamp:  lastSeen,
+		},
+		&corev1.Event{
+			ObjectMeta:     objectMeta("default", "node.1", nil),
+			InvolvedObject: corev1.ObjectReference{Kind: "Node", Name: "worker-1"},
+			Type:           corev1.EventTypeNormal,
+			Reason:         "Starting",
+		},
+	)
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{"all namespaces", "", []string{"default Normal Starting Node/worker-1", "shop Warning BackOff Pod/web-1"}},
+		{"namespace", "shop", []string{"shop Warning BackOff Pod/web-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetEvents(context.Background(), tt.namespace, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := keys(got, func(e api.Event) string {
+				return e.Namespace + " " + e.Type + " " + e.Reason + " " + e.Object
+			})
+			if !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+			for _, e := range got {
+				if e.Reason == "BackOff" && (e.Count != 5 || !e.LastSeen.Equal(lastSeen.Time)) {
+					t.Errorf("unexpected event %+v", e)
+				}
+			}
+		})
+	}
+}
+
+func TestGetConfigMaps(t *testing.T) {
+	c := newFakeClient(t,
+		&corev1.ConfigMap{ObjectMeta: objectMeta("shop", "web-config", nil)},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("kube-system", "coredns", nil)},
+	)
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{"all namespaces", "", []string{"kube-system/coredns", "shop/web-config"}},
+		{"namespace", "shop", []string{"shop/web-config"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetConfigMaps(context.Background(), tt.namespace, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := keys(got, func(cm api.ConfigMap) string { return cm.Namespace + "/" + cm.Name }); !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPVs(t *testing.T) {
+	c := newFakeClient(t,
+		&corev1.PersistentVolume{ObjectMeta: objectMeta("", "pv-1", nil), Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound}},
+		&corev1.PersistentVolume{ObjectMeta: objectMeta("", "pv-2", nil), Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased}},
+	)
+	got, err := c.GetPVs(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"pv-1 Bound", "pv-2 Released"}
+	if names := keys(got, func(pv api.PersistentVolume) string { return pv.Name + " " + pv.Phase }); !equalStrings(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+func TestGetPVCs(t *testing.T) {
+	c := newFakeClient(t,
+		&corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("shop", "data-db-0", nil), Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("ci", "cache", nil), Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending}},
+	)
+	tests := []struct {
+		name      string
+		namespace string
+		want      []string
+	}{
+		{"all namespaces", "", []string{"ci/cache Pending", "shop/data-db-0 Bound"}},
+		{"namespace", "ci", []string{"ci/cache Pending"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetPVCs(context.Background(), tt.namespace, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := keys(got, func(pvc api.PersistentVolumeClaim) string {
+				return pvc.Namespace + "/" + pvc.Name + " " + pvc.Status
+			})
+			if !equalStrings(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodLogs(t *testing.T) {
+	c := newFakeClient(t, testPod("shop", "web-1", corev1.PodRunning, nil))
+	tail := int64(10)
+	logs, err := c.GetPodLogs(context.Background(), "shop", "web-1", "", &tail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake clientset serves a fixed body for every log request
+	if logs != "fake logs" {
+		t.Errorf("logs = %q", logs)
+	}
+}
+
+func TestRestartWorkload(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		wantErr bool
+	}{
+		{"deployment", "Deployment", false},
+		{"statefulset", "StatefulSet", false},
+		{"daemonset", "DaemonSet", false},
+		{"unsupported kind", "ReplicaSet", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient(t,
+				testDeployment("shop", "web", 1, 1),
+				testStatefulSet("shop", "web", 1, 1),
+				testDaemonSet("shop", "web", 1, 1),
+			)
+			err := c.RestartWorkload(context.Background(), "shop", "web", tt.kind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			annotations := restartedTemplateAnnotations(t, c, tt.kind)
+			restartedAt, ok := annotations["kubectl.kubernetes.io/restartedAt"]
+			if !ok {
+				t.Fatalf("restartedAt annotation missing: %v", annotations)
+			}
+			if _, err := time.Parse(time.RFC3339, restartedAt); err != nil {
+				t.Errorf("restartedAt %q is not RFC3339: %v", restartedAt, err)
+			}
+		})
+	}
+
+	t.Run("missing workload", func(t *testing.T) {
+		c := newFakeClient(t)
+		if err := c.RestartWorkload(context.Background(), "shop", "web", "Deployment"); err == nil {
+			t.Error("expected error for missing deployment")
+		}
+	})
+}
+
+func restartedTemplateAnnotations(t *testing.T, c *Client, kind string) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	apps := c.Clientset.AppsV1()
+	switch kind {
+	case "Deployment":
+		obj, err := apps.Deployments("shop").Get(ctx, "web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.Spec.Template.Annotations
+	case "StatefulSet":
+		obj, err := apps.StatefulSets("shop").Get(ctx, "web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.Spec.Template.Annotations
+	default:
+		obj, err := apps.DaemonSets("shop").Get(ctx, "web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.Spec.Template.Annotations
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: objectMeta("shop", "db-password", nil)}
+	secret.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": `{"data":{"password":"aHVudGVyMg=="}}`}
+	cm := &corev1.ConfigMap{ObjectMeta: objectMeta("shop", "web-config", nil)}
+	cm.Annotations = map[string]string{"owner": "payments-team"}
+	c := newFakeClient(t, secret, cm)
+	fake := c.Clientset.(*kubefake.Clientset)
+
+	// Secret annotations are not searchable, they may hold the secret's data
+	results, err := c.Search(context.Background(), "kind:secret aHVudGVyMg", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("secret data matched: %+v", results)
+	}
+
+	// Later searches reuse the index instead of listing every kind again
+	listed := len(fake.Actions())
+	results, err = c.Search(context.Background(), "payments", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "web-config" {
+		t.Errorf("annotation search %+v", results)
+	}
+	if len(fake.Actions()) != listed {
+		t.Errorf("second search listed again: %d actions after %d", len(fake.Actions()), listed)
+	}
+}
+
+func TestSearchIndexRefresh(t *testing.T) {
+	c := newFakeClient(t, testPod("shop", "web-1", corev1.PodRunning, nil))
+	fake := c.Clientset.(*kubefake.Clientset)
+	listing, release := make(chan struct{}), make(chan struct{})
+	fake.PrependReactor("list", "namespaces", func(clienttesting.Action) (bool, runtime.Object, error) {
+		close(listing)
+		<-release
+		return false, nil, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if results, err := c.Search(context.Background(), "web", "", 0); err != nil || len(results) != 1 {
+				t.Errorf("search: %v, %+v", err, results)
+			}
+		}()
+	}
+	<-listing
+
+	// A search that gives up while the index is refreshed returns at once
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Search(ctx, "web", "", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled search error %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	lists := 0
+	for _, action := range fake.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "namespaces" {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Errorf("concurrent searches listed namespaces %d times", lists)
+	}
+}
+
+func TestGetPodDetail(t *testing.T) {
+	controller := true
+	cron := &batchv1.CronJob{ObjectMeta: objectMeta("shop", "report", nil)}
+	cron.UID = "cron-uid"
+	job := &batchv1.Job{ObjectMeta: objectMeta("shop", "report-28000", nil)}
+	job.UID = "job-uid"
+	job.OwnerReferences = []metav1.OwnerReference{{Kind: "CronJob", Name: "report", UID: "cron-uid", Controller: &controller}}
+	pod := testPod("shop", "report-28000-x1", corev1.PodRunning, nil)
+	pod.UID = "pod-uid"
+	pod.OwnerReferences = []metav1.OwnerReference{
+		{Kind: "ConfigMap", Name: "not-a-controller", UID: "cm-uid"},
+		{Kind: "Job", Name: "report-28000", UID: "job-uid", Controller: &controller},
+	}
+
+	event := func(name, reason string, uid types.UID, seen time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     objectMeta("shop", name, nil),
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: pod.Name, UID: uid},
+			Reason:         reason,
+			LastTimestamp:  metav1.NewTime(seen),
+		}
+	}
+	now := time.Now()
+	other := event("other", "Scheduled", "", now)
+	other.InvolvedObject.Name = "web-1"
+
+	c := newFakeClient(t, cron, job, pod,
+		event("pulled", "Pulled", "pod-uid", now.Add(-time.Minute)),
+		event("scheduled", "Scheduled", "pod-uid", now.Add(-2*time.Minute)),
+		// An event of an earlier pod with the same name
+		event("stale", "Killing", "old-uid", now),
+		other,
+	)
+	detail, err := c.GetPodDetail(context.Background(), "shop", pod.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The chain follows controllers only, from the pod upwards
+	var owners []string
+	for _, o := range detail.Owners {
+		owners = append(owners, o.Kind+"/"+o.Name)
+	}
+	if !equalStrings(owners, []string{"Job/report-28000", "CronJob/report"}) {
+		t.Errorf("owner chain %v", owners)
+	}
+	// Oldest first, without the events of other pods
+	var reasons []string
+	for _, e := range detail.Events {
+		reasons = append(reasons, e.Reason)
+	}
+	if !equalStrings(reasons, []string{"Scheduled", "Pulled"}) {
+		t.Errorf("events %v", reasons)
+	}
+	if len(detail.Containers) != 2 || detail.Containers[0].Name != "app" || detail.Status != "Running" {
+		t.Errorf("detail %+v", detail)
+	}
+
+	if _, err := c.GetPodDetail(context.Background(), "shop", "missing"); !apierrors.IsNotFound(err) {
+		t.Errorf("missing pod error %v", err)
+	}
+
+	// A deleted owner ends the chain, any other error fails the detail
+	if err := c.Clientset.BatchV1().Jobs("shop").Delete(context.Background(), job.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if detail, err := c.GetPodDetail(context.Background(), "shop", pod.Name); err != nil || len(detail.Owners) != 1 {
+		t.Errorf("deleted owner: %v, %+v", err, detail)
+	}
+	c.Clientset.(*kubefake.Clientset).PrependReactor("get", "jobs", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(batchv1.Resource("jobs"), job.Name, errors.New("denied"))
+	})
+	if _, err := c.GetPodDetail(context.Background(), "shop", pod.Name); !apierrors.IsForbidden(err) {
+		t.Errorf("forbidden owner error %v", err)
+	}
+}
+
+func TestGetResourceGraph(t *testing.T) {
+	controller := true
+	deploy := testDeployment("shop", "web", 1, 1)
+	deploy.UID = "deploy-uid"
+	rs := &appsv1.ReplicaSet{ObjectMeta: objectMeta("shop", "web-abc", nil)}
+	rs.UID = "rs-uid"
+	rs.OwnerReferences = []metav1.OwnerReference{{Kind: "Deployment", Name: "web", UID: "deploy-uid", Controller: &controller}}
+	pod := testPod("shop", "web-abc-1", corev1.PodRunning, map[string]string{"app": "web"})
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-abc", UID: "rs-uid", Controller: &controller}}
+	pod.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}}}
+	pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	pod.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}}}}
+	pod.Spec.EphemeralContainers = []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+		Name: "debugger",
+		Env:  []corev1.EnvVar{{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "debug-token"}}}}},
+	}}}
+	svc := testService("shop", "web")
+	svc.Spec.Selector = map[string]string{"app": "web"}
+	ing := &networkingv1.Ingress{ObjectMeta: objectMeta("shop", "web", nil), Spec: networkingv1.IngressSpec{
+		DefaultBackend: &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web"}},
+		TLS:            []networkingv1.IngressTLS{{SecretName: "web-tls"}},
+	}}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("shop", "data", nil), Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv-1"}}
+
+	c := newFakeClient(t, deploy, rs, pod, svc, ing, pvc,
+		&corev1.PersistentVolume{ObjectMeta: objectMeta("", "pv-1", nil)},
+		&corev1.PersistentVolume{ObjectMeta: objectMeta("", "pv-unrelated", nil)},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("shop", "web-config", nil)},
+		testPod("other", "elsewhere", corev1.PodRunning, map[string]string{"app": "web"}),
+	)
+	graph, err := c.GetResourceGraph(context.Background(), "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := keys(graph.Edges, func(e api.GraphEdge) string { return e.Source + " " + e.Type + " " + e.Target })
+	want := []string{
+		"Deployment/shop/web owns ReplicaSet/shop/web-abc",
+		"Ingress/shop/web routes Service/shop/web",
+		"Ingress/shop/web tls Secret/shop/web-tls",
+		"PersistentVolumeClaim/shop/data binds PersistentVolume/pv-1",
+		"Pod/shop/web-abc-1 env ConfigMap/shop/web-config",
+		"Pod/shop/web-abc-1 env Secret/shop/debug-token",
+		"Pod/shop/web-abc-1 imagePullSecret Secret/shop/registry",
+		"Pod/shop/web-abc-1 mounts PersistentVolumeClaim/shop/data",
+		"ReplicaSet/shop/web-abc owns Pod/shop/web-abc-1",
+		"Service/shop/web selects Pod/shop/web-abc-1",
+	}
+	if !equalStrings(edges, want) {
+		t.Errorf("edges\n%v\nwant\n%v", edges, want)
+	}
+	statuses := make(map[string]string)
+	for _, node := range graph.Nodes {
+		statuses[node.ID] = node.Status
+	}
+	if statuses["Secret/shop/registry"] != "Missing" || statuses["Deployment/shop/web"] != "1/1" {
+		t.Errorf("node statuses %v", statuses)
+	}
+	if _, ok := statuses["PersistentVolume/pv-unrelated"]; ok {
+		t.Error("unreferenced PVs are not part of a namespace graph")
+	}
+	if _, ok := statuses["Pod/other/elsewhere"]; ok {
+		t.Error("pods of other namespaces are not part of the graph")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	payment := testDeployment("shop", "payment", 1, 1)
+	payment.Labels = map[string]string{"app": "payment", "tier": "backend"}
+	api1 := testPod("shop", "payment-api-7d9f", corev1.PodRunning, map[string]string{"app": "payment", "tier": "backend"})
+	gateway := testService("shop", "gateway")
+	gateway.Labels = map[string]string{"routes-to": "payment"}
+	gateway.Annotations = map[string]string{"description": "fronts the payment api"}
+	c := newFakeClient(t, payment, api1, gateway,
+		testPod("staging", "payment-api-1b2c", corev1.PodRunning, map[string]string{"app": "payment"}),
+		testNode("node-1", corev1.ConditionTrue, nil),
+	)
+
+	tests := []struct {
+		q, labelSelector string
+		want             []string
+	}{
+		// Exact name beats prefix, prefix beats label value; ties go by kind, namespace and name
+		{"payment", "", []string{"deployment/shop/payment", "pod/shop/payment-api-7d9f", "pod/staging/payment-api-1b2c", "service/shop/gateway"}},
+		{"kind:pod payment", "", []string{"pod/shop/payment-api-7d9f", "pod/staging/payment-api-1b2c"}},
+		{"kind:po,deploy ns:shop payment", "", []string{"deployment/shop/payment", "pod/shop/payment-api-7d9f"}},
+		// Every term must match
+		{"payment api", "", []string{"pod/shop/payment-api-7d9f", "pod/staging/payment-api-1b2c", "service/shop/gateway"}},
+		{"tier=backend", "", []string{"deployment/shop/payment", "pod/shop/payment-api-7d9f"}},
+		{"payment", "tier=backend,app", []string{"deployment/shop/payment", "pod/shop/payment-api-7d9f"}},
+		{"kind:node", "", []string{"node/node-1"}},
+		{"nothing-matches", "", nil},
+	}
+	for _, tt := range tests {
+		results, err := c.Search(context.Background(), tt.q, tt.labelSelector, 0)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.q, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Key)
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("Search(%q, %q) = %v, want %v", tt.q, tt.labelSelector, got, tt.want)
+		}
+	}
+
+	results, err := c.Search(context.Background(), "payment", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Score != 100 || results[0].Link != "/workloads?name=payment&namespace=shop" {
+		t.Errorf("limited results %+v", results)
+	}
+	for _, q := range []string{"kind:widget", "a==b=c"} {
+		if _, err := c.Search(context.Background(), q, "", 0); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Search(%q) error %v", q, err)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c := newFakeClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.Watch(ctx, "po", "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods := c.Clientset.CoreV1().Pods("shop")
+	pod := testPod("shop", "web-1", corev1.PodPending, nil)
+	if _, err := pods.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pod.Status.Phase = corev1.PodRunning
+	if _, err := pods.Update(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case ev := <-events:
+			var item api.Pod
+			if err := json.Unmarshal(ev.Object, &item); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, ev.Type+" "+ev.Kind+" "+item.Name+" "+item.Phase)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %v", got)
+		}
+	}
+	want := []string{"ADDED Pod web-1 Pending", "MODIFIED Pod web-1 Running", "DELETED Pod web-1 Running"}
+	if !equalStrings(got, want) {
+		t.Errorf("events %v, want %v", got, want)
+	}
+
+	// The channel closes with the context
+	cancel()
+	for range events {
+	}
+
+	if _, err := c.Watch(context.Background(), "configmaps", ""); !errors.Is(err, ErrUnsupportedKind) {
+		t.Errorf("unsupported kind error %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package k8s
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Groupings of a cost rollup
+const (
+	CostByNamespace = "namespace"
+	CostByWorkload  = "workload"
+	CostByLabel     = "label"
+)
+
+// unallocatedLabel groups the costs of workloads without the chosen label
+const unallocatedLabel = "__unallocated__"
+
+// hoursPerMonth projects hourly costs to a month, as cloud price lists do
+const hoursPerMonth = 730
+
+const gib = 1 << 30
+
+// DefaultCostModel is used when no COST_MODEL file is configured; the prices
+// are typical on-demand list prices of general purpose cloud VMs
+var DefaultCostModel = api.CostModel{
+	Currency:       "USD",
+	CPUHourly:      0.031611,
+	MemoryGBHourly: 0.004237,
+}
+
+// CostStore persists daily workload costs
+type CostStore interface {
+	AddDailyCosts(ctx context.Context, costs []api.WorkloadCost) error
+	ListDailyCosts(ctx context.Context, from, to time.Time) ([]api.WorkloadCost, error)
+}
+
+// LoadCostModel reads a cost model from a JSON file
+func LoadCostModel(path string) (api.CostModel, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return api.CostModel{}, err
+	}
+	model := DefaultCostModel
+	model.Tiers = nil
+	if err := json.Unmarshal(raw, &model); err != nil {
+		return api.CostModel{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if model.CPUHourly <= 0 || model.MemoryGBHourly <= 0 {
+		return api.CostModel{}, fmt.Errorf("%s: cpuHourly and memoryGBHourly must be positive", path)
+	}
+	return model, nil
+}
+
+// nodePrices returns the price of a core-hour and a GiB-hour on node, from the
+// first tier selecting it or the model's default prices
+func nodePrices(model api.CostModel, node corev1.Node) (cpu, memory float64) {
+	cpu, memory = model.CPUHourly, model.MemoryGBHourly
+	for _, tier := range model.Tiers {
+		if !labels.SelectorFromSet(tier.NodeSelector).Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		if tier.CPUHourly > 0 {
+			cpu = tier.CPUHourly
+		}
+		if tier.MemoryGBHourly > 0 {
+			memory = tier.MemoryGBHourly
+		}
+		if tier.NodeHourly > 0 {
+			cores := float64(node.Status.Allocatable.Cpu().MilliValue()) / 1000
+			gigabytes := float64(node.Status.Allocatable.Memory().Value()) / gib
+			if list := cores*cpu + gigabytes*memory; list > 0 {
+				scale := tier.NodeHourly / list
+				cpu, memory = cpu*scale, memory*scale
+			}
+		}
+		break
+	}
+	return cpu, memory
+}
+
+// AllocateCosts returns the hourly cost of every workload with scheduled pods.
+// A pod is charged for the larger of its request and its usage, so idle
+// reservations cost as much as busy ones and bursting above requests is not free.
+func (c *Client) AllocateCosts(ctx context.Context, model api.CostModel) ([]api.WorkloadCost, error) {
+	nodeList, err := c.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podList, err := c.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	owners, err := c.workloadOwners(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	// Without a metrics server pods are charged for their requests only
+	usage := make(map[string]api.PodMetric)
+	if metrics, err := c.GetPodMetrics(ctx, "", metav1.ListOptions{}); err == nil {
+		for _, m := range metrics {
+			usage[m.Namespace+"/"+m.Name] = m
+		}
+	}
+
+	type prices struct{ cpu, memory float64 }
+	nodes := make(map[string]prices, len(nodeList.Items))
+	for _, node := range nodeList.Items {
+		cpu, memory := nodePrices(model, node)
+		nodes[node.Name] = prices{cpu, memory}
+	}
+
+	costs := make(map[string]*api.WorkloadCost)
+	for _, pod := range podList.Items {
+		price, ok := nodes[pod.Spec.NodeName]
+		if !ok || !activePod(pod) {
+			continue
+		}
+		requests := podRequests(pod)
+		metric := usage[pod.Namespace+"/"+pod.Name]
+		cores := math.Max(float64(requests.Cpu().MilliValue()), float64(metric.CPUUsage)) / 1000
+		gigabytes := math.Max(float64(requests.Memory().Value()), float64(metric.MemoryUsage)) / gib
+
+		kind, name := podWorkload(pod, owners)
+		key := pod.Namespace + "/" + kind + "/" + name
+		cost := costs[key]
+		if cost == nil {
+			cost = &api.WorkloadCost{Namespace: pod.Namespace, WorkloadKind: kind, Workload: name, Labels: pod.Labels}
+			costs[key] = cost
+		}
+		cost.Pods++
+		cost.CPUCoreHours += cores
+		cost.MemoryGBHours += gigabytes
+		cost.CPUCost += cores * price.cpu
+		cost.MemoryCost += gigabytes * price.memory
+	}
+
+	result := make([]api.WorkloadCost, 0, len(costs))
+	for _, cost := range costs {
+		result = append(result, *cost)
+	}
+	sort.Slice(result, func(i, j int) bool { return workloadCostKey(result[i]) < workloadCostKey(result[j]) })
+	return result, nil
+}
+
+func workloadCostKey(cost api.WorkloadCost) string {
+	return cost.Namespace + "/" + cost.WorkloadKind + "/" + cost.Workload
+}
+
+// RunCostRecorder adds the cost of every workload over each interval to its
+// daily total until ctx is done
+func (c *Client) RunCostRecorder(ctx context.Context, store CostStore, model api.CostModel, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		costs, err := c.AllocateCosts(ctx, model)
+		if err != nil {
+			log.Printf("[warn] cost recorder: %v", err)
+			continue
+		}
+		day := time.Now().UTC().Format("2006-01-02")
+		hours := interval.Hours()
+		for i := range costs {
+			costs[i].Date = day
+			costs[i].CPUCoreHours *= hours
+			costs[i].MemoryGBHours *= hours
+			costs[i].CPUCost *= hours
+			costs[i].MemoryCost *= hours
+		}
+		if err := store.AddDailyCosts(ctx, costs); err != nil {
+			log.Printf("[warn] cost recorder: %v", err)
+		}
+	}
+}
+
+// GroupCosts rolls workload costs up by namespace, workload or the value of a
+// label, keeping days apart
+func GroupCosts(costs []api.WorkloadCost, groupBy, label string) ([]api.CostAllocation, error) {
+	var group func(api.WorkloadCost) string
+	switch groupBy {
+	case "", CostByNamespace:
+		group = func(cost api.WorkloadCost) string { return cost.Namespace }
+	case CostByWorkload:
+		group = workloadCostKey
+	case CostByLabel:
+		if label == "" {
+			return nil, fmt.Errorf("groupBy=label needs a label")
+		}
+		group = func(cost api.WorkloadCost) string {
+			if value, ok := cost.Labels[label]; ok {
+				return value
+			}
+			return unallocatedLabel
+		}
+	default:
+		return nil, fmt.Errorf("unsupported groupBy: %s", groupBy)
+	}
+
+	allocations := make(map[string]*api.CostAllocation)
+	var order []string
+	for _, cost := range costs {
+		name := group(cost)
+		key := cost.Date + "/" + name
+		allocation := allocations[key]
+		if allocation == nil {
+			allocation = &api.CostAllocation{Group: name, Date: cost.Date}
+			allocations[key] = allocation
+			order = append(order, key)
+		}
+		allocation.Pods += cost.Pods
+		allocation.CPUCoreHours += cost.CPUCoreHours
+		allocation.MemoryGBHours += cost.MemoryGBHours
+		allocation.CPUCost += cost.CPUCost
+		allocation.MemoryCost += cost.MemoryCost
+	}
+
+	result := make([]api.CostAllocation, 0, len(order))
+	for _, key := range order {
+		allocation := allocations[key]
+		allocation.TotalCost = allocation.CPUCost + allocation.MemoryCost
+		if allocation.Date == "" {
+			allocation.MonthlyCost = allocation.TotalCost * hoursPerMonth
+		}
+		result = append(result, *allocation)
+	}
+	return result, nil
+}
+
+// WriteCostsCSV writes cost allocations as CSV with a header row
+func WriteCostsCSV(w io.Writer, allocations []api.CostAllocation) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"date", "group", "pods", "cpu_core_hours", "memory_gb_hours", "cpu_cost", "memory_cost", "total_cost"}); err != nil {
+		return err
+	}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, a := range allocations {
+		if err := out.Write([]string{a.Date, a.Group, strconv.Itoa(a.Pods), format(a.CPUCoreHours), format(a.MemoryGBHours),
+			format(a.CPUCost), format(a.MemoryCost), format(a.TotalCost)}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
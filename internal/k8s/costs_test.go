@@ -0,0 +1,176 @@
+package k8s
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// memoryCostStore keeps daily costs in memory for tests
+type memoryCostStore struct {
+	costs []api.WorkloadCost
+}
+
+func (m *memoryCostStore) AddDailyCosts(ctx context.Context, costs []api.WorkloadCost) error {
+	m.costs = append(m.costs, costs...)
+	return nil
+}
+
+func (m *memoryCostStore) ListDailyCosts(ctx context.Context, from, to time.Time) ([]api.WorkloadCost, error) {
+	var costs []api.WorkloadCost
+	for _, cost := range m.costs {
+		if cost.Date >= from.Format("2006-01-02") && cost.Date <= to.Format("2006-01-02") {
+			costs = append(costs, cost)
+		}
+	}
+	return costs, nil
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestNodePrices(t *testing.T) {
+	model := api.CostModel{CPUHourly: 0.04, MemoryGBHourly: 0.005, Tiers: []api.PriceTier{
+		{Name: "spot", NodeSelector: map[string]string{"lifecycle": "spot"}, CPUHourly: 0.01},
+		{Name: "dedicated", NodeSelector: map[string]string{"pool": "db"}, NodeHourly: 0.36},
+	}}
+	node := func(labels map[string]string) corev1.Node {
+		n := testNode("n", corev1.ConditionTrue, labels)
+		n.Status.Allocatable = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("40Gi")}
+		return *n
+	}
+
+	if cpu, memory := nodePrices(model, node(nil)); cpu != 0.04 || memory != 0.005 {
+		t.Errorf("default prices %v %v", cpu, memory)
+	}
+	if cpu, memory := nodePrices(model, node(map[string]string{"lifecycle": "spot"})); cpu != 0.01 || memory != 0.005 {
+		t.Errorf("spot prices %v %v", cpu, memory)
+	}
+	// 4 cores and 40GiB list at 0.16 + 0.2 = 0.36, so the node price keeps the list prices
+	cpu, memory := nodePrices(model, node(map[string]string{"pool": "db"}))
+	if !closeTo(cpu, 0.04) || !closeTo(memory, 0.005) {
+		t.Errorf("node priced tier %v %v", cpu, memory)
+	}
+	model.Tiers[1].NodeHourly = 0.72
+	if cpu, memory := nodePrices(model, node(map[string]string{"pool": "db"})); !closeTo(cpu, 0.08) || !closeTo(memory, 0.01) {
+		t.Errorf("doubled node price %v %v", cpu, memory)
+	}
+}
+
+func TestAllocateCosts(t *testing.T) {
+	node := schedulingNode("node-1", "a", "4", nil)
+	busy := requesting(testPod("shop", "busy", corev1.PodRunning, map[string]string{"team": "checkout"}), "500m")
+	idle := requesting(testPod("shop", "idle", corev1.PodRunning, nil), "2")
+	done := requesting(testPod("shop", "done", corev1.PodSucceeded, nil), "2")
+	pending := requesting(testPod("shop", "pending", corev1.PodPending, nil), "2")
+	pending.Spec.NodeName = ""
+	usage := &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "busy"},
+		Containers: []metricsv1beta1.ContainerMetrics{{Name: "app", Usage: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("1500m"), corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}}},
+	}
+	c := newFakeClient(t, node, busy, idle, done, pending, usage)
+	model := api.CostModel{CPUHourly: 0.04, MemoryGBHourly: 0.005}
+
+	costs, err := c.AllocateCosts(context.Background(), model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costs) != 2 {
+		t.Fatalf("costs %+v", costs)
+	}
+	// busy uses more than it requests, idle requests more than it uses
+	want := map[string]float64{"busy": 1.5, "idle": 2}
+	for _, cost := range costs {
+		if !closeTo(cost.CPUCoreHours, want[cost.Workload]) || !closeTo(cost.CPUCost, want[cost.Workload]*0.04) {
+			t.Errorf("%s: %+v", cost.Workload, cost)
+		}
+	}
+	if !closeTo(costs[0].MemoryGBHours, 1) || !closeTo(costs[0].MemoryCost, 0.005) {
+		t.Errorf("busy memory %+v", costs[0])
+	}
+
+	byTeam, err := GroupCosts(costs, CostByLabel, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string]api.CostAllocation{}
+	for _, a := range byTeam {
+		groups[a.Group] = a
+	}
+	if len(groups) != 2 || !closeTo(groups["checkout"].CPUCost, 0.06) || groups[unallocatedLabel].Pods != 1 ||
+		!closeTo(groups["checkout"].MonthlyCost, groups["checkout"].TotalCost*hoursPerMonth) {
+		t.Errorf("grouped by team %+v", byTeam)
+	}
+	if _, err := GroupCosts(costs, CostByLabel, ""); err == nil {
+		t.Error("expected an error grouping by label without a label")
+	}
+}
+
+func TestCostEndpoints(t *testing.T) {
+	store := &memoryCostStore{costs: []api.WorkloadCost{
+		{Date: "2024-03-10", Namespace: "shop", WorkloadKind: "Deployment", Workload: "web", Pods: 2, CPUCost: 1, MemoryCost: 0.5},
+		{Date: "2024-03-10", Namespace: "shop", WorkloadKind: "Deployment", Workload: "api", Pods: 1, CPUCost: 2, MemoryCost: 0.5},
+		{Date: "2024-03-11", Namespace: "shop", WorkloadKind: "Deployment", Workload: "web", Pods: 2, CPUCost: 1, MemoryCost: 0.5},
+		{Date: "2024-03-11", Namespace: "kube-system", WorkloadKind: "DaemonSet", Workload: "proxy", Pods: 3, CPUCost: 0.3},
+	}}
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c, WithCostStore(store))
+
+	w := serve(t, h, http.MethodGet, "/costs/daily?from=2024-03-10&to=2024-03-10")
+	var daily api.ListResponse[api.CostAllocation]
+	if err := json.Unmarshal(w.Body.Bytes(), &daily); err != nil {
+		t.Fatal(err)
+	}
+	if daily.Total != 1 || daily.Items[0].Group != "shop" || daily.Items[0].TotalCost != 4 || daily.Items[0].Pods != 3 {
+		t.Errorf("unexpected daily costs %+v", daily.Items)
+	}
+
+	w = serve(t, h, http.MethodGet, "/costs/export?from=2024-03-01&to=2024-03-31&groupBy=workload")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("export: status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || records[0][0] != "date" || records[1][1] != "shop/Deployment/web" || records[1][7] != "1.5000" {
+		t.Errorf("unexpected csv %v", records)
+	}
+
+	w = serve(t, h, http.MethodGet, "/costs?groupBy=namespace")
+	var live api.ListResponse[api.CostAllocation]
+	if err := json.Unmarshal(w.Body.Bytes(), &live); err != nil {
+		t.Fatal(err)
+	}
+	if live.Total == 0 || live.Items[0].TotalCost <= 0 {
+		t.Errorf("unexpected live costs %+v", live.Items)
+	}
+
+	if w := serve(t, h, http.MethodGet, "/costs?groupBy=team"); w.Code != http.StatusBadRequest {
+		t.Errorf("unsupported groupBy: status %d", w.Code)
+	}
+	if w := serve(t, h, http.MethodGet, "/costs/daily?from=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid from: status %d", w.Code)
+	}
+	if w := serve(t, NewHandler(c), http.MethodGet, "/costs/daily"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a store: status %d", w.Code)
+	}
+}
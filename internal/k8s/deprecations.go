@@ -0,0 +1,243 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+)
+
+// ErrInvalidTargetVersion is returned for target versions other than 1.N or v1.N.P
+var ErrInvalidTargetVersion = errors.New("invalid target version")
+
+// Sources of a deprecated API usage
+const (
+	SourceLastApplied   = "last-applied"
+	SourceManagedFields = "managedFields"
+)
+
+// deprecatedAPIs are the API versions removed from Kubernetes since 1.16,
+// following the upstream deprecation guide
+var deprecatedAPIs = []api.DeprecatedAPI{
+	{APIVersion: "extensions/v1beta1", Kind: "Deployment", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "DaemonSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "ReplicaSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "NetworkPolicy", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: "1.10", RemovedIn: "1.16", Replacement: "policy/v1beta1"},
+	{APIVersion: "apps/v1beta1", Kind: "Deployment", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta1", Kind: "StatefulSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "Deployment", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "StatefulSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "DaemonSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "ReplicaSet", DeprecatedIn: "1.9", RemovedIn: "1.16", Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Ingress", DeprecatedIn: "1.14", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1", Note: "backend serviceName/servicePort move to service.name/service.port and pathType is required"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", DeprecatedIn: "1.19", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1", Note: "backend serviceName/servicePort move to service.name/service.port and pathType is required"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", DeprecatedIn: "1.19", RemovedIn: "1.22", Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", DeprecatedIn: "1.16", RemovedIn: "1.22", Replacement: "apiextensions.k8s.io/v1", Note: "a structural schema is required"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", DeprecatedIn: "1.16", RemovedIn: "1.22", Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", DeprecatedIn: "1.16", RemovedIn: "1.22", Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "apiregistration.k8s.io/v1beta1", Kind: "APIService", DeprecatedIn: "1.19", RemovedIn: "1.22", Replacement: "apiregistration.k8s.io/v1"},
+	{APIVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", DeprecatedIn: "1.19", RemovedIn: "1.22", Replacement: "certificates.k8s.io/v1"},
+	{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", DeprecatedIn: "1.14", RemovedIn: "1.22", Replacement: "coordination.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", DeprecatedIn: "1.14", RemovedIn: "1.22", Replacement: "scheduling.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", DeprecatedIn: "1.19", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", DeprecatedIn: "1.17", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", DeprecatedIn: "1.6", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", DeprecatedIn: "1.13", RemovedIn: "1.22", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "batch/v1beta1", Kind: "CronJob", DeprecatedIn: "1.21", RemovedIn: "1.25", Replacement: "batch/v1"},
+	{APIVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", DeprecatedIn: "1.21", RemovedIn: "1.25", Replacement: "discovery.k8s.io/v1"},
+	{APIVersion: "events.k8s.io/v1beta1", Kind: "Event", DeprecatedIn: "1.19", RemovedIn: "1.25", Replacement: "events.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", DeprecatedIn: "1.22", RemovedIn: "1.25", Replacement: "autoscaling/v2"},
+	{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", DeprecatedIn: "1.21", RemovedIn: "1.25", Replacement: "policy/v1", Note: "an empty selector now selects every pod in the namespace"},
+	{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: "1.21", RemovedIn: "1.25", Note: "use Pod Security Admission or a third-party admission webhook"},
+	{APIVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", DeprecatedIn: "1.20", RemovedIn: "1.25", Replacement: "node.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", DeprecatedIn: "1.23", RemovedIn: "1.26", Replacement: "autoscaling/v2"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", DeprecatedIn: "1.23", RemovedIn: "1.26", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", DeprecatedIn: "1.23", RemovedIn: "1.26", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", DeprecatedIn: "1.24", RemovedIn: "1.27", Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", DeprecatedIn: "1.26", RemovedIn: "1.29", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", DeprecatedIn: "1.26", RemovedIn: "1.29", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", DeprecatedIn: "1.29", RemovedIn: "1.32", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", DeprecatedIn: "1.29", RemovedIn: "1.32", Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// deprecationKinds are checked for deprecated usages besides the fixture kinds:
+// the kinds whose older API versions were commonly applied from manifests
+var deprecationKinds = []fixtureKind{
+	{"horizontalpodautoscalers", autoscalingv2.SchemeGroupVersion.WithKind("HorizontalPodAutoscaler"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"poddisruptionbudgets", policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"networkpolicies", networkingv1.SchemeGroupVersion.WithKind("NetworkPolicy"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"ingressclasses", networkingv1.SchemeGroupVersion.WithKind("IngressClass"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"roles", rbacv1.SchemeGroupVersion.WithKind("Role"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.RbacV1().Roles(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"clusterroles", rbacv1.SchemeGroupVersion.WithKind("ClusterRole"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"clusterrolebindings", rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"storageclasses", storagev1.SchemeGroupVersion.WithKind("StorageClass"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+}
+
+// minorVersion parses "1.25", "v1.25" or "v1.25.3-gke.1" into the minor
+// release number, 25
+func minorVersion(v string) (int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("%w: %q, want 1.N", ErrInvalidTargetVersion, v)
+	}
+	// Managed clusters report minors like "27+"
+	minor, err := strconv.Atoi(strings.TrimRight(parts[1], "+"))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q, want 1.N", ErrInvalidTargetVersion, v)
+	}
+	return minor, nil
+}
+
+// removedBy reports whether a deprecated API is gone in the target minor release
+func removedBy(d api.DeprecatedAPI, target int) bool {
+	removed, err := minorVersion(d.RemovedIn)
+	return err == nil && removed <= target
+}
+
+// FindDeprecatedAPIs reports the APIs removed in target, e.g. "1.25", that the
+// cluster still serves and the objects whose kubectl last-applied annotation or
+// managedFields record one of them. An empty target is the release after the
+// server's, or the latest known removal when the server version is unknown.
+func (c *Client) FindDeprecatedAPIs(ctx context.Context, target string) (*api.DeprecationReport, error) {
+	report := &api.DeprecationReport{ServedAPIs: []api.DeprecatedAPI{}, Usages: []api.DeprecatedUsage{}}
+	if info, err := c.Clientset.Discovery().ServerVersion(); err == nil {
+		report.ServerVersion = info.GitVersion
+	}
+	if target == "" {
+		if server, err := minorVersion(report.ServerVersion); err == nil {
+			target = fmt.Sprintf("1.%d", server+1)
+		} else {
+			target = deprecatedAPIs[len(deprecatedAPIs)-1].RemovedIn
+		}
+	}
+	minor, err := minorVersion(target)
+	if err != nil {
+		return nil, err
+	}
+	report.TargetVersion = fmt.Sprintf("1.%d", minor)
+
+	removed := make(map[string]api.DeprecatedAPI)
+	for _, d := range deprecatedAPIs {
+		if removedBy(d, minor) {
+			removed[d.APIVersion+"/"+d.Kind] = d
+		}
+	}
+
+	// A partial discovery failure, usually an unavailable aggregated API, still lists the other groups
+	_, resources, err := c.Clientset.Discovery().ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	for _, list := range resources {
+		for _, resource := range list.APIResources {
+			if d, ok := removed[list.GroupVersion+"/"+resource.Kind]; ok && !strings.Contains(resource.Name, "/") {
+				report.ServedAPIs = append(report.ServedAPIs, d)
+			}
+		}
+	}
+
+	for _, kind := range append(append([]fixtureKind{}, fixtureKinds...), deprecationKinds...) {
+		if snapshotSkipped[kind.file] {
+			continue
+		}
+		items, err := kind.list(ctx, c, "")
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", kind.file, err)
+		}
+		for _, item := range items {
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				return nil, err
+			}
+			report.Usages = append(report.Usages, deprecatedUsages(accessor, kind.gvk.Kind, removed)...)
+		}
+	}
+	sort.Slice(report.ServedAPIs, func(i, j int) bool {
+		return report.ServedAPIs[i].APIVersion+"/"+report.ServedAPIs[i].Kind < report.ServedAPIs[j].APIVersion+"/"+report.ServedAPIs[j].Kind
+	})
+	sort.SliceStable(report.Usages, func(i, j int) bool {
+		a, b := report.Usages[i], report.Usages[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// deprecatedUsages returns the removed API versions an object was applied or
+// updated through, once per source and client
+func deprecatedUsages(obj metav1.Object, kind string, removed map[string]api.DeprecatedAPI) []api.DeprecatedUsage {
+	var usages []api.DeprecatedUsage
+	seen := make(map[string]bool)
+	add := func(apiVersion, kind, source, manager string) {
+		d, ok := removed[apiVersion+"/"+kind]
+		key := apiVersion + "/" + source + "/" + manager
+		if !ok || seen[key] {
+			return
+		}
+		seen[key] = true
+		usages = append(usages, api.DeprecatedUsage{
+			DeprecatedAPI: d, Namespace: obj.GetNamespace(), Name: obj.GetName(), Source: source, Manager: manager,
+		})
+	}
+
+	if raw := obj.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; raw != "" {
+		var applied metav1.TypeMeta
+		if err := json.Unmarshal([]byte(raw), &applied); err == nil {
+			add(applied.APIVersion, applied.Kind, SourceLastApplied, "")
+		}
+	}
+	for _, entry := range obj.GetManagedFields() {
+		add(entry.APIVersion, kind, SourceManagedFields, entry.Manager)
+	}
+	return usages
+}
@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+)
+
+func TestMinorVersion(t *testing.T) {
+	for v, want := range map[string]int{"1.25": 25, "v1.22.3": 22, "v1.27.4-gke.900": 27, "1.28+": 28} {
+		if got, err := minorVersion(v); err != nil || got != want {
+			t.Errorf("minorVersion(%q) = %d, %v", v, got, err)
+		}
+	}
+	for _, v := range []string{"", "2.1", "1", "v1.x"} {
+		if _, err := minorVersion(v); !errors.Is(err, ErrInvalidTargetVersion) {
+			t.Errorf("minorVersion(%q) error %v", v, err)
+		}
+	}
+}
+
+func TestFindDeprecatedAPIs(t *testing.T) {
+	cronJobManifest := `{"apiVersion":"batch/v1beta1","kind":"CronJob","metadata":{"name":"report","namespace":"shop"}}`
+	d := testDeployment("shop", "web", 1, 1)
+	d.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-client-side-apply", APIVersion: "apps/v1"},
+		{Manager: "old-operator", APIVersion: "extensions/v1beta1"},
+		{Manager: "old-operator", APIVersion: "extensions/v1beta1"},
+	}
+	svc := testService("shop", "web")
+	svc.Annotations = map[string]string{corev1.LastAppliedConfigAnnotation: `{"apiVersion":"v1","kind":"Service"}`}
+	c := newFakeClient(t, d, svc)
+	// A CronJob applied from an old manifest; the API server stores it as batch/v1
+	cron := &batchv1.CronJob{ObjectMeta: objectMeta("shop", "report", nil)}
+	cron.Annotations = map[string]string{corev1.LastAppliedConfigAnnotation: cronJobManifest}
+	if _, err := c.Clientset.BatchV1().CronJobs("shop").Create(context.Background(), cron, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fake := c.Clientset.Discovery().(*fakediscovery.FakeDiscovery)
+	fake.FakedServerVersion = &version.Info{GitVersion: "v1.24.6"}
+	fake.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{{Name: "cronjobs", Kind: "CronJob"}}},
+		{GroupVersion: "batch/v1beta1", APIResources: []metav1.APIResource{{Name: "cronjobs", Kind: "CronJob"}, {Name: "cronjobs/status", Kind: "CronJob"}}},
+		{GroupVersion: "policy/v1beta1", APIResources: []metav1.APIResource{{Name: "poddisruptionbudgets", Kind: "PodDisruptionBudget"}}},
+		{GroupVersion: "autoscaling/v2beta2", APIResources: []metav1.APIResource{{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler"}}},
+	}
+
+	// Defaults to the release after the server's
+	report, err := c.FindDeprecatedAPIs(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ServerVersion != "v1.24.6" || report.TargetVersion != "1.25" {
+		t.Errorf("versions %s -> %s", report.ServerVersion, report.TargetVersion)
+	}
+	var served []string
+	for _, d := range report.ServedAPIs {
+		served = append(served, d.APIVersion+"/"+d.Kind)
+	}
+	if !equalStrings(served, []string{"batch/v1beta1/CronJob", "policy/v1beta1/PodDisruptionBudget"}) {
+		t.Errorf("served %v", served)
+	}
+
+	if len(report.Usages) != 2 {
+		t.Fatalf("usages %+v", report.Usages)
+	}
+	cronUsage, deployUsage := report.Usages[0], report.Usages[1]
+	if cronUsage.Name != "report" || cronUsage.Source != SourceLastApplied || cronUsage.APIVersion != "batch/v1beta1" || cronUsage.Replacement != "batch/v1" {
+		t.Errorf("cronjob usage %+v", cronUsage)
+	}
+	if deployUsage.Name != "web" || deployUsage.Source != SourceManagedFields || deployUsage.Manager != "old-operator" || deployUsage.RemovedIn != "1.16" {
+		t.Errorf("deployment usage %+v", deployUsage)
+	}
+
+	// autoscaling/v2beta2 is only removed in 1.26
+	report, err = c.FindDeprecatedAPIs(context.Background(), "v1.26")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.ServedAPIs) != 3 {
+		t.Errorf("served in 1.26: %+v", report.ServedAPIs)
+	}
+	if _, err := c.FindDeprecatedAPIs(context.Background(), "latest"); !errors.Is(err, ErrInvalidTargetVersion) {
+		t.Errorf("invalid target error %v", err)
+	}
+}
+
+func TestDeprecatedAPIsHandler(t *testing.T) {
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c)
+	if w := serve(t, h, http.MethodGet, "/deprecations?target=1.x"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid target status %d", w.Code)
+	}
+
+	report, err := c.FindDeprecatedAPIs(context.Background(), "1.22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Usages) != 1 || report.Usages[0].Kind != "Ingress" || report.Usages[0].Manager != "helm" {
+		t.Errorf("demo usages %+v", report.Usages)
+	}
+}
@@ -0,0 +1,402 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Problem types found by the diagnosis
+const (
+	ProblemCrashLoop        = "CrashLoopBackOff"
+	ProblemOOMKilled        = "OOMKilled"
+	ProblemContainerFailed  = "ContainerFailed"
+	ProblemImagePull        = "ImagePullBackOff"
+	ProblemContainerConfig  = "CreateContainerConfigError"
+	ProblemProbeFailure     = "ProbeFailure"
+	ProblemEvicted          = "Evicted"
+	ProblemStuckTerminating = "StuckTerminating"
+)
+
+// Severities of problems and scan findings
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// stuckTerminatingAfter is how long past its deletion deadline a pod may take to go away
+const stuckTerminatingAfter = 5 * time.Minute
+
+// previousLogLines is the number of log lines of the crashed run shown as evidence
+const previousLogLines = 20
+
+var imagePullReasons = map[string]bool{
+	"ImagePullBackOff": true, "ErrImagePull": true, "InvalidImageName": true, "ErrImageNeverPull": true,
+}
+
+var containerConfigReasons = map[string]bool{
+	"CreateContainerConfigError": true, "CreateContainerError": true,
+}
+
+// DiagnosePod classifies the problems of a pod. Crashing containers come with
+// the last lines of their previous run, or of the current one when it has
+// terminated and will not be restarted.
+func (c *Client) DiagnosePod(ctx context.Context, namespace, name string) (*api.PodDiagnosis, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	selector := fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s", name)
+	events, err := c.Clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	problems := diagnosePod(*pod, podEvents(events.Items, *pod), time.Now())
+	for i := range problems {
+		p := &problems[i]
+		if p.Container == "" {
+			continue
+		}
+		if p.Type != ProblemCrashLoop && p.Type != ProblemOOMKilled && p.Type != ProblemContainerFailed && p.Type != ProblemProbeFailure {
+			continue
+		}
+		previous := !containerTerminated(*pod, p.Container)
+		if previous && p.RestartCount == 0 {
+			continue
+		}
+		p.PreviousLogs = c.previousLogs(ctx, namespace, name, p.Container, previous)
+	}
+
+	return &api.PodDiagnosis{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Phase:     string(pod.Status.Phase),
+		Healthy:   len(problems) == 0,
+		Problems:  problems,
+	}, nil
+}
+
+// GetProblems diagnoses every pod of a namespace, or of the cluster. Previous
+// logs are left out; they are fetched by DiagnosePod.
+func (c *Client) GetProblems(ctx context.Context, namespace string) ([]api.Problem, error) {
+	pods, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	events, err := c.Clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: "involvedObject.kind=Pod"})
+	if err != nil {
+		return nil, err
+	}
+
+	byPod := make(map[string][]corev1.Event)
+	for _, event := range events.Items {
+		key := event.Namespace + "/" + event.InvolvedObject.Name
+		byPod[key] = append(byPod[key], event)
+	}
+
+	problems := []api.Problem{}
+	now := time.Now()
+	for _, pod := range pods.Items {
+		problems = append(problems, diagnosePod(pod, podEvents(byPod[pod.Namespace+"/"+pod.Name], pod), now)...)
+	}
+	return problems, nil
+}
+
+// previousLogs returns the last lines logged by the previous run of a
+// container, or by the current run when previous is false
+func (c *Client) previousLogs(ctx context.Context, namespace, pod, container string, previous bool) []string {
+	tail := int64(previousLogLines)
+	options := &corev1.PodLogOptions{Container: container, Previous: previous, TailLines: &tail}
+	raw, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(pod, options).DoRaw(ctx)
+	if err != nil {
+		return nil
+	}
+	text := strings.TrimRight(string(raw), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// podEvents keeps the events of this pod instance, oldest first
+func podEvents(events []corev1.Event, pod corev1.Pod) []corev1.Event {
+	var matched []corev1.Event
+	for _, event := range events {
+		if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != pod.Name {
+			continue
+		}
+		if event.InvolvedObject.UID != "" && pod.UID != "" && event.InvolvedObject.UID != pod.UID {
+			continue
+		}
+		matched = append(matched, event)
+	}
+	sort.Slice(matched, func(i, j int) bool { return eventTime(matched[i]).Before(eventTime(matched[j])) })
+	return matched
+}
+
+// diagnosePod classifies the problems of a pod from its status and events
+func diagnosePod(pod corev1.Pod, events []corev1.Event, now time.Time) []api.Problem {
+	var problems []api.Problem
+	add := func(p api.Problem) {
+		p.Namespace, p.Pod = pod.Namespace, pod.Name
+		p.Events = containerEvents(events, p.Container, p.Type)
+		problems = append(problems, p)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed && pod.Status.Reason == "Evicted" {
+		add(api.Problem{
+			Type:     ProblemEvicted,
+			Severity: SeverityWarning,
+			Summary:  "The pod was evicted from its node",
+			Message:  pod.Status.Message,
+			NextSteps: []string{
+				fmt.Sprintf("Check the pressure conditions of node %s: kubectl describe node %s", pod.Spec.NodeName, pod.Spec.NodeName),
+				"Set memory and ephemeral-storage requests so that the scheduler accounts for the pod's usage",
+				fmt.Sprintf("Delete the evicted pod once investigated: kubectl delete pod -n %s %s", pod.Namespace, pod.Name),
+			},
+		})
+	}
+
+	if pod.DeletionTimestamp != nil && now.Sub(pod.DeletionTimestamp.Time) > stuckTerminatingAfter {
+		p := api.Problem{
+			Type:     ProblemStuckTerminating,
+			Severity: SeverityWarning,
+			Summary:  fmt.Sprintf("The pod has been terminating for %s", formatAge(pod.DeletionTimestamp.Time)),
+			NextSteps: []string{
+				fmt.Sprintf("Check that node %s is Ready; pods on an unreachable node are not removed until it returns", pod.Spec.NodeName),
+			},
+		}
+		if len(pod.Finalizers) > 0 {
+			p.Message = "Finalizers: " + strings.Join(pod.Finalizers, ", ")
+			p.NextSteps = append(p.NextSteps, "Find the controller responsible for the finalizers; remove them only if it is gone")
+		}
+		p.NextSteps = append(p.NextSteps,
+			fmt.Sprintf("As a last resort: kubectl delete pod -n %s %s --grace-period=0 --force", pod.Namespace, pod.Name))
+		add(p)
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if p, ok := diagnoseContainer(pod, status); ok {
+			add(p)
+		}
+		if p, ok := diagnoseProbes(pod, status, events); ok {
+			add(p)
+		}
+	}
+	return problems
+}
+
+// containerTerminated reports whether a container of the pod is terminated now
+func containerTerminated(pod corev1.Pod, container string) bool {
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		if status.Name == container {
+			return status.State.Terminated != nil
+		}
+	}
+	return false
+}
+
+// diagnoseContainer classifies a waiting, OOM killed or failed container. The
+// termination is the current state's when the container has not been
+// restarted, as under restartPolicy Never, else the last state's.
+func diagnoseContainer(pod corev1.Pod, status corev1.ContainerStatus) (api.Problem, bool) {
+	p := api.Problem{Container: status.Name, RestartCount: status.RestartCount}
+	logsCmd := fmt.Sprintf("kubectl logs -n %s %s -c %s --previous", pod.Namespace, pod.Name, status.Name)
+	last := status.LastTerminationState.Terminated
+	current := status.State.Terminated
+	if current != nil {
+		last = current
+		logsCmd = strings.TrimSuffix(logsCmd, " --previous")
+	}
+	if last != nil {
+		exitCode := last.ExitCode
+		p.ExitCode, p.Signal = &exitCode, last.Signal
+	}
+
+	waiting := status.State.Waiting
+	switch {
+	case waiting != nil && imagePullReasons[waiting.Reason]:
+		image := containerImage(pod, status.Name)
+		p.Type, p.Severity, p.Message = ProblemImagePull, SeverityCritical, waiting.Message
+		p.Summary = fmt.Sprintf("Image %s cannot be pulled", image)
+		p.NextSteps = []string{
+			fmt.Sprintf("Check that the image name and tag exist: %s", image),
+			"For a private registry, check the pod's imagePullSecrets and the service account's",
+			"Check that the node can reach the registry",
+		}
+		return p, true
+
+	case waiting != nil && containerConfigReasons[waiting.Reason]:
+		p.Type, p.Severity, p.Message = ProblemContainerConfig, SeverityCritical, waiting.Message
+		p.Summary = "The container cannot be created from its configuration"
+		p.NextSteps = []string{
+			"Check that every ConfigMap, Secret and key referenced by env, envFrom and volumes exists in the namespace",
+			fmt.Sprintf("kubectl describe pod -n %s %s", pod.Namespace, pod.Name),
+		}
+		return p, true
+
+	case last != nil && last.Reason == "OOMKilled":
+		p.Type, p.Severity = ProblemOOMKilled, SeverityWarning
+		p.Summary = "The container was killed for exceeding its memory limit"
+		if waiting != nil && waiting.Reason == "CrashLoopBackOff" {
+			p.Severity, p.Message = SeverityCritical, waiting.Message
+			p.Summary = "The container is crash looping because it runs out of memory"
+		}
+		limit := "none"
+		if quantity, ok := containerResources(pod, status.Name).Limits[corev1.ResourceMemory]; ok {
+			limit = quantity.String()
+		}
+		p.NextSteps = []string{
+			fmt.Sprintf("Raise the memory limit (currently %s) or reduce the application's memory use", limit),
+			fmt.Sprintf("Compare with actual usage: kubectl top pod -n %s %s --containers", pod.Namespace, pod.Name),
+			"Check the logs for the allocation that failed: " + logsCmd,
+		}
+		return p, true
+
+	case current != nil && current.ExitCode != 0 && pod.Status.Phase != corev1.PodSucceeded:
+		p.Type, p.Severity, p.Message = ProblemContainerFailed, SeverityWarning, current.Message
+		p.Summary = fmt.Sprintf("The container exited with code %d", current.ExitCode)
+		if pod.Spec.RestartPolicy == corev1.RestartPolicyNever {
+			p.Severity = SeverityCritical
+			p.Summary += " and will not be restarted"
+		}
+		p.NextSteps = []string{"Read the logs of the failed run: " + logsCmd}
+		if hint := exitCodeHint(current.ExitCode); hint != "" {
+			p.NextSteps = append(p.NextSteps, hint)
+		}
+		p.NextSteps = append(p.NextSteps, "Check the command, arguments and environment the container starts with")
+		return p, true
+
+	case waiting != nil && waiting.Reason == "CrashLoopBackOff":
+		p.Type, p.Severity, p.Message = ProblemCrashLoop, SeverityCritical, waiting.Message
+		p.Summary = fmt.Sprintf("The container keeps exiting and has restarted %d times", status.RestartCount)
+		p.NextSteps = []string{"Read the logs of the crashed run: " + logsCmd}
+		if last != nil {
+			p.Summary += fmt.Sprintf(", last with exit code %d", last.ExitCode)
+			if hint := exitCodeHint(last.ExitCode); hint != "" {
+				p.NextSteps = append(p.NextSteps, hint)
+			}
+		}
+		p.NextSteps = append(p.NextSteps, "Check the command, arguments and environment the container starts with")
+		return p, true
+	}
+	return p, false
+}
+
+// diagnoseProbes reports a container whose probes fail while it is not ready or restarting
+func diagnoseProbes(pod corev1.Pod, status corev1.ContainerStatus, events []corev1.Event) (api.Problem, bool) {
+	if status.State.Running == nil {
+		return api.Problem{}, false
+	}
+	var failures []string
+	for _, event := range events {
+		if event.Reason != "Unhealthy" || eventContainer(event) != status.Name {
+			continue
+		}
+		probe, _, _ := strings.Cut(event.Message, " probe failed")
+		if probe == event.Message {
+			continue
+		}
+		// Liveness failures matter once they restart the container, readiness failures while not ready
+		if (probe == "Liveness" && status.RestartCount > 0) || (probe != "Liveness" && !status.Ready) {
+			failures = append(failures, event.Message)
+		}
+	}
+	if len(failures) == 0 {
+		return api.Problem{}, false
+	}
+
+	return api.Problem{
+		Container:    status.Name,
+		Type:         ProblemProbeFailure,
+		Severity:     SeverityWarning,
+		Summary:      "Health probes of the container are failing",
+		RestartCount: status.RestartCount,
+		Message:      failures[len(failures)-1],
+		NextSteps: []string{
+			"Check that the probe's port and path match what the application serves",
+			"If the application starts slowly, add a startupProbe or raise initialDelaySeconds",
+			"Raise timeoutSeconds and failureThreshold if the endpoint is slow under load",
+		},
+	}, true
+}
+
+// exitCodeHint explains common exit codes
+func exitCodeHint(code int32) string {
+	switch code {
+	case 0:
+		return "Exit code 0: the process finished; a long-running container must not exit"
+	case 1:
+		return "Exit code 1: the application failed; its logs should say why"
+	case 126:
+		return "Exit code 126: the command is not executable; check file permissions"
+	case 127:
+		return "Exit code 127: the command was not found in the image"
+	case 137:
+		return "Exit code 137: killed by SIGKILL, usually a failed liveness probe or the memory limit"
+	case 139:
+		return "Exit code 139: segmentation fault"
+	case 143:
+		return "Exit code 143: stopped by SIGTERM"
+	}
+	return ""
+}
+
+// containerEvents returns the warning events about a container, or about the pod itself
+func containerEvents(events []corev1.Event, container, problem string) []api.ObjectEvent {
+	var result []api.ObjectEvent
+	for _, event := range events {
+		if event.Type != corev1.EventTypeWarning && problem != ProblemEvicted && problem != ProblemStuckTerminating {
+			continue
+		}
+		if name := eventContainer(event); name != "" && name != container {
+			continue
+		}
+		result = append(result, newObjectEvent(event))
+	}
+	return result
+}
+
+// eventContainer returns the container an event refers to through its field path,
+// e.g. spec.containers{web}
+func eventContainer(event corev1.Event) string {
+	_, rest, ok := strings.Cut(event.InvolvedObject.FieldPath, "{")
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(rest, "}")
+	return name
+}
+
+// podContainers returns the init and regular containers of a pod
+func podContainers(pod corev1.Pod) []corev1.Container {
+	return append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+}
+
+func containerImage(pod corev1.Pod, name string) string {
+	for _, container := range podContainers(pod) {
+		if container.Name == name {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+func containerResources(pod corev1.Pod, name string) corev1.ResourceRequirements {
+	for _, container := range podContainers(pod) {
+		if container.Name == name {
+			return container.Resources
+		}
+	}
+	return corev1.ResourceRequirements{}
+}
@@ -0,0 +1,173 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiagnosePod(t *testing.T) {
+	now := time.Now()
+	waiting := func(reason string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}
+	}
+	terminated := func(reason string, code int32) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: reason, ExitCode: code}}
+	}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+
+	tests := []struct {
+		name   string
+		modify func(pod *corev1.Pod)
+		events []corev1.Event
+		want   []string // problem types
+	}{
+		{"healthy", func(pod *corev1.Pod) {}, nil, nil},
+		{"crash loop", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = waiting("CrashLoopBackOff")
+			pod.Status.ContainerStatuses[0].LastTerminationState = terminated("Error", 1)
+		}, nil, []string{ProblemCrashLoop}},
+		{"crash loop from OOM", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = waiting("CrashLoopBackOff")
+			pod.Status.ContainerStatuses[0].LastTerminationState = terminated("OOMKilled", 137)
+		}, nil, []string{ProblemOOMKilled}},
+		{"OOM killed before its first restart", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = terminated("OOMKilled", 137)
+		}, nil, []string{ProblemOOMKilled}},
+		{"failed with restartPolicy Never", func(pod *corev1.Pod) {
+			pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+			pod.Status.Phase = corev1.PodFailed
+			pod.Status.ContainerStatuses[0].State = terminated("Error", 1)
+		}, nil, []string{ProblemContainerFailed}},
+		{"completed", func(pod *corev1.Pod) {
+			pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+			pod.Status.Phase = corev1.PodSucceeded
+			pod.Status.ContainerStatuses[0].State = terminated("Completed", 0)
+			pod.Status.ContainerStatuses[1].State = terminated("Completed", 0)
+		}, nil, nil},
+		{"image pull", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[1].State = waiting("ErrImagePull")
+		}, nil, []string{ProblemImagePull}},
+		{"config error in init container", func(pod *corev1.Pod) {
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{Name: "init", State: waiting("CreateContainerConfigError")}}
+		}, nil, []string{ProblemContainerConfig}},
+		{"failing readiness probe", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[1].State = running
+			pod.Status.ContainerStatuses[1].Ready = false
+		}, []corev1.Event{
+			{Type: corev1.EventTypeWarning, Reason: "Unhealthy", Message: "Readiness probe failed: HTTP probe failed with statuscode: 503",
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-1", FieldPath: "spec.containers{sidecar}"}},
+		}, []string{ProblemProbeFailure}},
+		{"readiness probe failure of a ready container", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[1].State = running
+		}, []corev1.Event{
+			{Type: corev1.EventTypeWarning, Reason: "Unhealthy", Message: "Readiness probe failed: timeout",
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-1", FieldPath: "spec.containers{sidecar}"}},
+		}, nil},
+		{"evicted", func(pod *corev1.Pod) {
+			pod.Status.Phase, pod.Status.Reason = corev1.PodFailed, "Evicted"
+		}, nil, []string{ProblemEvicted}},
+		{"stuck terminating", func(pod *corev1.Pod) {
+			deleted := metav1.NewTime(now.Add(-time.Hour))
+			pod.DeletionTimestamp = &deleted
+			pod.Finalizers = []string{"example.com/cleanup"}
+		}, nil, []string{ProblemStuckTerminating}},
+		{"recently deleted", func(pod *corev1.Pod) {
+			deleted := metav1.NewTime(now.Add(-time.Minute))
+			pod.DeletionTimestamp = &deleted
+		}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := testPod("shop", "web-1", corev1.PodRunning, nil)
+			tt.modify(pod)
+			var got []string
+			for _, p := range diagnosePod(*pod, tt.events, now) {
+				got = append(got, p.Type)
+				if p.Namespace != "shop" || p.Pod != "web-1" || p.Severity == "" || len(p.NextSteps) == 0 {
+					t.Errorf("incomplete problem %+v", p)
+				}
+			}
+			if !equalStrings(got, tt.want) {
+				t.Errorf("problems %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnoseTerminatedContainer(t *testing.T) {
+	pod := testPod("batch", "report-x7k2p", corev1.PodFailed, nil)
+	pod.Spec.RestartPolicy = corev1.RestartPolicyNever
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}
+	c := newFakeClient(t, pod)
+
+	diagnosis, err := c.DiagnosePod(context.Background(), "batch", "report-x7k2p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnosis.Problems) != 1 {
+		t.Fatalf("problems %+v", diagnosis.Problems)
+	}
+	problem := diagnosis.Problems[0]
+	if problem.Type != ProblemOOMKilled || problem.ExitCode == nil || *problem.ExitCode != 137 || len(problem.PreviousLogs) == 0 {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+	// There is no previous run to read the logs of
+	for _, step := range problem.NextSteps {
+		if strings.Contains(step, "--previous") {
+			t.Errorf("next step %q", step)
+		}
+	}
+	for _, action := range c.Clientset.(*kubefake.Clientset).Actions() {
+		if action.GetSubresource() == "log" && action.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions).Previous {
+			t.Error("fetched the logs of a previous run")
+		}
+	}
+}
+
+func TestDiagnosisEndpoints(t *testing.T) {
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c)
+
+	w := serve(t, h, http.MethodGet, "/pods/shop/api-5f7b8c9d4-mn3pq/diagnosis")
+	var diagnosis api.PodDiagnosis
+	if err := json.Unmarshal(w.Body.Bytes(), &diagnosis); err != nil {
+		t.Fatal(err)
+	}
+	if diagnosis.Healthy || len(diagnosis.Problems) != 1 {
+		t.Fatalf("unexpected diagnosis %+v", diagnosis)
+	}
+	problem := diagnosis.Problems[0]
+	if problem.Type != ProblemOOMKilled || problem.Severity != SeverityCritical ||
+		problem.ExitCode == nil || *problem.ExitCode != 137 || len(problem.PreviousLogs) == 0 {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+
+	w = serve(t, h, http.MethodGet, "/pods/shop/web-7d9c6b5f4-abcde/diagnosis")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown pod: status %d", w.Code)
+	}
+
+	w = serve(t, h, http.MethodGet, "/problems?namespace=shop")
+	var list api.ListResponse[api.Problem]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.Items[0].Pod != "api-5f7b8c9d4-mn3pq" || list.Items[0].PreviousLogs != nil {
+		t.Errorf("unexpected problems %+v", list.Items)
+	}
+}
@@ -0,0 +1,324 @@
+package k8s
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ErrExecUnavailable is returned by clients without a connection to a real API server
+var ErrExecUnavailable = errors.New("copying files needs a connection to a cluster")
+
+// ErrInvalidCopyTarget is returned for relative container paths, unknown
+// containers and unusable upload file names
+var ErrInvalidCopyTarget = errors.New("invalid copy target")
+
+// ErrContainerPathNotFound is returned when the path does not exist in the container
+var ErrContainerPathNotFound = errors.New("no such file or directory in the container")
+
+// ErrFileTooLarge is returned when the files to copy exceed the size limit
+var ErrFileTooLarge = errors.New("files exceed the copy size limit")
+
+// DefaultCopyLimit is the most bytes of file content copied in one request
+const DefaultCopyLimit int64 = 1 << 30
+
+// defaultContainerAnnotation names the container kubectl uses when none is given
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// execFunc runs a command in a container, streaming its stdin and output
+type execFunc func(ctx context.Context, namespace, pod, container string, command []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+// exec runs a command in a container through the pods/exec subresource
+func (c *Client) exec(ctx context.Context, namespace, pod, container string, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if c.execer != nil {
+		return c.execer(ctx, namespace, pod, container, command, stdin, stdout, stderr)
+	}
+	if c.Config == nil {
+		return ErrExecUnavailable
+	}
+	req := c.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(namespace).Name(pod).SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(c.Config, http.MethodPost, req.URL())
+	if err != nil {
+		return err
+	}
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+}
+
+// PodContainer checks that a pod has the container, or picks the one kubectl
+// would: the default-container annotation, else the first container
+func (c *Client) PodContainer(ctx context.Context, namespace, name, container string) (string, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if container == "" {
+		container = pod.Annotations[defaultContainerAnnotation]
+	}
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name, nil
+	}
+	for _, ctr := range pod.Spec.Containers {
+		if ctr.Name == container {
+			return container, nil
+		}
+	}
+	return "", fmt.Errorf("%w: pod %s has no container %s", ErrInvalidCopyTarget, name, container)
+}
+
+// containerPath cleans an absolute path inside a container
+func containerPath(p string) (string, error) {
+	if !path.IsAbs(p) || strings.ContainsRune(p, 0) {
+		return "", fmt.Errorf("%w: path %q must be absolute", ErrInvalidCopyTarget, p)
+	}
+	return path.Clean(p), nil
+}
+
+// CopyFromContainer streams a file or directory out of a container to w as a
+// tar archive, or a zip archive of its files and directories when format is
+// "zip", and returns the bytes of file content copied. Like kubectl cp it
+// runs tar in the container, which must have it. Nothing is written to w
+// before the first file arrives, so callers can still answer a missing path
+// or a first file over the limit with an error status.
+func (c *Client) CopyFromContainer(ctx context.Context, namespace, pod, container, srcPath, format string, limit int64, w io.Writer) (int64, error) {
+	srcPath, err := containerPath(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	dir, base := path.Split(srcPath)
+	if base == "" {
+		dir, base = "/", "."
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	var stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		// "--" keeps a file name starting with a dash from being read as an option
+		err := c.exec(ctx, namespace, pod, container, []string{"tar", "cf", "-", "-C", dir, "--", base}, nil, pw, &stderr)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	archive := newArchiveWriter(w, format)
+	copied, err := copyArchive(archive, tar.NewReader(pr), limit)
+	if err != nil {
+		// Stop the transfer
+		cancel()
+	} else {
+		// tar pads the archive beyond its end marker
+		io.Copy(io.Discard, pr)
+	}
+	pr.Close()
+	execErr := <-done
+
+	if errors.Is(err, ErrFileTooLarge) {
+		return copied, err
+	}
+	if execErr != nil {
+		return copied, tarError(execErr, stderr.String())
+	}
+	if err != nil {
+		return copied, err
+	}
+	return copied, archive.Close()
+}
+
+// CopyToContainer uploads files into a directory of a container by piping a
+// tar archive of them into tar. Only the base names of the files are used.
+func (c *Client) CopyToContainer(ctx context.Context, namespace, pod, container, destDir string, files []*multipart.FileHeader) (*api.CopyResult, error) {
+	destDir, err := containerPath(destDir)
+	if err != nil {
+		return nil, err
+	}
+	result := &api.CopyResult{Container: container, Path: destDir, Files: []string{}}
+	for _, file := range files {
+		name := path.Base(strings.ReplaceAll(file.Filename, "\\", "/"))
+		if name == "." || name == ".." || name == "/" {
+			return nil, fmt.Errorf("%w: file name %q", ErrInvalidCopyTarget, file.Filename)
+		}
+		result.Files = append(result.Files, name)
+		result.Bytes += file.Size
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeUploadArchive(pw, files, result.Files))
+	}()
+	var stderr bytes.Buffer
+	err = c.exec(ctx, namespace, pod, container, []string{"tar", "xf", "-", "-C", destDir}, pr, io.Discard, &stderr)
+	// Unblock the archive writer if tar stopped reading early
+	pr.Close()
+	if err != nil {
+		return nil, tarError(err, stderr.String())
+	}
+	return result, nil
+}
+
+func writeUploadArchive(w io.Writer, files []*multipart.FileHeader, names []string) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for i, file := range files {
+		f, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: names[i], Size: file.Size, Mode: 0644, ModTime: now})
+		if err == nil {
+			_, err = io.Copy(tw, f)
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// tarError explains a failed tar command from its output
+func tarError(err error, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	switch {
+	case errors.Is(err, ErrExecUnavailable):
+		return err
+	case strings.Contains(stderr, "No such file or directory"):
+		return fmt.Errorf("%w: %s", ErrContainerPathNotFound, stderr)
+	case strings.Contains(err.Error(), "executable file not found"):
+		return fmt.Errorf("copying files needs tar in the container: %w", err)
+	case stderr != "":
+		return fmt.Errorf("%w: %s", err, stderr)
+	}
+	return err
+}
+
+// archiveWriter writes the entries of a tar stream in the requested format
+type archiveWriter interface {
+	Add(hdr *tar.Header, r io.Reader) error
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format string) archiveWriter {
+	if format == "zip" {
+		return zipArchive{zip.NewWriter(w)}
+	}
+	return tarArchive{tar.NewWriter(w)}
+}
+
+// copyArchive copies the entries of a tar stream until the end of the archive
+// or until the file content would exceed limit bytes. The container is not
+// trusted: entries and links that would land outside the archive root when
+// extracted are dropped.
+func copyArchive(dst archiveWriter, src *tar.Reader, limit int64) (int64, error) {
+	var copied int64
+	for {
+		hdr, err := src.Next()
+		if err == io.EOF {
+			return copied, nil
+		}
+		if err != nil {
+			return copied, err
+		}
+		name, ok := archivePath(hdr.Name)
+		if !ok {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			if path.IsAbs(hdr.Linkname) {
+				continue
+			}
+			if _, ok := archivePath(path.Join(path.Dir(name), hdr.Linkname)); !ok {
+				continue
+			}
+		case tar.TypeLink:
+			if _, ok := archivePath(hdr.Linkname); !ok {
+				continue
+			}
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			name += "/"
+		}
+		hdr.Name = name
+		if copied+hdr.Size > limit {
+			return copied, fmt.Errorf("%w of %d bytes at %s", ErrFileTooLarge, limit, hdr.Name)
+		}
+		if err := dst.Add(hdr, src); err != nil {
+			return copied, err
+		}
+		copied += hdr.Size
+	}
+}
+
+// archivePath cleans the name of an archive entry and reports whether it stays
+// inside the archive root
+func archivePath(name string) (string, bool) {
+	name = path.Clean(name)
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+type tarArchive struct{ *tar.Writer }
+
+func (a tarArchive) Add(hdr *tar.Header, r io.Reader) error {
+	if err := a.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(a.Writer, r)
+	return err
+}
+
+type zipArchive struct{ *zip.Writer }
+
+// Add keeps files and directories; zip has no portable links or devices
+func (a zipArchive) Add(hdr *tar.Header, r io.Reader) error {
+	if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+		return nil
+	}
+	fh, err := zip.FileInfoHeader(hdr.FileInfo())
+	if err != nil {
+		return err
+	}
+	fh.Name = strings.TrimPrefix(hdr.Name, "./")
+	if hdr.Typeflag == tar.TypeDir {
+		if fh.Name == "" || fh.Name == "." {
+			return nil
+		}
+		fh.Name = strings.TrimSuffix(fh.Name, "/") + "/"
+		_, err = a.CreateHeader(fh)
+		return err
+	}
+	fh.Method = zip.Deflate
+	fw, err := a.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
@@ -0,0 +1,299 @@
+package k8s
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fakeContainer runs tar against an in-memory file system
+type fakeContainer struct {
+	files    map[string]string
+	commands []string
+}
+
+func (f *fakeContainer) exec(ctx context.Context, namespace, pod, container string, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f.commands = append(f.commands, container+": "+strings.Join(command, " "))
+	switch command[1] {
+	case "cf":
+		dir, base := command[4], command[6]
+		root := path.Join(dir, base)
+		var names []string
+		for name := range f.files {
+			if name == root || strings.HasPrefix(name, root+"/") {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			fmt.Fprintf(stderr, "tar: %s: Cannot stat: No such file or directory\n", base)
+			return errors.New("command terminated with exit code 2")
+		}
+		sort.Strings(names)
+		tw := tar.NewWriter(stdout)
+		for _, name := range names {
+			content := f.files[name]
+			tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: strings.TrimPrefix(name, dir), Size: int64(len(content)), Mode: 0644})
+			io.WriteString(tw, content)
+		}
+		return tw.Close()
+	case "xf":
+		tr := tar.NewReader(stdin)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			content, _ := io.ReadAll(tr)
+			f.files[path.Join(command[4], hdr.Name)] = string(content)
+		}
+	}
+	return fmt.Errorf("unexpected command %v", command)
+}
+
+func newFileTestHandler(t *testing.T, opts ...HandlerOption) (*Handler, *fakeContainer, *memoryAuditStore) {
+	t.Helper()
+	pod := testPod("shop", "web-1", corev1.PodRunning, nil)
+	pod.Annotations = map[string]string{defaultContainerAnnotation: "sidecar"}
+	c := newFakeClient(t, pod)
+	container := &fakeContainer{files: map[string]string{
+		"/tmp/heap.hprof":         "heap dump",
+		"/var/log/app/access.log": "GET /",
+		"/var/log/app/error.log":  "oops",
+	}}
+	c.execer = container.exec
+	audit := &memoryAuditStore{}
+	return NewHandler(c, append([]HandlerOption{WithAuditStore(audit), WithProxyToken("s3cret")}, opts...)...), container, audit
+}
+
+// serveFiles is serve with the proxy token file copies need
+func serveFiles(t *testing.T, h *Handler, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveJSON(t, h, method, target, nil, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer s3cret")
+	})
+}
+
+func TestDownloadPodFiles(t *testing.T) {
+	h, container, audit := newFileTestHandler(t)
+
+	w := serveFiles(t, h, http.MethodGet, "/pods/shop/web-1/files?path=/var/log/app&container=app")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="web-1-app.tar"` {
+		t.Errorf("content disposition %s", got)
+	}
+	tr := tar.NewReader(w.Body)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if !equalStrings(names, []string{"app/access.log", "app/error.log"}) {
+		t.Errorf("archive entries %v", names)
+	}
+	if container.commands[0] != "app: tar cf - -C /var/log/ -- app" {
+		t.Errorf("command %s", container.commands[0])
+	}
+
+	// The default container comes from the annotation
+	w = serveFiles(t, h, http.MethodGet, "/pods/shop/web-1/files?path=/tmp/heap.hprof&format=zip")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("zip status %d: %s", w.Code, w.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "heap.hprof" {
+		t.Fatalf("zip entries %+v", zr.File)
+	}
+	if container.commands[1] != "sidecar: tar cf - -C /tmp/ -- heap.hprof" {
+		t.Errorf("command %s", container.commands[1])
+	}
+
+	w = serveFiles(t, h, http.MethodGet, "/pods/shop/web-1/files?path=/tmp/missing")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Disposition") != "" {
+		t.Errorf("missing path status %d, headers %v", w.Code, w.Header())
+	}
+
+	if len(audit.entries) != 3 {
+		t.Fatalf("audit entries %+v", audit.entries)
+	}
+	if e := audit.entries[1]; e.Action != AuditCopyFrom || e.Kind != "Pod" || e.Name != "web-1" || e.Detail != "sidecar:/tmp/heap.hprof (9 bytes)" || e.Error != "" {
+		t.Errorf("audit entry %+v", e)
+	}
+	if e := audit.entries[2]; e.Error == "" {
+		t.Errorf("failed download audit entry %+v", e)
+	}
+
+	for target, status := range map[string]int{
+		"/pods/shop/web-1/files?path=tmp/heap.hprof":            http.StatusBadRequest,
+		"/pods/shop/web-1/files?path=/tmp&container=missing":    http.StatusBadRequest,
+		"/pods/shop/web-1/files?path=/tmp/heap.hprof&format=7z": http.StatusBadRequest,
+		"/pods/shop/web-2/files?path=/tmp/heap.hprof":           http.StatusNotFound,
+	} {
+		if w := serveFiles(t, h, http.MethodGet, target); w.Code != status {
+			t.Errorf("%s status %d, want %d", target, w.Code, status)
+		}
+	}
+}
+
+func TestPodFilesNeedToken(t *testing.T) {
+	h, container, audit := newFileTestHandler(t)
+	if w := serve(t, h, http.MethodGet, "/pods/shop/web-1/files?path=/tmp/heap.hprof"); w.Code != http.StatusUnauthorized {
+		t.Errorf("download without token status %d", w.Code)
+	}
+	// A form posted from another site carries no token
+	body, contentType := multipartFiles(t, map[string]string{"evil.sh": "rm -rf /"})
+	w := serveJSON(t, h, http.MethodPost, "/pods/shop/web-1/files?path=/tmp", nil, func(req *http.Request) {
+		req.Body = io.NopCloser(body)
+		req.Header.Set("Content-Type", contentType)
+		req.AddCookie(&http.Cookie{Name: proxyTokenCookie, Value: "s3cret"})
+	})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("upload without token status %d", w.Code)
+	}
+	if len(container.commands) != 0 || len(audit.entries) != 0 {
+		t.Errorf("unauthorized copies ran %v", container.commands)
+	}
+
+	h, _, _ = newFileTestHandler(t, WithProxyToken(""))
+	if w := serveFiles(t, h, http.MethodGet, "/pods/shop/web-1/files?path=/tmp/heap.hprof"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without PROXY_TOKEN status %d", w.Code)
+	}
+}
+
+func TestCopyArchiveDropsEscapingEntries(t *testing.T) {
+	var src bytes.Buffer
+	tw := tar.NewWriter(&src)
+	for _, hdr := range []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "./app/"},
+		{Typeflag: tar.TypeReg, Name: "app/../app/ok.log", Size: 2},
+		{Typeflag: tar.TypeReg, Name: "../../etc/cron.d/evil", Size: 2},
+		{Typeflag: tar.TypeReg, Name: "/etc/passwd", Size: 2},
+		{Typeflag: tar.TypeSymlink, Name: "app/current", Linkname: "ok.log"},
+		{Typeflag: tar.TypeSymlink, Name: "app/escape", Linkname: "../../root"},
+		{Typeflag: tar.TypeSymlink, Name: "app/absolute", Linkname: "/etc/shadow"},
+		{Typeflag: tar.TypeLink, Name: "app/hard", Linkname: "../etc/shadow"},
+	} {
+		hdr.Mode = 0644
+		tw.WriteHeader(hdr)
+		io.WriteString(tw, strings.Repeat("x", int(hdr.Size)))
+	}
+	tw.Close()
+
+	var dst bytes.Buffer
+	archive := newArchiveWriter(&dst, "tar")
+	if _, err := copyArchive(archive, tar.NewReader(&src), DefaultCopyLimit); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+	var names []string
+	tr := tar.NewReader(&dst)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if !equalStrings(names, []string{"app/", "app/ok.log", "app/current"}) {
+		t.Errorf("archive entries %v", names)
+	}
+}
+
+func TestDownloadPodFilesLimits(t *testing.T) {
+	h, _, _ := newFileTestHandler(t, WithCopyLimit(8))
+	if w := serveFiles(t, h, http.MethodGet, "/pods/shop/web-1/files?path=/tmp/heap.hprof"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("over the limit status %d", w.Code)
+	}
+
+	if w := serveFiles(t, NewHandler(newFakeClient(t), WithProxyToken("s3cret")), http.MethodGet, "/pods/shop/web-1/files?path=/tmp"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without audit store status %d", w.Code)
+	}
+	// Fake clients cannot exec
+	c := newFakeClient(t, testPod("shop", "web-1", corev1.PodRunning, nil))
+	h = NewHandler(c, WithAuditStore(&memoryAuditStore{}), WithProxyToken("s3cret"))
+	if w := serveFiles(t, h, http.MethodGet, "/pods/shop/web-1/files?path=/tmp"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a cluster status %d", w.Code)
+	}
+}
+
+func multipartFiles(t *testing.T, files map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, content)
+	}
+	mw.Close()
+	return &body, mw.FormDataContentType()
+}
+
+func TestUploadPodFiles(t *testing.T) {
+	h, container, audit := newFileTestHandler(t)
+	body, contentType := multipartFiles(t, map[string]string{"config.yaml": "debug: true"})
+	w := serveJSON(t, h, http.MethodPost, "/pods/shop/web-1/files?path=/etc/app/&container=app&token=s3cret", nil, func(req *http.Request) {
+		req.Body = io.NopCloser(body)
+		req.Header.Set("Content-Type", contentType)
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var result api.CopyResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Path != "/etc/app" || result.Bytes != 11 || !equalStrings(result.Files, []string{"config.yaml"}) {
+		t.Errorf("result %+v", result)
+	}
+	if got := container.files["/etc/app/config.yaml"]; got != "debug: true" {
+		t.Errorf("uploaded content %q", got)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != AuditCopyTo || audit.entries[0].Detail != "app:/etc/app/ config.yaml" {
+		t.Errorf("audit entries %+v", audit.entries)
+	}
+
+	h, _, _ = newFileTestHandler(t, WithCopyLimit(64))
+	body, contentType = multipartFiles(t, map[string]string{"big.bin": strings.Repeat("x", 256)})
+	w = serveJSON(t, h, http.MethodPost, "/pods/shop/web-1/files?path=/tmp&token=s3cret", nil, func(req *http.Request) {
+		req.Body = io.NopCloser(body)
+		req.Header.Set("Content-Type", contentType)
+	})
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("over the limit status %d: %s", w.Code, w.Body.String())
+	}
+}
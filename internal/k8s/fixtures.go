@@ -0,0 +1,339 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// RedactedValue replaces every Secret value in recorded fixtures
+const RedactedValue = "REDACTED"
+
+var fixtureScheme = runtime.NewScheme()
+
+func init() {
+	if err := clientgoscheme.AddToScheme(fixtureScheme); err != nil {
+		panic(err)
+	}
+	if err := metricsv1beta1.AddToScheme(fixtureScheme); err != nil {
+		panic(err)
+	}
+}
+
+// NewFakeClient returns a Client backed by client-go fake clientsets holding
+// objects. NodeMetrics and PodMetrics go to the metrics fake, under the resource
+// names it lists them by (nodes and pods), which its tracker cannot guess.
+func NewFakeClient(objects ...runtime.Object) (*Client, error) {
+	var core []runtime.Object
+	metrics := metricsfake.NewSimpleClientset()
+	for _, obj := range objects {
+		var err error
+		switch m := obj.(type) {
+		case *metricsv1beta1.NodeMetrics:
+			err = metrics.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("nodes"), m, "")
+		case *metricsv1beta1.PodMetrics:
+			err = metrics.Tracker().Create(metricsv1beta1.SchemeGroupVersion.WithResource("pods"), m, m.Namespace)
+		default:
+			core = append(core, obj)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Client{Clientset: fake.NewSimpleClientset(core...), MetricsClient: metrics}, nil
+}
+
+// NewDemoClient returns a fake Client loaded from a fixture directory
+func NewDemoClient(dir string) (*Client, error) {
+	objects, err := LoadFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewFakeClient(objects...)
+}
+
+// LoadFixtures decodes every .yaml, .yml and .json file under dir. Files may hold
+// several documents and List objects such as `kubectl get -o yaml` output.
+func LoadFixtures(dir string) ([]runtime.Object, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			if !d.IsDir() {
+				files = append(files, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	decoder := serializer.NewCodecFactory(fixtureScheme).UniversalDeserializer()
+	var objects []runtime.Object
+	for _, file := range files {
+		decoded, err := decodeFixtureFile(decoder, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+func decodeFixtureFile(decoder runtime.Decoder, file string) ([]runtime.Object, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var objects []runtime.Object
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(f))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Skip documents holding only comments or whitespace
+		var probe map[string]interface{}
+		if err := yaml.Unmarshal(doc, &probe); err != nil {
+			return nil, err
+		}
+		if len(probe) == 0 {
+			continue
+		}
+
+		decoded, err := decodeFixture(decoder, doc)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+}
+
+// decodeFixture decodes one document, flattening List objects
+func decodeFixture(decoder runtime.Decoder, doc []byte) ([]runtime.Object, error) {
+	obj, _, err := decoder.Decode(doc, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := obj.(*corev1.List)
+	if !ok {
+		return []runtime.Object{obj}, nil
+	}
+
+	var objects []runtime.Object
+	for _, item := range list.Items {
+		decoded, err := decodeFixture(decoder, item.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// fixtureKind is a resource kind captured by RecordFixtures. list returns the
+// objects of one namespace, or of all namespaces for ""; cluster-scoped kinds
+// ignore the namespace.
+type fixtureKind struct {
+	file       string
+	gvk        schema.GroupVersionKind
+	namespaced bool
+	list       func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error)
+}
+
+var fixtureKinds = []fixtureKind{
+	{"namespaces", corev1.SchemeGroupVersion.WithKind("Namespace"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"nodes", corev1.SchemeGroupVersion.WithKind("Node"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"pods", corev1.SchemeGroupVersion.WithKind("Pod"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"services", corev1.SchemeGroupVersion.WithKind("Service"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"endpointslices", discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"configmaps", corev1.SchemeGroupVersion.WithKind("ConfigMap"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"secrets", corev1.SchemeGroupVersion.WithKind("Secret"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"persistentvolumes", corev1.SchemeGroupVersion.WithKind("PersistentVolume"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"persistentvolumeclaims", corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"resourcequotas", corev1.SchemeGroupVersion.WithKind("ResourceQuota"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"limitranges", corev1.SchemeGroupVersion.WithKind("LimitRange"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().LimitRanges(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"events", corev1.SchemeGroupVersion.WithKind("Event"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"deployments", appsv1.SchemeGroupVersion.WithKind("Deployment"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"statefulsets", appsv1.SchemeGroupVersion.WithKind("StatefulSet"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"daemonsets", appsv1.SchemeGroupVersion.WithKind("DaemonSet"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"replicasets", appsv1.SchemeGroupVersion.WithKind("ReplicaSet"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"jobs", batchv1.SchemeGroupVersion.WithKind("Job"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"cronjobs", batchv1.SchemeGroupVersion.WithKind("CronJob"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"ingresses", networkingv1.SchemeGroupVersion.WithKind("Ingress"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"rolebindings", rbacv1.SchemeGroupVersion.WithKind("RoleBinding"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.Clientset.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"nodemetrics", metricsv1beta1.SchemeGroupVersion.WithKind("NodeMetrics"), false, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.MetricsClient.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+	{"podmetrics", metricsv1beta1.SchemeGroupVersion.WithKind("PodMetrics"), true, func(ctx context.Context, c *Client, namespace string) ([]runtime.Object, error) {
+		l, err := c.MetricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+		return fixtureItems(l, err)
+	}},
+}
+
+// fixtureItems extracts the items of a typed list
+func fixtureItems(list runtime.Object, err error) ([]runtime.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	return meta.ExtractList(list)
+}
+
+// RecordFixtures writes the cluster's resources to dir, one List file per kind,
+// in the format LoadFixtures reads. Secret values are replaced by RedactedValue.
+// Metrics are skipped with a warning when the metrics server is unavailable.
+func (c *Client) RecordFixtures(ctx context.Context, dir string, warn func(format string, args ...interface{})) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, kind := range fixtureKinds {
+		items, err := kind.list(ctx, c, "")
+		if err != nil {
+			if kind.gvk.Group == metricsv1beta1.GroupName {
+				warn("skipping %s: %v", kind.file, err)
+				continue
+			}
+			return fmt.Errorf("list %s: %w", kind.file, err)
+		}
+
+		list := &corev1.List{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"}}
+		for _, item := range items {
+			item = item.DeepCopyObject()
+			item.GetObjectKind().SetGroupVersionKind(kind.gvk)
+			sanitizeFixture(item)
+			raw, err := json.Marshal(item)
+			if err != nil {
+				return fmt.Errorf("encode %s: %w", kind.file, err)
+			}
+			list.Items = append(list.Items, runtime.RawExtension{Raw: raw})
+		}
+
+		raw, err := yaml.Marshal(list)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", kind.file, err)
+		}
+		header := []byte(fmt.Sprintf("# %d %s recorded by kubelens\n", len(items), kind.file))
+		if err := os.WriteFile(filepath.Join(dir, kind.file+".yaml"), bytes.Join([][]byte{header, raw}, nil), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sanitizeFixture drops server-managed noise and redacts secret values. The
+// last-applied-configuration annotation is dropped from Secrets as it holds
+// their data too.
+func sanitizeFixture(obj runtime.Object) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	for key := range secret.Data {
+		secret.Data[key] = []byte(RedactedValue)
+	}
+	for key := range secret.StringData {
+		secret.StringData[key] = RedactedValue
+	}
+	delete(secret.Annotations, corev1.LastAppliedConfigAnnotation)
+}
@@ -0,0 +1,204 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kubelens/internal/db"
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+const demoFixtures = "../../fixtures/demo"
+
+func TestDemoFixturesServeRoutes(t *testing.T) {
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := db.NewDiskSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c, WithSnapshotStore(store), WithChangeStore(&memoryChangeStore{}), WithUsageStore(db.NewMemoryUsageStore(db.DefaultMemoryUsageSamples)), WithCostStore(&memoryCostStore{}), WithAuditStore(&memoryAuditStore{}))
+
+	w := serve(t, h, http.MethodGet, "/summary")
+	var summary api.Summary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	// The crash looping api pod is in phase Running but not counted as running
+	want := api.Summary{TotalPods: 7, RunningPods: 5, TotalNodes: 3, ReadyNodes: 2, TotalServices: 3, TotalWorkloads: 4}
+	if summary != want {
+		t.Errorf("summary %+v, want %+v", summary, want)
+	}
+
+	for _, route := range h.Routes() {
+		if route.Method != http.MethodGet || strings.Contains(route.Path, ":") || route.ContentType != "" {
+			continue
+		}
+		target := route.Path
+		if route.Path == "/search" {
+			target += "?q=web"
+		}
+		if w := serve(t, h, http.MethodGet, target); w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d: %s", target, w.Code, w.Body)
+		}
+	}
+
+	if w := serve(t, h, http.MethodGet, "/pods/shop/db-0"); w.Code != http.StatusOK {
+		t.Errorf("pod detail: status %d: %s", w.Code, w.Body)
+	}
+
+	metrics, err := c.GetNodeMetrics(context.Background(), metav1.ListOptions{})
+	if err != nil || len(metrics) != 2 {
+		t.Errorf("node metrics %v, err %v", metrics, err)
+	}
+}
+
+func TestLoadFixturesFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"multi.yaml": `# comment only document
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: a
+`,
+		"nested/list.yml": `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata: {name: p1, namespace: a}
+- apiVersion: metrics.k8s.io/v1beta1
+  kind: PodMetrics
+  metadata: {name: p1, namespace: a}
+  containers: []
+`,
+		"node.json": `{"apiVersion": "v1", "kind": "Node", "metadata": {"name": "n1"}}`,
+		"README.md": "not a fixture",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, err := LoadFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *corev1.Namespace:
+			kinds = append(kinds, "Namespace")
+		case *corev1.ConfigMap:
+			kinds = append(kinds, "ConfigMap")
+		case *corev1.Pod:
+			kinds = append(kinds, "Pod")
+		case *corev1.Node:
+			kinds = append(kinds, "Node")
+		case *metricsv1beta1.PodMetrics:
+			kinds = append(kinds, "PodMetrics")
+		default:
+			t.Errorf("unexpected object %T", obj)
+		}
+	}
+	want := []string{"Namespace", "ConfigMap", "Pod", "PodMetrics", "Node"}
+	if !equalStrings(kinds, want) {
+		t.Errorf("kinds %v, want %v", kinds, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("kind: Unknown\napiVersion: example.com/v1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFixtures(dir); err == nil || !strings.Contains(err.Error(), "bad.yaml") {
+		t.Errorf("expected error naming bad.yaml, got %v", err)
+	}
+}
+
+func TestRecordFixturesRoundTrip(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "shop",
+			Name:      "api-credentials",
+			Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: `{"data":{"password":"aHVudGVyMg=="}}`,
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Data: map[string][]byte{"password": []byte("hunter2")},
+	}
+	live := newFakeClient(t,
+		testPod("shop", "web-1", corev1.PodRunning, map[string]string{"app": "web"}),
+		testDeployment("shop", "web", 1, 1),
+		testNode("worker-1", corev1.ConditionTrue, nil),
+		secret,
+		&metricsv1beta1.NodeMetrics{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}},
+	)
+
+	dir := t.TempDir()
+	var warnings []string
+	warn := func(format string, args ...interface{}) { warnings = append(warnings, format) }
+	if err := live.RecordFixtures(context.Background(), dir, warn); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "secrets.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "aHVudGVyMg") || strings.Contains(string(raw), "last-applied") {
+		t.Errorf("secret data leaked into fixture:\n%s", raw)
+	}
+
+	demo, err := NewDemoClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	pods, err := demo.GetPods(ctx, "shop", metav1.ListOptions{})
+	if err != nil || len(pods) != 1 || pods[0].Name != "web-1" || pods[0].Restarts != 3 {
+		t.Errorf("pods %+v, err %v", pods, err)
+	}
+	workloads, err := demo.GetWorkloads(ctx, "", metav1.ListOptions{})
+	if err != nil || len(workloads) != 1 {
+		t.Errorf("workloads %+v, err %v", workloads, err)
+	}
+	metrics, err := demo.GetNodeMetrics(ctx, metav1.ListOptions{})
+	if err != nil || len(metrics) != 1 {
+		t.Errorf("node metrics %+v, err %v", metrics, err)
+	}
+
+	recorded, err := demo.Clientset.CoreV1().Secrets("shop").Get(ctx, "api-credentials", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recorded.Data["password"]) != RedactedValue || len(recorded.ManagedFields) != 0 {
+		t.Errorf("secret not sanitized: %+v", recorded)
+	}
+}
@@ -0,0 +1,342 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Edge types of the resource relationship graph
+const (
+	EdgeOwns            = "owns"            // owner reference, owner -> dependent
+	EdgeSelects         = "selects"         // Service selector, Service -> Pod
+	EdgeRoutes          = "routes"          // Ingress backend, Ingress -> Service
+	EdgeMounts          = "mounts"          // volume, Pod -> PVC/ConfigMap/Secret
+	EdgeEnv             = "env"             // env/envFrom reference, Pod -> ConfigMap/Secret
+	EdgeImagePullSecret = "imagePullSecret" // registry credentials, Pod -> Secret
+	EdgeBinds           = "binds"           // claim binding, PVC -> PV
+	EdgeTLS             = "tls"             // TLS certificate, Ingress -> Secret
+)
+
+type resourceGraph struct {
+	nodes map[string]api.GraphNode
+	uids  map[string]string
+	edges map[string]api.GraphEdge
+}
+
+func newResourceGraph() *resourceGraph {
+	return &resourceGraph{
+		nodes: make(map[string]api.GraphNode),
+		uids:  make(map[string]string),
+		edges: make(map[string]api.GraphEdge),
+	}
+}
+
+func graphNodeID(kind, namespace, name string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s", kind, name)
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+func (g *resourceGraph) addObject(kind string, obj metav1.Object, status string) string {
+	id := graphNodeID(kind, obj.GetNamespace(), obj.GetName())
+	g.nodes[id] = api.GraphNode{
+		ID:        id,
+		Kind:      kind,
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Status:    status,
+	}
+	g.uids[string(obj.GetUID())] = id
+	return id
+}
+
+// ensureNode adds a placeholder for an object that is referenced but was not listed
+func (g *resourceGraph) ensureNode(kind, namespace, name string) string {
+	id := graphNodeID(kind, namespace, name)
+	if _, ok := g.nodes[id]; !ok {
+		g.nodes[id] = api.GraphNode{
+			ID:        id,
+			Kind:      kind,
+			Name:      name,
+			Namespace: namespace,
+			Status:    "Missing",
+		}
+	}
+	return id
+}
+
+func (g *resourceGraph) addEdge(source, target, edgeType string) {
+	key := source + "|" + target + "|" + edgeType
+	g.edges[key] = api.GraphEdge{
+		Source: source,
+		Target: target,
+		Type:   edgeType,
+	}
+}
+
+func (g *resourceGraph) addOwnerEdges(id string, refs []metav1.OwnerReference) {
+	for _, ref := range refs {
+		if owner, ok := g.uids[string(ref.UID)]; ok {
+			g.addEdge(owner, id, EdgeOwns)
+		}
+	}
+}
+
+func (g *resourceGraph) result() *api.ResourceGraph {
+	nodes := make([]api.GraphNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	edges := make([]api.GraphEdge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Source != edges[j].Source {
+			return edges[i].Source < edges[j].Source
+		}
+		if edges[i].Target != edges[j].Target {
+			return edges[i].Target < edges[j].Target
+		}
+		return edges[i].Type < edges[j].Type
+	})
+
+	return &api.ResourceGraph{Nodes: nodes, Edges: edges}
+}
+
+// GetResourceGraph builds the relationship graph of a namespace: owner references,
+// Service selectors, Ingress backends, volume claims and ConfigMap/Secret references
+func (c *Client) GetResourceGraph(ctx context.Context, namespace string) (*api.ResourceGraph, error) {
+	g := newResourceGraph()
+
+	// Owners are added before their dependents so that owner UIDs can be resolved
+	cronJobs, err := c.Clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range cronJobs.Items {
+		g.addObject("CronJob", &cronJobs.Items[i], "")
+	}
+
+	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		deploy := &deployments.Items[i]
+		g.addObject("Deployment", deploy, fmt.Sprintf("%d/%d", deploy.Status.ReadyReplicas, deploy.Status.Replicas))
+	}
+
+	statefulSets, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		sts := &statefulSets.Items[i]
+		g.addObject("StatefulSet", sts, fmt.Sprintf("%d/%d", sts.Status.ReadyReplicas, sts.Status.Replicas))
+	}
+
+	daemonSets, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		ds := &daemonSets.Items[i]
+		g.addObject("DaemonSet", ds, fmt.Sprintf("%d/%d", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled))
+	}
+
+	replicaSets, err := c.Clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+		id := g.addObject("ReplicaSet", rs, fmt.Sprintf("%d/%d", rs.Status.ReadyReplicas, rs.Status.Replicas))
+		g.addOwnerEdges(id, rs.OwnerReferences)
+	}
+
+	jobs, err := c.Clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		id := g.addObject("Job", job, fmt.Sprintf("%d/%d", job.Status.Succeeded, completions(job.Spec.Completions)))
+		g.addOwnerEdges(id, job.OwnerReferences)
+	}
+
+	pods, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podIDs := make([]string, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		podIDs[i] = g.addObject("Pod", pod, podStatus(*pod))
+	}
+
+	pvcs, err := c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		id := g.addObject("PersistentVolumeClaim", pvc, string(pvc.Status.Phase))
+		if pvc.Spec.VolumeName != "" {
+			g.addEdge(id, g.ensureNode("PersistentVolume", "", pvc.Spec.VolumeName), EdgeBinds)
+		}
+	}
+
+	pvs, err := c.Clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if _, ok := g.nodes[graphNodeID("PersistentVolume", "", pv.Name)]; ok {
+			g.addObject("PersistentVolume", pv, string(pv.Status.Phase))
+		}
+	}
+
+	configMaps, err := c.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range configMaps.Items {
+		g.addObject("ConfigMap", &configMaps.Items[i], "")
+	}
+
+	secrets, err := c.Clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range secrets.Items {
+		g.addObject("Secret", &secrets.Items[i], string(secrets.Items[i].Type))
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		g.addOwnerEdges(podIDs[i], pod.OwnerReferences)
+		addPodReferenceEdges(g, podIDs[i], pod)
+	}
+
+	services, err := c.Clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		id := g.addObject("Service", svc, string(svc.Spec.Type))
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		for j := range pods.Items {
+			if pods.Items[j].Namespace == svc.Namespace && selector.Matches(labels.Set(pods.Items[j].Labels)) {
+				g.addEdge(id, podIDs[j], EdgeSelects)
+			}
+		}
+	}
+
+	ingresses, err := c.Clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range ingresses.Items {
+		ing := &ingresses.Items[i]
+		id := g.addObject("Ingress", ing, "")
+		if backend := ing.Spec.DefaultBackend; backend != nil && backend.Service != nil {
+			g.addEdge(id, g.ensureNode("Service", ing.Namespace, backend.Service.Name), EdgeRoutes)
+		}
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service != nil {
+					g.addEdge(id, g.ensureNode("Service", ing.Namespace, path.Backend.Service.Name), EdgeRoutes)
+				}
+			}
+		}
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName != "" {
+				g.addEdge(id, g.ensureNode("Secret", ing.Namespace, tls.SecretName), EdgeTLS)
+			}
+		}
+	}
+
+	return g.result(), nil
+}
+
+// addPodReferenceEdges links a pod to the claims, ConfigMaps and Secrets it
+// consumes, including the env references of its init and ephemeral containers
+func addPodReferenceEdges(g *resourceGraph, podID string, pod *corev1.Pod) {
+	ns := pod.Namespace
+
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			g.addEdge(podID, g.ensureNode("PersistentVolumeClaim", ns, volume.PersistentVolumeClaim.ClaimName), EdgeMounts)
+		case volume.ConfigMap != nil:
+			g.addEdge(podID, g.ensureNode("ConfigMap", ns, volume.ConfigMap.Name), EdgeMounts)
+		case volume.Secret != nil:
+			g.addEdge(podID, g.ensureNode("Secret", ns, volume.Secret.SecretName), EdgeMounts)
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					g.addEdge(podID, g.ensureNode("ConfigMap", ns, source.ConfigMap.Name), EdgeMounts)
+				}
+				if source.Secret != nil {
+					g.addEdge(podID, g.ensureNode("Secret", ns, source.Secret.Name), EdgeMounts)
+				}
+			}
+		}
+	}
+
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		g.addEdge(podID, g.ensureNode("Secret", ns, secret.Name), EdgeImagePullSecret)
+	}
+
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, ephemeral := range pod.Spec.EphemeralContainers {
+		containers = append(containers, corev1.Container{Env: ephemeral.Env, EnvFrom: ephemeral.EnvFrom})
+	}
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				g.addEdge(podID, g.ensureNode("ConfigMap", ns, envFrom.ConfigMapRef.Name), EdgeEnv)
+			}
+			if envFrom.SecretRef != nil {
+				g.addEdge(podID, g.ensureNode("Secret", ns, envFrom.SecretRef.Name), EdgeEnv)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				g.addEdge(podID, g.ensureNode("ConfigMap", ns, ref.Name), EdgeEnv)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				g.addEdge(podID, g.ensureNode("Secret", ns, ref.Name), EdgeEnv)
+			}
+		}
+	}
+}
+
+func completions(c *int32) int32 {
+	if c == nil {
+		return 1
+	}
+	return *c
+}
@@ -2,156 +2,337 @@ package k8s
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"time"
+
+	"kubelens/internal/db"
+	"kubelens/pkg/api"
 
 	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-var K8sClient *Client
+// Handler serves the Kubernetes API routes from a Client
+type Handler struct {
+	client     *Client
+	snapshots  SnapshotStore
+	changes    ChangeStore
+	protected  map[string]bool
+	templates  []api.NamespaceTemplate
+	roles      map[string]bool
+	usage      UsageStore
+	costModel  api.CostModel
+	costs      CostStore
+	scanRules  map[string]string
+	audit      AuditStore
+	proxyToken string
+	copyLimit  int64
+}
+
+// HandlerOption enables optional Handler features
+type HandlerOption func(*Handler)
+
+// WithSnapshotStore enables the snapshot endpoints
+func WithSnapshotStore(store SnapshotStore) HandlerOption {
+	return func(h *Handler) { h.snapshots = store }
+}
+
+// WithChangeStore enables the timeline endpoint
+func WithChangeStore(store ChangeStore) HandlerOption {
+	return func(h *Handler) { h.changes = store }
+}
+
+// WithUsageStore enables the right-sizing recommendations
+func WithUsageStore(store UsageStore) HandlerOption {
+	return func(h *Handler) { h.usage = store }
+}
+
+// WithCostModel replaces DefaultCostModel
+func WithCostModel(model api.CostModel) HandlerOption {
+	return func(h *Handler) { h.costModel = model }
+}
+
+// WithCostStore enables the daily cost history and its CSV export
+func WithCostStore(store CostStore) HandlerOption {
+	return func(h *Handler) { h.costs = store }
+}
+
+// WithAuditStore enables the audit log, which orphan deletion requires
+func WithAuditStore(store AuditStore) HandlerOption {
+	return func(h *Handler) { h.audit = store }
+}
+
+// WithProxyToken enables the pod and service proxy for requests bearing token
+func WithProxyToken(token string) HandlerOption {
+	return func(h *Handler) { h.proxyToken = token }
+}
+
+// WithCopyLimit sets the most bytes copied to or from a container per request
+func WithCopyLimit(bytes int64) HandlerOption {
+	return func(h *Handler) { h.copyLimit = bytes }
+}
+
+// WithScanRules changes the severity of scan rules or turns them off, see ParseScanRules
+func WithScanRules(config map[string]string) HandlerOption {
+	return func(h *Handler) { h.scanRules = config }
+}
+
+// WithProtectedNamespaces replaces DefaultProtectedNamespaces as the namespaces that cannot be deleted
+func WithProtectedNamespaces(names []string) HandlerOption {
+	return func(h *Handler) { h.protected = stringSet(names) }
+}
+
+// WithNamespaceTemplates replaces DefaultNamespaceTemplates
+func WithNamespaceTemplates(templates []api.NamespaceTemplate) HandlerOption {
+	return func(h *Handler) { h.templates = templates }
+}
+
+// WithBindableClusterRoles replaces DefaultBindableClusterRoles as the
+// ClusterRoles a new namespace may bind
+func WithBindableClusterRoles(names []string) HandlerOption {
+	return func(h *Handler) { h.roles = stringSet(names) }
+}
+
+// NewHandler returns a Handler backed by client
+func NewHandler(client *Client, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		client:    client,
+		protected: stringSet(DefaultProtectedNamespaces),
+		templates: DefaultNamespaceTemplates,
+		roles:     stringSet(DefaultBindableClusterRoles),
+		costModel: DefaultCostModel,
+		copyLimit: DefaultCopyLimit,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
 
-func GetNamespacesHandlerFunc(c *gin.Context) {
+func stringSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func (h *Handler) GetNamespacesHandlerFunc(c *gin.Context) {
 	log.Printf("Received request for namespaces")
-	namespaces, err := K8sClient.GetNamespaces(context.Background())
+	q, err := parseListQuery(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	namespaces, err := h.client.GetNamespaces(context.Background(), q.listOptions())
 	if err != nil {
 		log.Printf("Error getting namespaces: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	log.Printf("Successfully retrieved %d namespaces", len(namespaces))
-	c.JSON(http.StatusOK, gin.H{"items": namespaces})
+	respondList(c, namespaces, q)
 }
 
-func GetWorkloadsHandlerFunc(c *gin.Context) {
+func (h *Handler) GetWorkloadsHandlerFunc(c *gin.Context) {
 	namespace := c.DefaultQuery("namespace", "")
-	workloads, err := K8sClient.GetWorkloads(context.Background(), namespace)
+	q, err := parseListQuery(c, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": workloads})
+	workloads, err := h.client.GetWorkloads(context.Background(), namespace, q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, workloads, q)
 }
 
-func GetPodsHandlerFunc(c *gin.Context) {
+func (h *Handler) GetPodsHandlerFunc(c *gin.Context) {
 	namespace := c.DefaultQuery("namespace", "")
-	pods, err := K8sClient.GetPods(context.Background(), namespace)
+	q, err := parseListQuery(c, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pods, err := h.client.GetPods(context.Background(), namespace, q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": pods})
+	respondList(c, pods, q)
 }
 
-func GetServicesHandlerFunc(c *gin.Context) {
+func (h *Handler) GetServicesHandlerFunc(c *gin.Context) {
 	namespace := c.DefaultQuery("namespace", "")
-	svcs, err := K8sClient.GetServices(context.Background(), namespace)
+	q, err := parseListQuery(c, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svcs, err := h.client.GetServices(context.Background(), namespace, q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": svcs})
+	respondList(c, svcs, q)
 }
 
-func GetNodesHandlerFunc(c *gin.Context) {
-	nodes, err := K8sClient.GetNodes(context.Background())
+func (h *Handler) GetNodesHandlerFunc(c *gin.Context) {
+	q, err := parseListQuery(c, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	nodes, err := h.client.GetNodes(context.Background(), q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": nodes})
+	respondList(c, nodes, q)
 }
 
-func GetEventsHandlerFunc(c *gin.Context) {
+func (h *Handler) GetEventsHandlerFunc(c *gin.Context) {
 	namespace := c.DefaultQuery("namespace", "")
-	events, err := K8sClient.GetEvents(context.Background(), namespace)
+	q, err := parseListQuery(c, "age")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, err := h.client.GetEvents(context.Background(), namespace, q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": events})
+	respondList(c, events, q)
 }
 
-func GetNodeMetricsHandlerFunc(c *gin.Context) {
+func (h *Handler) GetNodeMetricsHandlerFunc(c *gin.Context) {
 	log.Printf("Received request for node metrics")
-	metrics, err := K8sClient.GetNodeMetrics(context.Background())
+	q, err := parseListQuery(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	metrics, err := h.client.GetNodeMetrics(context.Background(), q.listOptions())
 	if err != nil {
 		log.Printf("Error getting node metrics: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve node metrics. The metrics server may not be available or not properly configured. Please check your Kubernetes cluster setup."})
 		return
 	}
 	log.Printf("Successfully retrieved %d node metrics", len(metrics))
-	c.JSON(http.StatusOK, gin.H{"items": metrics})
+	respondList(c, metrics, q)
 }
 
-func GetPodMetricsHandlerFunc(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"items": []map[string]interface{}{{}}})
+func (h *Handler) GetPodMetricsHandlerFunc(c *gin.Context) {
+	namespace := c.DefaultQuery("namespace", "")
+	q, err := parseListQuery(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	metrics, err := h.client.GetPodMetrics(context.Background(), namespace, q.listOptions())
+	if err != nil {
+		log.Printf("Error getting pod metrics: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pod metrics. The metrics server may not be available or not properly configured. Please check your Kubernetes cluster setup."})
+		return
+	}
+	respondList(c, metrics, q)
 }
 
-func GetConfigMapsHandlerFunc(c *gin.Context) {
+func (h *Handler) GetConfigMapsHandlerFunc(c *gin.Context) {
 	namespace := c.DefaultQuery("namespace", "")
-	items, err := K8sClient.GetConfigMaps(context.Background(), namespace)
+	q, err := parseListQuery(c, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	items, err := h.client.GetConfigMaps(context.Background(), namespace, q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, items, q)
 }
 
-func GetPVsHandlerFunc(c *gin.Context) {
-	items, err := K8sClient.GetPVs(context.Background())
+func (h *Handler) GetPVsHandlerFunc(c *gin.Context) {
+	q, err := parseListQuery(c, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	items, err := h.client.GetPVs(context.Background(), q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, items, q)
 }
 
-func GetPVCsHandlerFunc(c *gin.Context) {
+func (h *Handler) GetPVCsHandlerFunc(c *gin.Context) {
 	namespace := c.DefaultQuery("namespace", "")
-	items, err := K8sClient.GetPVCs(context.Background(), namespace)
+	q, err := parseListQuery(c, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	items, err := h.client.GetPVCs(context.Background(), namespace, q.listOptions())
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, items, q)
+}
+
+// HealthHandlerFunc reports that the server is up
+func HealthHandlerFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, api.HealthResponse{Status: "ok"})
 }
 
-// getNotifications returns empty list for now to avoid 404 noise
-func GetSummaryHandlerFunc(c *gin.Context) {
+func (h *Handler) GetSummaryHandlerFunc(c *gin.Context) {
 	// Get all pods to calculate summary
-	pods, err := K8sClient.GetPods(context.Background(), "")
+	pods, err := h.client.GetPods(context.Background(), "", metav1.ListOptions{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Get all nodes
-	nodes, err := K8sClient.GetNodes(context.Background())
+	nodes, err := h.client.GetNodes(context.Background(), metav1.ListOptions{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Get all services
-	services, err := K8sClient.GetServices(context.Background(), "")
+	services, err := h.client.GetServices(context.Background(), "", metav1.ListOptions{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Get all workloads
-	workloads, err := K8sClient.GetWorkloads(context.Background(), "")
+	workloads, err := h.client.GetWorkloads(context.Background(), "", metav1.ListOptions{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Calculate running pods
+	// Calculate running pods from the kubectl status, so crash looping pods do not count
 	runningPods := 0
 	for _, pod := range pods {
-		if pod["status"] == "Running" {
+		if pod.Status == "Running" {
 			runningPods++
 		}
 	}
@@ -159,37 +340,282 @@ func GetSummaryHandlerFunc(c *gin.Context) {
 	// Calculate ready nodes
 	readyNodes := 0
 	for _, node := range nodes {
-		if node["status"] == "Ready" {
+		if node.Status == "Ready" {
 			readyNodes++
 		}
 	}
 
-	summary := gin.H{
-		"totalPods":      len(pods),
-		"runningPods":    runningPods,
-		"totalNodes":     len(nodes),
-		"readyNodes":     readyNodes,
-		"totalServices":  len(services),
-		"totalWorkloads": len(workloads),
+	summary := api.Summary{
+		TotalPods:      len(pods),
+		RunningPods:    runningPods,
+		TotalNodes:     len(nodes),
+		ReadyNodes:     readyNodes,
+		TotalServices:  len(services),
+		TotalWorkloads: len(workloads),
 	}
 
 	c.JSON(http.StatusOK, summary)
 }
 
-func GetNotificationsHandlerFunc(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"items": []interface{}{}})
+// GetNotificationsHandlerFunc returns empty list for now to avoid 404 noise
+func (h *Handler) GetNotificationsHandlerFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, api.ListResponse[api.Notification]{Items: []api.Notification{}})
+}
+
+// GetNamespaceDetailHandlerFunc returns the pods, workloads, quotas and limit ranges of a namespace
+func (h *Handler) GetNamespaceDetailHandlerFunc(c *gin.Context) {
+	detail, err := h.client.GetNamespaceDetail(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// CreateNamespaceHandlerFunc creates a namespace with its default quota, limit range and role bindings
+func (h *Handler) CreateNamespaceHandlerFunc(c *gin.Context) {
+	var req api.NamespaceCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var template *api.NamespaceTemplate
+	if req.Template != "" {
+		for i := range h.templates {
+			if h.templates[i].Name == req.Template {
+				template = &h.templates[i]
+			}
+		}
+		if template == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown namespace template: %s", req.Template)})
+			return
+		}
+	}
+
+	result, err := h.client.CreateNamespace(c.Request.Context(), req, template, h.roles)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidNamespaceRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case apierrors.IsAlreadyExists(err):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// ListNamespaceTemplatesHandlerFunc returns the templates a new namespace can start from
+func (h *Handler) ListNamespaceTemplatesHandlerFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, api.ListResponse[api.NamespaceTemplate]{Items: h.templates, Total: len(h.templates)})
+}
+
+// GetNamespaceContentsHandlerFunc lists what deleting a namespace would remove
+func (h *Handler) GetNamespaceContentsHandlerFunc(c *gin.Context) {
+	name := c.Param("name")
+	contents, err := h.client.NamespaceContents(c.Request.Context(), name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	contents.Protected = h.protected[name]
+	c.JSON(http.StatusOK, contents)
+}
+
+// DeleteNamespaceHandlerFunc deletes a namespace once the caller confirms by
+// repeating its name; protected namespaces are refused
+func (h *Handler) DeleteNamespaceHandlerFunc(c *gin.Context) {
+	name := c.Param("name")
+	if h.protected[name] {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("namespace %s is protected", name)})
+		return
+	}
+	contents, err := h.client.NamespaceContents(c.Request.Context(), name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("confirm") != name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(
+			"namespace %s contains %d objects; review GET /namespaces/%s/contents and repeat the name in confirm to delete it",
+			name, contents.Total, name)})
+		return
+	}
+
+	if err := h.client.DeleteNamespace(c.Request.Context(), name); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Deleted namespace %s with %d objects", name, contents.Total)
+	c.JSON(http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("Namespace %s and its %d objects are being deleted", name, contents.Total)})
+}
+
+// GetNodeDetailHandlerFunc returns the capacity, conditions and pods of a single node
+func (h *Handler) GetNodeDetailHandlerFunc(c *gin.Context) {
+	detail, err := h.client.GetNodeDetail(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// GetServiceDetailHandlerFunc returns a service with its resolved ports, endpoints and warnings
+func (h *Handler) GetServiceDetailHandlerFunc(c *gin.Context) {
+	detail, err := h.client.GetServiceDetail(c.Request.Context(), c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// GetPodDetailHandlerFunc returns the describe-style detail of a single pod
+func (h *Handler) GetPodDetailHandlerFunc(c *gin.Context) {
+	namespace := c.Param("namespace")
+	podName := c.Param("podName")
+
+	detail, err := h.client.GetPodDetail(c.Request.Context(), namespace, podName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// GetPodDiagnosisHandlerFunc explains why a pod is unhealthy
+func (h *Handler) GetPodDiagnosisHandlerFunc(c *gin.Context) {
+	diagnosis, err := h.client.DiagnosePod(c.Request.Context(), c.Param("namespace"), c.Param("podName"))
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if diagnosis.Problems == nil {
+		diagnosis.Problems = []api.Problem{}
+	}
+	c.JSON(http.StatusOK, diagnosis)
+}
+
+// GetPodSchedulingHandlerFunc explains on which nodes a pending pod does not fit and why
+func (h *Handler) GetPodSchedulingHandlerFunc(c *gin.Context) {
+	explanation, err := h.client.ExplainScheduling(c.Request.Context(), c.Param("namespace"), c.Param("podName"))
+	if err != nil {
+		switch {
+		case apierrors.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrNotPending):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, explanation)
+}
+
+// GetProblemsHandlerFunc lists the problems of every pod, most severe first
+func (h *Handler) GetProblemsHandlerFunc(c *gin.Context) {
+	q, err := parseListQuery(c, "severity,namespace,pod")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	problems, err := h.client.GetProblems(c.Request.Context(), c.Query("namespace"))
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, problems, q)
+}
+
+// GetResourceGraphHandlerFunc returns the resource relationship graph of a namespace
+func (h *Handler) GetResourceGraphHandlerFunc(c *gin.Context) {
+	namespace := c.DefaultQuery("namespace", "")
+	graph, err := h.client.GetResourceGraph(context.Background(), namespace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, graph)
+}
+
+// SearchHandlerFunc searches names, labels and annotations across all resource kinds
+func (h *Handler) SearchHandlerFunc(c *gin.Context) {
+	q := c.Query("q")
+	labelSelector := c.Query("labelSelector")
+	if strings.TrimSpace(q) == "" && labelSelector == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q or labelSelector is required"})
+		return
+	}
+
+	limit := 50
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsed, err := strconv.Atoi(limitParam); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	results, err := h.client.Search(context.Background(), q, labelSelector, limit)
+	if err != nil {
+		if errors.Is(err, ErrInvalidQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if results == nil {
+		results = []api.SearchResult{}
+	}
+	c.JSON(http.StatusOK, api.ListResponse[api.SearchResult]{Items: results, Total: len(results)})
 }
 
 // GetPodLogsHandlerFunc 处理获取Pod日志的请求
-func GetPodLogsHandlerFunc(c *gin.Context) {
+// follow=true 时以 text/plain 持续推送日志流
+func (h *Handler) GetPodLogsHandlerFunc(c *gin.Context) {
 	namespace := c.Param("namespace")
 	podName := c.Param("podName")
-	
+
 	if namespace == "" || podName == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "namespace and podName are required"})
 		return
 	}
-	
+
 	// 获取tailLines参数，默认获取最新100行
 	tailLines := int64(100)
 	if tailParam := c.Query("tail"); tailParam != "" {
@@ -197,18 +623,71 @@ func GetPodLogsHandlerFunc(c *gin.Context) {
 			tailLines = parsed
 		}
 	}
-	
-	logs, err := K8sClient.GetPodLogs(context.Background(), namespace, podName, &tailLines)
+
+	if c.Query("follow") == "true" {
+		options := &corev1.PodLogOptions{
+			Container: c.Query("container"),
+			Follow:    true,
+			TailLines: &tailLines,
+		}
+		stream, err := h.client.StreamPodLogs(c.Request.Context(), namespace, podName, options)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer stream.Close()
+
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Status(http.StatusOK)
+		buf := make([]byte, 4096)
+		c.Stream(func(w io.Writer) bool {
+			n, err := stream.Read(buf)
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return false
+				}
+			}
+			return err == nil
+		})
+		return
+	}
+
+	logs, err := h.client.GetPodLogs(c.Request.Context(), namespace, podName, c.Query("container"), &tailLines)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.PodLogs{Logs: logs})
+}
+
+// WatchHandlerFunc streams changes of one kind as server-sent events
+func (h *Handler) WatchHandlerFunc(c *gin.Context) {
+	kind := c.Query("kind")
+	namespace := c.DefaultQuery("namespace", "")
+
+	events, err := h.client.Watch(c.Request.Context(), kind, namespace)
 	if err != nil {
+		if errors.Is(err, ErrUnsupportedKind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	
-	c.JSON(http.StatusOK, gin.H{"logs": logs})
+
+	c.Stream(func(w io.Writer) bool {
+		event, ok := <-events
+		if !ok {
+			return false
+		}
+		c.SSEvent(event.Type, event)
+		return true
+	})
 }
 
 // RestartWorkloadHandlerFunc 处理重启工作负载的请求
-func RestartWorkloadHandlerFunc(c *gin.Context) {
+func (h *Handler) RestartWorkloadHandlerFunc(c *gin.Context) {
 	namespace := c.Param("namespace")
 	name := c.Param("name")
 	kind := c.Param("kind")
@@ -231,11 +710,674 @@ func RestartWorkloadHandlerFunc(c *gin.Context) {
 	}
 
 	// 重启工作负载
-	err := K8sClient.RestartWorkload(context.Background(), namespace, name, kind)
+	err := h.client.RestartWorkload(context.Background(), namespace, name, kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("Successfully restarted %s %s in namespace %s", kind, name, namespace)})
+}
+
+// snapshotStore returns the snapshot store, answering 503 if snapshots are disabled
+func (h *Handler) snapshotStore(c *gin.Context) (SnapshotStore, bool) {
+	if h.snapshots == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "snapshots are not configured"})
+		return nil, false
+	}
+	return h.snapshots, true
+}
+
+// loadSnapshot reads the snapshot named by a path or query parameter
+func (h *Handler) loadSnapshot(c *gin.Context, store SnapshotStore, idParam string) (api.SnapshotInfo, []snapshotObject, bool) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid snapshot id: %s", idParam)})
+		return api.SnapshotInfo{}, nil, false
+	}
+	info, data, err := store.GetSnapshot(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return info, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return info, nil, false
+	}
+	objects, err := decodeSnapshot(data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return info, nil, false
+	}
+	return info, objects, true
+}
+
+// ListSnapshotsHandlerFunc lists the stored snapshots, newest first
+func (h *Handler) ListSnapshotsHandlerFunc(c *gin.Context) {
+	store, ok := h.snapshotStore(c)
+	if !ok {
+		return
+	}
+	q, err := parseListQuery(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	snapshots, err := store.ListSnapshots(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	respondList(c, snapshots, q)
+}
+
+// CreateSnapshotHandlerFunc takes an on-demand snapshot
+func (h *Handler) CreateSnapshotHandlerFunc(c *gin.Context) {
+	store, ok := h.snapshotStore(c)
+	if !ok {
+		return
+	}
+	info, err := h.client.TakeSnapshot(c.Request.Context(), store, SnapshotManual)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// ExportSnapshotHandlerFunc downloads a snapshot as a tarball of YAML manifests
+func (h *Handler) ExportSnapshotHandlerFunc(c *gin.Context) {
+	store, ok := h.snapshotStore(c)
+	if !ok {
+		return
+	}
+	info, objects, ok := h.loadSnapshot(c, store, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	root := fmt.Sprintf("snapshot-%d-%s", info.ID, info.CreatedAt.Format("20060102T150405Z"))
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", root+".tar.gz"))
+	c.Status(http.StatusOK)
+	if err := writeSnapshotTar(c.Writer, root, info.CreatedAt, objects); err != nil {
+		log.Printf("Error exporting snapshot %d: %v", info.ID, err)
+	}
+}
+
+// DiffSnapshotHandlerFunc compares a snapshot with a later one, or with the live cluster if to is empty
+func (h *Handler) DiffSnapshotHandlerFunc(c *gin.Context) {
+	store, ok := h.snapshotStore(c)
+	if !ok {
+		return
+	}
+	fromInfo, from, ok := h.loadSnapshot(c, store, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var toInfo api.SnapshotInfo
+	var to []snapshotObject
+	if toParam := c.Query("to"); toParam != "" {
+		if toInfo, to, ok = h.loadSnapshot(c, store, toParam); !ok {
+			return
+		}
+	} else {
+		var err error
+		if to, err = h.client.CaptureSnapshot(c.Request.Context()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		toInfo = api.SnapshotInfo{CreatedAt: time.Now().UTC(), Reason: "live", Objects: len(to)}
+	}
+
+	filter := snapshotFilter{kinds: splitSet(strings.ToLower(c.Query("kind"))), namespaces: splitSet(c.Query("namespace"))}
+	created, deleted, changed, err := diffSnapshots(from, to, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, api.SnapshotDiff{From: fromInfo, To: toInfo, Created: created, Deleted: deleted, Changed: changed})
+}
+
+// splitSet turns a comma separated parameter into a set, nil if empty
+func splitSet(param string) map[string]bool {
+	if param == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, value := range strings.Split(param, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
+
+// GetTimelineHandlerFunc merges the recorded changes and archived events of a namespace or object
+func (h *Handler) GetTimelineHandlerFunc(c *gin.Context) {
+	if h.changes == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "change tracking requires a database"})
+		return
+	}
+	q, err := parseListQuery(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	since, err := time.ParseDuration(c.DefaultQuery("since", "24h"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid since: %v", err)})
+		return
+	}
+
+	kind := c.Query("kind")
+	if k, ok := searchKinds[strings.ToLower(kind)]; ok {
+		kind = k
+	}
+	filter := db.TimelineFilter{
+		Namespace: c.Query("namespace"),
+		Kind:      kind,
+		Name:      c.Query("name"),
+		Since:     time.Now().Add(-since),
+	}
+	// The store pages the timeline, so only its own filter and order apply
+	if len(q.Sort) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the timeline is always sorted newest first"})
+		return
+	}
+	switch strings.Join(q.Status, ",") {
+	case "", "change,event", "event,change":
+	case TimelineChange, TimelineEvent:
+		filter.Type = q.Status[0]
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be change or event"})
+		return
+	}
+	entries, total, err := Timeline(c.Request.Context(), h.changes, filter, q.Limit, q.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	next := ""
+	if end := q.Offset + len(entries); q.Limit > 0 && end < total {
+		next = encodeContinue(end)
+	}
+	c.JSON(http.StatusOK, api.ListResponse[api.TimelineEntry]{Items: entries, Total: total, Continue: next})
+}
+
+// GetRecommendationsHandlerFunc suggests requests and limits from the recorded usage history
+func (h *Handler) GetRecommendationsHandlerFunc(c *gin.Context) {
+	if h.usage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "usage history is not configured"})
+		return
+	}
+	q, err := parseListQuery(c, "namespace,workload,container")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	window, err := time.ParseDuration(c.DefaultQuery("window", "168h"))
+	if err != nil || window <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid window: %s", c.Query("window"))})
+		return
+	}
+	recommendations, err := h.client.Recommend(c.Request.Context(), h.usage, c.Query("namespace"), window)
+	if err != nil {
+		c.JSON(listErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, recommendations, q)
+}
+
+// GetCostModelHandlerFunc returns the prices costs are allocated with
+func (h *Handler) GetCostModelHandlerFunc(c *gin.Context) {
+	c.JSON(http.StatusOK, h.costModel)
+}
+
+// GetCostsHandlerFunc returns the current hourly cost rolled up by namespace, workload or label
+func (h *Handler) GetCostsHandlerFunc(c *gin.Context) {
+	q, err := parseListQuery(c, "-totalCost")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	costs, err := h.client.AllocateCosts(c.Request.Context(), h.costModel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	allocations, err := GroupCosts(costs, c.Query("groupBy"), c.Query("label"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, allocations, q)
+}
+
+// GetDailyCostsHandlerFunc returns the recorded daily costs rolled up by namespace, workload or label
+func (h *Handler) GetDailyCostsHandlerFunc(c *gin.Context) {
+	q, err := parseListQuery(c, "date,-totalCost")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allocations, ok := h.dailyCosts(c)
+	if !ok {
+		return
+	}
+	respondList(c, allocations, q)
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Successfully restarted %s %s in namespace %s", kind, name, namespace)})
+// ExportCostsHandlerFunc downloads the daily costs as CSV
+func (h *Handler) ExportCostsHandlerFunc(c *gin.Context) {
+	allocations, ok := h.dailyCosts(c)
+	if !ok {
+		return
+	}
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "costs.csv"))
+	c.Status(http.StatusOK)
+	if err := WriteCostsCSV(c.Writer, allocations); err != nil {
+		log.Printf("Error exporting costs: %v", err)
+	}
+}
+
+// dailyCosts reads and groups the daily costs between the from and to dates,
+// by default the last 30 days, answering the request itself on errors
+func (h *Handler) dailyCosts(c *gin.Context) ([]api.CostAllocation, bool) {
+	if h.costs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "cost history requires a database"})
+		return nil, false
+	}
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	for _, param := range []struct {
+		name string
+		date *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if value := c.Query(param.name); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: %s, want YYYY-MM-DD", param.name, value)})
+				return nil, false
+			}
+			*param.date = date
+		}
+	}
+	costs, err := h.costs.ListDailyCosts(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	allocations, err := GroupCosts(costs, c.Query("groupBy"), c.Query("label"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return allocations, true
+}
+
+// ScanWorkloadsHandlerFunc audits workloads against the best-practice and security rules
+func (h *Handler) ScanWorkloadsHandlerFunc(c *gin.Context) {
+	report, err := h.client.ScanWorkloads(c.Request.Context(), c.Query("namespace"), h.scanRules)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetDeprecatedAPIsHandlerFunc reports the APIs and objects that break when upgrading to the target version
+func (h *Handler) GetDeprecatedAPIsHandlerFunc(c *gin.Context) {
+	report, err := h.client.FindDeprecatedAPIs(c.Request.Context(), c.Query("target"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidTargetVersion) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListOrphansHandlerFunc lists the resources nothing appears to use
+func (h *Handler) ListOrphansHandlerFunc(c *gin.Context) {
+	q, err := parseListQuery(c, "kind,namespace,name")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orphans, err := h.client.FindOrphans(c.Request.Context(), c.Query("namespace"), h.protected)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if kind := c.Query("kind"); kind != "" {
+		if k, ok := searchKinds[strings.ToLower(kind)]; ok {
+			kind = k
+		}
+		filtered := []api.OrphanedResource{}
+		for _, o := range orphans {
+			if o.Kind == kind {
+				filtered = append(filtered, o)
+			}
+		}
+		orphans = filtered
+	}
+	respondList(c, orphans, q)
+}
+
+// DeleteOrphansHandlerFunc deletes orphaned resources in bulk. It is a dry run
+// unless dryRun=false, and every deletion is written to the audit log before
+// it happens; nothing is deleted when the audit log cannot be written.
+func (h *Handler) DeleteOrphansHandlerFunc(c *gin.Context) {
+	var req api.OrphanDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dryRun := c.DefaultQuery("dryRun", "true") != "false"
+	if !dryRun && h.audit == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "deleting orphans requires a database for the audit log"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	result, err := h.client.CheckOrphans(ctx, req.Items, h.protected)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if h.audit == nil {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	now := time.Now().UTC()
+	entry := func(ref api.ObjectRef, errMsg string) api.AuditEntry {
+		return api.AuditEntry{Time: now, Actor: c.ClientIP(), Action: AuditDelete, Kind: ref.Kind,
+			Namespace: ref.Namespace, Name: ref.Name, DryRun: dryRun, Detail: "orphan cleanup", Error: errMsg}
+	}
+	var entries []api.AuditEntry
+	for _, ref := range result.Deleted {
+		entries = append(entries, entry(ref, ""))
+	}
+	for _, failed := range result.Failed {
+		entries = append(entries, entry(failed.ObjectRef, failed.Error))
+	}
+	if err := h.audit.RecordAudit(ctx, entries); err != nil {
+		log.Printf("Error recording audit log: %v", err)
+		if !dryRun {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("nothing was deleted: recording the audit log failed: %v", err)})
+			return
+		}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// Deletions that fail after they were audited are recorded again with their error
+	failed := h.client.DeleteOrphans(ctx, result)
+	if len(failed) > 0 {
+		now = time.Now().UTC()
+		entries = nil
+		for _, f := range failed {
+			entries = append(entries, entry(f.ObjectRef, f.Error))
+		}
+		if err := h.audit.RecordAudit(context.WithoutCancel(ctx), entries); err != nil {
+			log.Printf("Error recording audit log: %v", err)
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListAuditHandlerFunc returns the audit log, newest first
+func (h *Handler) ListAuditHandlerFunc(c *gin.Context) {
+	if h.audit == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the audit log requires a database"})
+		return
+	}
+	q, err := parseListQuery(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	since, err := time.ParseDuration(c.DefaultQuery("since", "168h"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid since: %v", err)})
+		return
+	}
+	entries, err := h.audit.ListAudit(c.Request.Context(), time.Now().Add(-since))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondList(c, entries, q)
+}
+
+// proxyTokenCookie carries the proxy token to the pages and assets of a proxied
+// UI once it was opened with ?token=
+const proxyTokenCookie = "kubelens_proxy_token"
+
+// ProxyPodHandlerFunc forwards a request to a port of a pod
+func (h *Handler) ProxyPodHandlerFunc(c *gin.Context) {
+	h.proxy(c, "pods")
+}
+
+// ProxyServiceHandlerFunc forwards a request to a port of a service
+func (h *Handler) ProxyServiceHandlerFunc(c *gin.Context) {
+	h.proxy(c, "services")
+}
+
+func (h *Handler) proxy(c *gin.Context, resource string) {
+	if !h.authorizeProxy(c) {
+		return
+	}
+	proxy, err := h.client.Proxy(resource, c.Param("namespace"), c.Param("name"), c.Param("port"), c.Param("path"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrProxyUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidProxyTarget):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	log.Printf("Proxying %s %s to %s %s/%s:%s", c.Request.Method, c.Param("path"), resource, c.Param("namespace"), c.Param("name"), c.Param("port"))
+	proxy.ServeHTTP(proxyWriter{c.Writer, c.Writer}, c.Request)
+}
+
+// proxyWriter hides gin's CloseNotify, which panics on writers without it, from
+// the reverse proxy while keeping Flush for streamed responses
+type proxyWriter struct {
+	http.ResponseWriter
+	http.Flusher
+}
+
+// authorizeProxy checks the proxy token from the Authorization header, the
+// token query parameter or the cookie set by an earlier ?token= request, and
+// strips it so it never reaches the pod. It answers the request itself when
+// the proxy is disabled or the token is wrong.
+func (h *Handler) authorizeProxy(c *gin.Context) bool {
+	if h.proxyToken == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the proxy is disabled, set PROXY_TOKEN to enable it"})
+		return false
+	}
+	req := c.Request
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	query := req.URL.Query()
+	fromQuery := query.Get("token")
+	if fromQuery != "" {
+		token = fromQuery
+	} else if cookie, err := req.Cookie(proxyTokenCookie); err == nil && token == "" {
+		token = cookie.Value
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.proxyToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "a valid proxy token is required"})
+		return false
+	}
+
+	if fromQuery != "" {
+		// Scope the cookie to the proxied port, so relative links and assets work
+		prefix := strings.TrimSuffix(req.URL.Path, c.Param("path"))
+		http.SetCookie(c.Writer, &http.Cookie{Name: proxyTokenCookie, Value: token, Path: prefix, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		query.Del("token")
+		req.URL.RawQuery = query.Encode()
+	}
+	req.Header.Del("Authorization")
+	var cookies []string
+	for _, cookie := range req.Cookies() {
+		if cookie.Name != proxyTokenCookie {
+			cookies = append(cookies, cookie.String())
+		}
+	}
+	req.Header.Del("Cookie")
+	if len(cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+	return true
+}
+
+// DownloadPodFilesHandlerFunc streams a file or directory out of a container
+// as a tar or zip archive, and writes the download to the audit log
+func (h *Handler) DownloadPodFilesHandlerFunc(c *gin.Context) {
+	if !h.authorizeFiles(c) {
+		return
+	}
+	format := c.DefaultQuery("format", "tar")
+	if format != "tar" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be tar or zip"})
+		return
+	}
+	if h.audit == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "copying files requires a database for the audit log"})
+		return
+	}
+	namespace, podName, srcPath := c.Param("namespace"), c.Param("podName"), c.Query("path")
+	container, err := h.client.PodContainer(c.Request.Context(), namespace, podName, c.Query("container"))
+	if err != nil {
+		respondCopyError(c, err)
+		return
+	}
+
+	name := path.Base(srcPath)
+	if name == "/" || name == "." {
+		name = "root"
+	}
+	contentType := "application/x-tar"
+	if format == "zip" {
+		contentType = "application/zip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", podName+"-"+name+"."+format))
+	copied, err := h.client.CopyFromContainer(c.Request.Context(), namespace, podName, container, srcPath, format, h.copyLimit, c.Writer)
+	h.auditCopy(c, AuditCopyFrom, namespace, podName, fmt.Sprintf("%s:%s (%d bytes)", container, srcPath, copied), err)
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		// Too late for a status; the client gets a truncated archive
+		log.Printf("Error copying %s from %s/%s: %v", srcPath, namespace, podName, err)
+		return
+	}
+	c.Writer.Header().Del("Content-Type")
+	c.Writer.Header().Del("Content-Disposition")
+	respondCopyError(c, err)
+}
+
+// UploadPodFilesHandlerFunc copies the files of a multipart form into a
+// container directory, and writes the upload to the audit log
+func (h *Handler) UploadPodFilesHandlerFunc(c *gin.Context) {
+	if !h.authorizeFiles(c) {
+		return
+	}
+	if h.audit == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "copying files requires a database for the audit log"})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.copyLimit)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondCopyError(c, fmt.Errorf("%w of %d bytes", ErrFileTooLarge, h.copyLimit))
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer form.RemoveAll()
+	files := form.File["file"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no files in the file field of the form"})
+		return
+	}
+
+	namespace, podName, destDir := c.Param("namespace"), c.Param("podName"), c.Query("path")
+	container, err := h.client.PodContainer(c.Request.Context(), namespace, podName, c.Query("container"))
+	if err != nil {
+		respondCopyError(c, err)
+		return
+	}
+	result, err := h.client.CopyToContainer(c.Request.Context(), namespace, podName, container, destDir, files)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Filename)
+	}
+	h.auditCopy(c, AuditCopyTo, namespace, podName, fmt.Sprintf("%s:%s %s", container, destDir, strings.Join(names, ", ")), err)
+	if err != nil {
+		respondCopyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// authorizeFiles checks the proxy token of a file copy from the Authorization
+// header or the token query parameter. Unlike the proxy it takes no cookie, so
+// other sites cannot make a browser copy files with it.
+func (h *Handler) authorizeFiles(c *gin.Context) bool {
+	if h.proxyToken == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "copying files is disabled, set PROXY_TOKEN to enable it"})
+		return false
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if fromQuery := c.Query("token"); fromQuery != "" {
+		token = fromQuery
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.proxyToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "a valid proxy token is required"})
+		return false
+	}
+	return true
+}
+
+// auditCopy records a copy to or from a pod, including aborted downloads
+func (h *Handler) auditCopy(c *gin.Context, action, namespace, pod, detail string, err error) {
+	entry := api.AuditEntry{Time: time.Now().UTC(), Actor: c.ClientIP(), Action: action, Kind: "Pod",
+		Namespace: namespace, Name: pod, Detail: detail}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := h.audit.RecordAudit(context.WithoutCancel(c.Request.Context()), []api.AuditEntry{entry}); err != nil {
+		log.Printf("Error recording audit log: %v", err)
+	}
+}
+
+func respondCopyError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case apierrors.IsNotFound(err), errors.Is(err, ErrContainerPathNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalidCopyTarget):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrFileTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrExecUnavailable):
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
 }
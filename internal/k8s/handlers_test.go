@@ -0,0 +1,206 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// serve runs a single request against the handler's routes
+func serve(t *testing.T, h *Handler, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveJSON(t, h, method, target, nil)
+}
+
+// serveJSON sends body encoded as JSON, or no body when it is nil. prepare
+// may add headers, cookies or another body to the request before it is served.
+func serveJSON(t *testing.T, h *Handler, method, target string, body interface{}, prepare ...func(*http.Request)) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterRoutes(r, h.Routes())
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, p := range prepare {
+		p(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetSummaryHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *Client
+		want    api.Summary
+		wantErr bool
+	}{
+		{
+			name:   "empty cluster",
+			client: newFakeClient(t),
+			want:   api.Summary{},
+		},
+		{
+			name: "mixed cluster",
+			client: newFakeClient(t,
+				testPod("shop", "web-1", corev1.PodRunning, nil),
+				testPod("shop", "web-2", corev1.PodRunning, nil),
+				testPod("shop", "db-0", corev1.PodPending, nil),
+				testPod("ci", "job-1", corev1.PodSucceeded, nil),
+				testNode("worker-1", corev1.ConditionTrue, nil),
+				testNode("worker-2", corev1.ConditionFalse, nil),
+				testService("shop", "web"),
+				testDeployment("shop", "web", 2, 2),
+				testStatefulSet("shop", "db", 0, 1),
+				testDaemonSet("kube-system", "proxy", 2, 2),
+			),
+			want: api.Summary{
+				TotalPods:      4,
+				RunningPods:    2,
+				TotalNodes:     2,
+				ReadyNodes:     1,
+				TotalServices:  1,
+				TotalWorkloads: 3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, NewHandler(tt.client), http.MethodGet, "/summary")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var got api.Summary
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartWorkloadHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{"deployment", "/workloads/shop/web/Deployment/restart", http.StatusOK},
+		{"unsupported kind", "/workloads/shop/web/ReplicaSet/restart", http.StatusBadRequest},
+		{"missing workload", "/workloads/shop/api/Deployment/restart", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient(t, testDeployment("shop", "web", 1, 1))
+			w := serve(t, NewHandler(c), http.MethodPost, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestGetPodLogsHandler(t *testing.T) {
+	// testPod has an app and a sidecar container
+	c := newFakeClient(t, testPod("shop", "web-1", corev1.PodRunning, nil))
+	w := serve(t, NewHandler(c), http.MethodGet, "/pods/shop/web-1/logs?container=sidecar&tail=20")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var logs api.PodLogs
+	if err := json.Unmarshal(w.Body.Bytes(), &logs); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Logs != "fake logs" {
+		t.Errorf("logs %q", logs.Logs)
+	}
+
+	var options *corev1.PodLogOptions
+	for _, action := range c.Clientset.(*kubefake.Clientset).Actions() {
+		if action.GetSubresource() == "log" {
+			options = action.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+		}
+	}
+	if options == nil || options.Container != "sidecar" || options.TailLines == nil || *options.TailLines != 20 {
+		t.Errorf("log options %+v", options)
+	}
+}
+
+func TestListHandlerPagination(t *testing.T) {
+	c := newFakeClient(t,
+		testPod("shop", "a", corev1.PodRunning, nil),
+		testPod("shop", "b", corev1.PodPending, nil),
+		testPod("shop", "c", corev1.PodRunning, nil),
+	)
+	h := NewHandler(c)
+
+	w := serve(t, h, http.MethodGet, "/pods?sort=-name&limit=2")
+	var page api.ListResponse[api.Pod]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].Name != "c" || page.Continue == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+
+	w = serve(t, h, http.MethodGet, "/pods?sort=-name&limit=2&continue="+page.Continue)
+	page = api.ListResponse[api.Pod]{}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Name != "a" || page.Continue != "" {
+		t.Errorf("unexpected second page %+v", page)
+	}
+
+	w = serve(t, h, http.MethodGet, "/pods?status=Pending")
+	page = api.ListResponse[api.Pod]{}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Items[0].Name != "b" {
+		t.Errorf("unexpected filtered page %+v", page)
+	}
+
+	if w := serve(t, h, http.MethodGet, "/pods?labelSelector=a%3D%3D%3D"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid selector: status %d", w.Code)
+	}
+	// ConfigMaps have no status to filter by
+	if w := serve(t, h, http.MethodGet, "/configmaps?status=Pending"); w.Code != http.StatusBadRequest {
+		t.Errorf("status filter on configmaps: status %d", w.Code)
+	}
+
+	for query, want := range map[string]int{
+		"sort=bogus":           http.StatusBadRequest,
+		"sort=-bogus":          http.StatusBadRequest,
+		"sort=name,bogus":      http.StatusBadRequest,
+		"sort=-age":            http.StatusOK,
+		"sort=namespace,-name": http.StatusOK,
+	} {
+		if w := serve(t, h, http.MethodGet, "/pods?"+query); w.Code != want {
+			t.Errorf("%s: status %d, want %d", query, w.Code, want)
+		}
+	}
+}
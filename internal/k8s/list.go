@@ -0,0 +1,302 @@
+package k8s
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubelens/pkg/api"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// statusColumns are the columns the status filter is matched against, in order of preference
+var statusColumns = []string{"status", "phase", "type"}
+
+// ListQuery holds the pagination, sorting and filtering parameters shared by all list endpoints
+type ListQuery struct {
+	Limit         int
+	Offset        int
+	Sort          []string
+	LabelSelector string
+	FieldSelector string
+	Status        []string
+}
+
+// parseListQuery reads limit, continue, sort, labelSelector, fieldSelector and status
+// from the query string. defaultSort is used when no sort is given.
+func parseListQuery(c *gin.Context, defaultSort string) (ListQuery, error) {
+	q := ListQuery{
+		LabelSelector: c.Query("labelSelector"),
+		FieldSelector: c.Query("fieldSelector"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 0 {
+			return q, fmt.Errorf("invalid limit: %s", limit)
+		}
+		q.Limit = parsed
+	}
+
+	if token := c.Query("continue"); token != "" {
+		offset, err := decodeContinue(token)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = offset
+	}
+
+	sortParam := c.DefaultQuery("sort", defaultSort)
+	for _, column := range strings.Split(sortParam, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			q.Sort = append(q.Sort, column)
+		}
+	}
+
+	if status := c.Query("status"); status != "" {
+		q.Status = strings.Split(status, ",")
+	}
+
+	if q.LabelSelector != "" {
+		if _, err := labels.Parse(q.LabelSelector); err != nil {
+			return q, fmt.Errorf("invalid labelSelector: %w", err)
+		}
+	}
+	if q.FieldSelector != "" {
+		if _, err := fields.ParseSelector(q.FieldSelector); err != nil {
+			return q, fmt.Errorf("invalid fieldSelector: %w", err)
+		}
+	}
+
+	return q, nil
+}
+
+// listOptions passes the selectors through to the API server
+func (q ListQuery) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{
+		LabelSelector: q.LabelSelector,
+		FieldSelector: q.FieldSelector,
+	}
+}
+
+// applyList filters by status, sorts and cuts out the requested page. It returns the page,
+// the total number of matching items and the continue token of the next page.
+// Columns are addressed by their JSON names.
+func applyList[T any](q ListQuery, items []T) ([]T, int, string) {
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if q.matchesStatus(reflect.ValueOf(item)) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	if len(q.Sort) > 0 {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			a, b := reflect.ValueOf(filtered[i]), reflect.ValueOf(filtered[j])
+			for _, column := range q.Sort {
+				desc := strings.HasPrefix(column, "-")
+				// A smaller age is a later creation time
+				if strings.TrimPrefix(column, "-") == "age" {
+					desc = !desc
+				}
+				av, _ := columnValue(a, sortColumn(column))
+				bv, _ := columnValue(b, sortColumn(column))
+				cmp := compareValues(av, bv)
+				if cmp == 0 {
+					continue
+				}
+				if desc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	total := len(filtered)
+	if q.Offset >= total {
+		return []T{}, total, ""
+	}
+	end := total
+	if q.Limit > 0 && q.Offset+q.Limit < total {
+		end = q.Offset + q.Limit
+	}
+
+	next := ""
+	if end < total {
+		next = encodeContinue(end)
+	}
+	return filtered[q.Offset:end], total, next
+}
+
+func (q ListQuery) matchesStatus(item reflect.Value) bool {
+	if len(q.Status) == 0 {
+		return true
+	}
+	for _, column := range statusColumns {
+		value, ok := columnValue(item, column)
+		if !ok {
+			continue
+		}
+		for _, status := range q.Status {
+			if strings.EqualFold(fmt.Sprint(value), status) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// hasColumn reports whether items of type T have a field with the JSON name column
+func hasColumn[T any](column string) bool {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	_, ok := columnValue(reflect.New(t).Elem(), column)
+	return ok
+}
+
+// hasStatusColumn reports whether items of type T have one of the statusColumns
+func hasStatusColumn[T any]() bool {
+	for _, column := range statusColumns {
+		if hasColumn[T](column) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortColumn returns the field a sort column, without its "-" prefix, orders by
+func sortColumn(column string) string {
+	column = strings.TrimPrefix(column, "-")
+	if column == "age" {
+		return "createdAt"
+	}
+	return column
+}
+
+// respondList writes the standard list response of a list endpoint. Sort
+// columns the items do not have are rejected, and so is the status filter for
+// kinds without a status, phase or type column.
+func respondList[T any](c *gin.Context, items []T, q ListQuery) {
+	for _, column := range q.Sort {
+		if !hasColumn[T](sortColumn(column)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cannot sort by %s: this list has no such column", strings.TrimPrefix(column, "-"))})
+			return
+		}
+	}
+	if len(q.Status) > 0 && !hasStatusColumn[T]() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status is not supported for this list: it has no status, phase or type column"})
+		return
+	}
+	page, total, next := applyList(q, items)
+	c.JSON(http.StatusOK, api.ListResponse[T]{Items: page, Total: total, Continue: next})
+}
+
+// listErrorStatus maps a list error to an HTTP status; selector errors rejected by the API server are client errors
+func listErrorStatus(err error) int {
+	if apierrors.IsBadRequest(err) || apierrors.IsInvalid(err) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// columnValue returns the struct field whose JSON name is column, looking into embedded structs
+func columnValue(v reflect.Value, column string) (interface{}, bool) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			if value, ok := columnValue(v.Field(i), column); ok {
+				return value, true
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == column {
+			return v.Field(i).Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func compareValues(a, b interface{}) int {
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	if a == nil {
+		as = ""
+	}
+	if b == nil {
+		bs = ""
+	}
+	return strings.Compare(as, bs)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func encodeContinue(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeContinue(token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid continue token")
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid continue token")
+	}
+	return offset, nil
+}
@@ -0,0 +1,242 @@
+package k8s
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetNamespaceDetail returns the pods, workloads and resource consumption of a
+// namespace next to its ResourceQuotas and LimitRanges
+func (c *Client) GetNamespaceDetail(ctx context.Context, name string) (*api.NamespaceDetail, error) {
+	ns, err := c.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podList, err := c.Clientset.CoreV1().Pods(name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	quotaList, err := c.Clientset.CoreV1().ResourceQuotas(name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	limitRangeList, err := c.Clientset.CoreV1().LimitRanges(name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	workloads, err := c.countWorkloads(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &api.NamespaceDetail{
+		Namespace: api.Namespace{
+			Name:      ns.Name,
+			Age:       formatAge(ns.CreationTimestamp.Time),
+			CreatedAt: ns.CreationTimestamp.UTC(),
+		},
+		Status:       string(ns.Status.Phase),
+		Labels:       ns.Labels,
+		Annotations:  ns.Annotations,
+		Pods:         len(podList.Items),
+		PodsByStatus: make(map[string]int),
+		Workloads:    workloads,
+		Quotas:       []api.ResourceQuota{},
+		LimitRanges:  []api.LimitRange{},
+	}
+	if detail.Status == "" {
+		detail.Status = string(corev1.NamespaceActive)
+	}
+
+	// Only pods that still hold resources count against the quota
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	activePods := 0
+	for _, pod := range podList.Items {
+		detail.PodsByStatus[podStatus(pod)]++
+		if !activePod(pod) {
+			continue
+		}
+		activePods++
+		addResources(requests, podRequests(pod))
+		addResources(limits, podLimits(pod))
+	}
+
+	// Usage is optional: without a metrics server the detail still shows requests and limits
+	var usage corev1.ResourceList
+	if metrics, err := c.GetPodMetrics(ctx, name, metav1.ListOptions{}); err == nil {
+		detail.MetricsAvailable = true
+		var cpu, memory int64
+		for _, m := range metrics {
+			cpu += m.CPUUsage
+			memory += m.MemoryUsage
+		}
+		usage = corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewMilliQuantity(cpu, resource.DecimalSI),
+			corev1.ResourceMemory: *resource.NewQuantity(memory, resource.BinarySI),
+		}
+	}
+
+	for _, quota := range quotaList.Items {
+		detail.Quotas = append(detail.Quotas, newResourceQuota(quota, requests, limits, activePods))
+	}
+	sort.Slice(detail.Quotas, func(i, j int) bool { return detail.Quotas[i].Name < detail.Quotas[j].Name })
+	for _, limitRange := range limitRangeList.Items {
+		detail.LimitRanges = append(detail.LimitRanges, newLimitRange(limitRange))
+	}
+	sort.Slice(detail.LimitRanges, func(i, j int) bool { return detail.LimitRanges[i].Name < detail.LimitRanges[j].Name })
+
+	for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		requested, limited := requests[resourceName], limits[resourceName]
+		row := api.NamespaceResource{
+			Name:     string(resourceName),
+			Requests: requested.String(),
+			Limits:   limited.String(),
+		}
+		if hard, ok := tightestQuota(quotaList.Items, corev1.ResourceName("requests."+string(resourceName)), resourceName); ok {
+			row.RequestsQuota = hard.String()
+			row.RequestsPercent = percentOf(requested, hard)
+		}
+		if hard, ok := tightestQuota(quotaList.Items, corev1.ResourceName("limits."+string(resourceName))); ok {
+			row.LimitsQuota = hard.String()
+			row.LimitsPercent = percentOf(limited, hard)
+		}
+		if used, ok := usage[resourceName]; ok {
+			row.Usage = used.String()
+		}
+		detail.Resources = append(detail.Resources, row)
+	}
+	return detail, nil
+}
+
+// countWorkloads counts the workload controllers of a namespace by kind
+func (c *Client) countWorkloads(ctx context.Context, namespace string) (map[string]int, error) {
+	counts := make(map[string]int)
+	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts["Deployment"] = len(deployments.Items)
+	statefulSets, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts["StatefulSet"] = len(statefulSets.Items)
+	daemonSets, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts["DaemonSet"] = len(daemonSets.Items)
+	jobs, err := c.Clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts["Job"] = len(jobs.Items)
+	cronJobs, err := c.Clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts["CronJob"] = len(cronJobs.Items)
+	return counts, nil
+}
+
+// newResourceQuota lists the hard limits of a quota with their usage. The quota
+// controller reports usage in the status; until it has, compute and object
+// count usage is derived from the pods
+func newResourceQuota(quota corev1.ResourceQuota, requests, limits corev1.ResourceList, pods int) api.ResourceQuota {
+	result := api.ResourceQuota{Name: quota.Name, Items: []api.QuotaItem{}}
+	for _, scope := range quota.Spec.Scopes {
+		result.Scopes = append(result.Scopes, string(scope))
+	}
+	hardLimits := corev1.ResourceList{}
+	addResources(hardLimits, quota.Status.Hard)
+	for name, hard := range quota.Spec.Hard {
+		hardLimits[name] = hard
+	}
+	for name, hard := range hardLimits {
+		used, ok := quota.Status.Used[name]
+		if !ok {
+			used = derivedQuotaUsage(name, requests, limits, pods)
+		}
+		result.Items = append(result.Items, api.QuotaItem{
+			Resource: string(name),
+			Hard:     hard.String(),
+			Used:     used.String(),
+			Percent:  percentOf(used, hard),
+		})
+	}
+	sort.Slice(result.Items, func(i, j int) bool { return result.Items[i].Resource < result.Items[j].Resource })
+	return result
+}
+
+func derivedQuotaUsage(name corev1.ResourceName, requests, limits corev1.ResourceList, pods int) resource.Quantity {
+	switch {
+	case name == corev1.ResourcePods:
+		return *resource.NewQuantity(int64(pods), resource.DecimalSI)
+	case strings.HasPrefix(string(name), "limits."):
+		return limits[corev1.ResourceName(strings.TrimPrefix(string(name), "limits."))]
+	case strings.HasPrefix(string(name), "requests."):
+		return requests[corev1.ResourceName(strings.TrimPrefix(string(name), "requests."))]
+	case name == corev1.ResourceCPU || name == corev1.ResourceMemory:
+		return requests[name]
+	}
+	return resource.Quantity{}
+}
+
+// tightestQuota returns the lowest hard limit any quota sets under one of names
+func tightestQuota(quotas []corev1.ResourceQuota, names ...corev1.ResourceName) (resource.Quantity, bool) {
+	var tightest resource.Quantity
+	found := false
+	for _, quota := range quotas {
+		for _, name := range names {
+			if hard, ok := quota.Spec.Hard[name]; ok && (!found || hard.Cmp(tightest) < 0) {
+				tightest, found = hard, true
+			}
+		}
+	}
+	return tightest, found
+}
+
+// newLimitRange flattens a LimitRange into one item per type and resource
+func newLimitRange(limitRange corev1.LimitRange) api.LimitRange {
+	result := api.LimitRange{Name: limitRange.Name, Limits: []api.LimitRangeItem{}}
+	for _, limit := range limitRange.Spec.Limits {
+		names := map[corev1.ResourceName]bool{}
+		for _, list := range []corev1.ResourceList{limit.Min, limit.Max, limit.Default, limit.DefaultRequest, limit.MaxLimitRequestRatio} {
+			for name := range list {
+				names[name] = true
+			}
+		}
+		var sorted []string
+		for name := range names {
+			sorted = append(sorted, string(name))
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			resourceName := corev1.ResourceName(name)
+			result.Limits = append(result.Limits, api.LimitRangeItem{
+				Type:                 string(limit.Type),
+				Resource:             name,
+				Min:                  quantityString(limit.Min, resourceName),
+				Max:                  quantityString(limit.Max, resourceName),
+				Default:              quantityString(limit.Default, resourceName),
+				DefaultRequest:       quantityString(limit.DefaultRequest, resourceName),
+				MaxLimitRequestRatio: quantityString(limit.MaxLimitRequestRatio, resourceName),
+			})
+		}
+	}
+	return result
+}
+
+func quantityString(list corev1.ResourceList, name corev1.ResourceName) string {
+	if quantity, ok := list[name]; ok {
+		return quantity.String()
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package k8s
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetNamespaceDetail(t *testing.T) {
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c)
+
+	w := serve(t, h, http.MethodGet, "/namespaces/shop")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var detail api.NamespaceDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Status != "Active" || detail.Pods != 4 || detail.PodsByStatus["Running"] != 3 || detail.PodsByStatus["CrashLoopBackOff"] != 1 {
+		t.Errorf("unexpected pod counts %+v", detail)
+	}
+	if detail.Workloads["Deployment"] != 2 || detail.Workloads["StatefulSet"] != 1 || !detail.MetricsAvailable {
+		t.Errorf("unexpected workloads %v", detail.Workloads)
+	}
+	cpu := detail.Resources[0]
+	if cpu.Name != "cpu" || cpu.Requests != "950m" || cpu.RequestsQuota != "2" || cpu.RequestsPercent != 47 || cpu.Usage == "" {
+		t.Errorf("unexpected cpu row %+v", cpu)
+	}
+	if len(detail.Quotas) != 1 || len(detail.Quotas[0].Items) != 5 || len(detail.LimitRanges) != 1 || len(detail.LimitRanges[0].Limits) != 2 {
+		t.Errorf("unexpected quotas %+v and limit ranges %+v", detail.Quotas, detail.LimitRanges)
+	}
+
+	if w := serve(t, h, http.MethodGet, "/namespaces/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("missing namespace: status %d", w.Code)
+	}
+}
+
+func TestNewResourceQuota(t *testing.T) {
+	quota := corev1.ResourceQuota{
+		ObjectMeta: objectMeta("shop", "compute", nil),
+		Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+			corev1.ResourceRequestsCPU: resource.MustParse("2"),
+			corev1.ResourcePods:        resource.MustParse("10"),
+		}},
+		Status: corev1.ResourceQuotaStatus{Used: corev1.ResourceList{
+			corev1.ResourcePods: resource.MustParse("5"),
+		}},
+	}
+	requests := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}
+	got := newResourceQuota(quota, requests, corev1.ResourceList{}, 3)
+	want := []api.QuotaItem{
+		{Resource: "pods", Hard: "10", Used: "5", Percent: 50},
+		{Resource: "requests.cpu", Hard: "2", Used: "500m", Percent: 25},
+	}
+	if len(got.Items) != len(want) {
+		t.Fatalf("items %+v", got.Items)
+	}
+	for i := range want {
+		if got.Items[i] != want[i] {
+			t.Errorf("item %d: %+v, want %+v", i, got.Items[i], want[i])
+		}
+	}
+}
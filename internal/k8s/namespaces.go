@@ -0,0 +1,297 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Names of the objects a NamespaceTemplate creates in a new namespace
+const (
+	templateQuotaName      = "default-quota"
+	templateLimitRangeName = "default-limits"
+)
+
+// ErrInvalidNamespaceRequest wraps the validation errors of a namespace creation
+var ErrInvalidNamespaceRequest = errors.New("invalid namespace request")
+
+// DefaultProtectedNamespaces cannot be deleted unless PROTECTED_NAMESPACES says otherwise
+var DefaultProtectedNamespaces = []string{"default", "kube-system", "kube-public", "kube-node-lease"}
+
+// DefaultBindableClusterRoles are the ClusterRoles a new namespace may bind
+// unless BINDABLE_CLUSTER_ROLES says otherwise
+var DefaultBindableClusterRoles = []string{"admin", "edit", "view"}
+
+// DefaultNamespaceTemplates are offered when no NAMESPACE_TEMPLATES file is configured
+var DefaultNamespaceTemplates = []api.NamespaceTemplate{
+	{
+		Name:        "small",
+		Description: "2 CPU / 4Gi requested, containers default to 100m / 128Mi",
+		Quota:       map[string]string{"requests.cpu": "2", "requests.memory": "4Gi", "limits.cpu": "4", "limits.memory": "8Gi", "pods": "20"},
+		LimitRange: []api.LimitRangeItem{
+			{Type: "Container", Resource: "cpu", Default: "500m", DefaultRequest: "100m"},
+			{Type: "Container", Resource: "memory", Default: "512Mi", DefaultRequest: "128Mi"},
+		},
+	},
+	{
+		Name:        "medium",
+		Description: "8 CPU / 16Gi requested, containers default to 250m / 256Mi",
+		Quota:       map[string]string{"requests.cpu": "8", "requests.memory": "16Gi", "limits.cpu": "16", "limits.memory": "32Gi", "pods": "100"},
+		LimitRange: []api.LimitRangeItem{
+			{Type: "Container", Resource: "cpu", Default: "1", DefaultRequest: "250m"},
+			{Type: "Container", Resource: "memory", Default: "1Gi", DefaultRequest: "256Mi"},
+		},
+	},
+	{
+		Name:        "large",
+		Description: "32 CPU / 64Gi requested, containers default to 500m / 512Mi",
+		Quota:       map[string]string{"requests.cpu": "32", "requests.memory": "64Gi", "limits.cpu": "64", "limits.memory": "128Gi", "pods": "500"},
+		LimitRange: []api.LimitRangeItem{
+			{Type: "Container", Resource: "cpu", Default: "2", DefaultRequest: "500m"},
+			{Type: "Container", Resource: "memory", Default: "2Gi", DefaultRequest: "512Mi"},
+		},
+	},
+}
+
+// LoadNamespaceTemplates reads a JSON array of templates and checks that every
+// quantity parses, so a typo fails at startup rather than on the first create
+func LoadNamespaceTemplates(path string) ([]api.NamespaceTemplate, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var templates []api.NamespaceTemplate
+	if err := json.Unmarshal(raw, &templates); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, template := range templates {
+		if template.Name == "" {
+			return nil, fmt.Errorf("%s: template without a name", path)
+		}
+		if _, _, err := templateObjects(template); err != nil {
+			return nil, fmt.Errorf("%s: template %s: %w", path, template.Name, err)
+		}
+	}
+	return templates, nil
+}
+
+// CreateNamespace creates a labeled namespace with the quota and limit range of
+// template, if any, and the requested role bindings of clusterRoles. Objects
+// created before a failure are kept and named in the error.
+func (c *Client) CreateNamespace(ctx context.Context, req api.NamespaceCreateRequest, template *api.NamespaceTemplate, clusterRoles map[string]bool) (*api.NamespaceCreateResult, error) {
+	if errs := validation.IsDNS1123Label(req.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("%w: name %q: %s", ErrInvalidNamespaceRequest, req.Name, strings.Join(errs, ", "))
+	}
+	bindings, err := roleBindingObjects(req.Name, req.RoleBindings, clusterRoles)
+	if err != nil {
+		return nil, err
+	}
+	var quota *corev1.ResourceQuota
+	var limitRange *corev1.LimitRange
+	if template != nil {
+		if quota, limitRange, err = templateObjects(*template); err != nil {
+			return nil, fmt.Errorf("%w: template %s: %v", ErrInvalidNamespaceRequest, template.Name, err)
+		}
+	}
+
+	ns, err := c.Clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: req.Name, Labels: req.Labels},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := &api.NamespaceCreateResult{
+		Namespace: api.Namespace{Name: ns.Name, Age: formatAge(ns.CreationTimestamp.Time), CreatedAt: ns.CreationTimestamp.UTC()},
+		Created:   []api.ObjectRef{{Kind: "Namespace", Name: ns.Name}},
+	}
+	partial := func(err error) error {
+		var created []string
+		for _, ref := range result.Created {
+			created = append(created, ref.Kind+" "+ref.Name)
+		}
+		return fmt.Errorf("created %s, then: %w", strings.Join(created, ", "), err)
+	}
+
+	if quota != nil {
+		if _, err := c.Clientset.CoreV1().ResourceQuotas(req.Name).Create(ctx, quota, metav1.CreateOptions{}); err != nil {
+			return nil, partial(err)
+		}
+		result.Created = append(result.Created, api.ObjectRef{Kind: "ResourceQuota", Namespace: req.Name, Name: quota.Name})
+	}
+	if limitRange != nil {
+		if _, err := c.Clientset.CoreV1().LimitRanges(req.Name).Create(ctx, limitRange, metav1.CreateOptions{}); err != nil {
+			return nil, partial(err)
+		}
+		result.Created = append(result.Created, api.ObjectRef{Kind: "LimitRange", Namespace: req.Name, Name: limitRange.Name})
+	}
+	for _, binding := range bindings {
+		if _, err := c.Clientset.RbacV1().RoleBindings(req.Name).Create(ctx, binding, metav1.CreateOptions{}); err != nil {
+			return nil, partial(err)
+		}
+		result.Created = append(result.Created, api.ObjectRef{Kind: "RoleBinding", Namespace: req.Name, Name: binding.Name})
+	}
+	return result, nil
+}
+
+// templateObjects builds the ResourceQuota and LimitRange of a template; either
+// is nil when the template leaves it out
+func templateObjects(template api.NamespaceTemplate) (*corev1.ResourceQuota, *corev1.LimitRange, error) {
+	var quota *corev1.ResourceQuota
+	if len(template.Quota) > 0 {
+		hard := corev1.ResourceList{}
+		for name, value := range template.Quota {
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("quota %s: %w", name, err)
+			}
+			hard[corev1.ResourceName(name)] = quantity
+		}
+		quota = &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: templateQuotaName},
+			Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		}
+	}
+
+	var limitRange *corev1.LimitRange
+	if len(template.LimitRange) > 0 {
+		limitRange = &corev1.LimitRange{ObjectMeta: metav1.ObjectMeta{Name: templateLimitRangeName}}
+		byType := map[string]int{}
+		for _, item := range template.LimitRange {
+			i, ok := byType[item.Type]
+			if !ok {
+				i = len(limitRange.Spec.Limits)
+				byType[item.Type] = i
+				limitRange.Spec.Limits = append(limitRange.Spec.Limits, corev1.LimitRangeItem{Type: corev1.LimitType(item.Type)})
+			}
+			limit := &limitRange.Spec.Limits[i]
+			for _, field := range []struct {
+				value string
+				list  *corev1.ResourceList
+			}{
+				{item.Min, &limit.Min},
+				{item.Max, &limit.Max},
+				{item.Default, &limit.Default},
+				{item.DefaultRequest, &limit.DefaultRequest},
+				{item.MaxLimitRequestRatio, &limit.MaxLimitRequestRatio},
+			} {
+				if field.value == "" {
+					continue
+				}
+				quantity, err := resource.ParseQuantity(field.value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("limit range %s %s: %w", item.Type, item.Resource, err)
+				}
+				if *field.list == nil {
+					*field.list = corev1.ResourceList{}
+				}
+				(*field.list)[corev1.ResourceName(item.Resource)] = quantity
+			}
+		}
+	}
+	return quota, limitRange, nil
+}
+
+// roleBindingObjects validates the requested bindings of ClusterRoles. Only
+// clusterRoles can be bound, and only to users, groups and service accounts of
+// the namespace, so a binding cannot grant system identities or other
+// namespaces anything.
+func roleBindingObjects(namespace string, bindings []api.RoleBinding, clusterRoles map[string]bool) ([]*rbacv1.RoleBinding, error) {
+	var objects []*rbacv1.RoleBinding
+	for _, binding := range bindings {
+		if binding.ClusterRole == "" || len(binding.Subjects) == 0 {
+			return nil, fmt.Errorf("%w: a role binding needs a clusterRole and subjects", ErrInvalidNamespaceRequest)
+		}
+		if !clusterRoles[binding.ClusterRole] {
+			var allowed []string
+			for role := range clusterRoles {
+				allowed = append(allowed, role)
+			}
+			sort.Strings(allowed)
+			return nil, fmt.Errorf("%w: clusterRole %q cannot be bound, want one of %s", ErrInvalidNamespaceRequest, binding.ClusterRole, strings.Join(allowed, ", "))
+		}
+		name := binding.Name
+		if name == "" {
+			name = binding.ClusterRole
+		}
+		object := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: binding.ClusterRole},
+		}
+		for _, subject := range binding.Subjects {
+			s := rbacv1.Subject{Kind: subject.Kind, Name: subject.Name}
+			switch subject.Kind {
+			case rbacv1.UserKind, rbacv1.GroupKind:
+				s.APIGroup = rbacv1.GroupName
+				if strings.HasPrefix(subject.Name, "system:") {
+					return nil, fmt.Errorf("%w: %s %q is reserved for Kubernetes", ErrInvalidNamespaceRequest, subject.Kind, subject.Name)
+				}
+			case rbacv1.ServiceAccountKind:
+				s.Namespace = namespace
+				if subject.Namespace != "" && subject.Namespace != namespace {
+					return nil, fmt.Errorf("%w: ServiceAccount %s/%s is not in namespace %s", ErrInvalidNamespaceRequest, subject.Namespace, subject.Name, namespace)
+				}
+			default:
+				return nil, fmt.Errorf("%w: subject kind %q, want User, Group or ServiceAccount", ErrInvalidNamespaceRequest, subject.Kind)
+			}
+			if subject.Name == "" {
+				return nil, fmt.Errorf("%w: %s subject without a name", ErrInvalidNamespaceRequest, subject.Kind)
+			}
+			object.Subjects = append(object.Subjects, s)
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// NamespaceContents lists every object KubeLens knows of inside a namespace,
+// which is what deleting it would remove
+func (c *Client) NamespaceContents(ctx context.Context, name string) (*api.NamespaceContents, error) {
+	if _, err := c.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{}); err != nil {
+		return nil, err
+	}
+	contents := &api.NamespaceContents{Namespace: name, Counts: map[string]int{}, Objects: []api.ObjectRef{}}
+	for _, kind := range fixtureKinds {
+		if snapshotSkipped[kind.file] || !kind.namespaced {
+			continue
+		}
+		items, err := kind.list(ctx, c, name)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", kind.file, err)
+		}
+		for _, item := range items {
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				return nil, err
+			}
+			contents.Objects = append(contents.Objects, api.ObjectRef{Kind: kind.gvk.Kind, Namespace: name, Name: accessor.GetName()})
+			contents.Counts[kind.gvk.Kind]++
+		}
+	}
+	sort.Slice(contents.Objects, func(i, j int) bool {
+		if contents.Objects[i].Kind != contents.Objects[j].Kind {
+			return contents.Objects[i].Kind < contents.Objects[j].Kind
+		}
+		return contents.Objects[i].Name < contents.Objects[j].Name
+	})
+	contents.Total = len(contents.Objects)
+	return contents, nil
+}
+
+// DeleteNamespace deletes a namespace and, in the background, everything in it
+func (c *Client) DeleteNamespace(ctx context.Context, name string) error {
+	policy := metav1.DeletePropagationBackground
+	return c.Clientset.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &policy})
+}
@@ -0,0 +1,152 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateNamespace(t *testing.T) {
+	c := newFakeClient(t)
+	h := NewHandler(c)
+
+	req := api.NamespaceCreateRequest{
+		Name:     "team-a",
+		Labels:   map[string]string{"team": "a"},
+		Template: "small",
+		RoleBindings: []api.RoleBinding{{ClusterRole: "edit", Subjects: []api.Subject{
+			{Kind: "Group", Name: "team-a"},
+			{Kind: "ServiceAccount", Name: "deployer"},
+		}}},
+	}
+	w := serveJSON(t, h, http.MethodPost, "/namespaces", req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var result api.NamespaceCreateResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	var created []string
+	for _, ref := range result.Created {
+		created = append(created, ref.Kind+"/"+ref.Name)
+	}
+	want := []string{"Namespace/team-a", "ResourceQuota/" + templateQuotaName, "LimitRange/" + templateLimitRangeName, "RoleBinding/edit"}
+	if !equalStrings(created, want) {
+		t.Errorf("created %v, want %v", created, want)
+	}
+
+	ctx := context.Background()
+	ns, err := c.Clientset.CoreV1().Namespaces().Get(ctx, "team-a", metav1.GetOptions{})
+	if err != nil || ns.Labels["team"] != "a" {
+		t.Errorf("namespace %+v: %v", ns, err)
+	}
+	limits, err := c.Clientset.CoreV1().LimitRanges("team-a").Get(ctx, templateLimitRangeName, metav1.GetOptions{})
+	if err != nil || len(limits.Spec.Limits) != 1 || limits.Spec.Limits[0].DefaultRequest.Cpu().String() != "100m" {
+		t.Errorf("limit range %+v: %v", limits, err)
+	}
+	binding, err := c.Clientset.RbacV1().RoleBindings("team-a").Get(ctx, "edit", metav1.GetOptions{})
+	if err != nil || binding.Subjects[1].Namespace != "team-a" || binding.RoleRef.Kind != "ClusterRole" {
+		t.Errorf("role binding %+v: %v", binding, err)
+	}
+
+	if w := serveJSON(t, h, http.MethodPost, "/namespaces", req); w.Code != http.StatusConflict {
+		t.Errorf("existing namespace: status %d", w.Code)
+	}
+	for name, bad := range map[string]api.NamespaceCreateRequest{
+		"invalid name":     {Name: "Team_A"},
+		"unknown template": {Name: "team-b", Template: "huge"},
+		"bad subject":      {Name: "team-b", RoleBindings: []api.RoleBinding{{ClusterRole: "view", Subjects: []api.Subject{{Kind: "Robot", Name: "r2"}}}}},
+		"cluster-admin":    {Name: "team-b", RoleBindings: []api.RoleBinding{{ClusterRole: "cluster-admin", Subjects: []api.Subject{{Kind: "Group", Name: "team-b"}}}}},
+		"system group":     {Name: "team-b", RoleBindings: []api.RoleBinding{{ClusterRole: "view", Subjects: []api.Subject{{Kind: "Group", Name: "system:authenticated"}}}}},
+		"system user":      {Name: "team-b", RoleBindings: []api.RoleBinding{{ClusterRole: "view", Subjects: []api.Subject{{Kind: "User", Name: "system:anonymous"}}}}},
+		"foreign account":  {Name: "team-b", RoleBindings: []api.RoleBinding{{ClusterRole: "edit", Subjects: []api.Subject{{Kind: "ServiceAccount", Namespace: "kube-system", Name: "default"}}}}},
+	} {
+		if w := serveJSON(t, h, http.MethodPost, "/namespaces", bad); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", name, w.Code)
+		}
+	}
+	if _, err := c.Clientset.CoreV1().Namespaces().Get(ctx, "team-b", metav1.GetOptions{}); err == nil {
+		t.Error("namespace created despite an invalid request")
+	}
+
+	// Operators choose the roles that can be bound
+	h = NewHandler(c, WithBindableClusterRoles([]string{"view", "team-deployer"}))
+	deployer := api.NamespaceCreateRequest{Name: "team-c", RoleBindings: []api.RoleBinding{{ClusterRole: "team-deployer", Subjects: []api.Subject{{Kind: "User", Name: "alice"}}}}}
+	if w := serveJSON(t, h, http.MethodPost, "/namespaces", deployer); w.Code != http.StatusCreated {
+		t.Errorf("configured role: status %d: %s", w.Code, w.Body)
+	}
+	editor := api.NamespaceCreateRequest{Name: "team-d", RoleBindings: []api.RoleBinding{{ClusterRole: "edit", Subjects: []api.Subject{{Kind: "User", Name: "alice"}}}}}
+	if w := serveJSON(t, h, http.MethodPost, "/namespaces", editor); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "team-deployer, view") {
+		t.Errorf("role outside the configured list: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestDeleteNamespace(t *testing.T) {
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c, WithProtectedNamespaces([]string{"kube-system", "shop-prod"}))
+
+	w := serve(t, h, http.MethodGet, "/namespaces/shop/contents")
+	var contents api.NamespaceContents
+	if err := json.Unmarshal(w.Body.Bytes(), &contents); err != nil {
+		t.Fatal(err)
+	}
+	if contents.Protected || contents.Total == 0 || contents.Counts["Pod"] != 4 || contents.Counts["Deployment"] != 2 {
+		t.Errorf("unexpected contents %+v", contents)
+	}
+	// Only the namespace is listed, and no cluster-scoped kinds
+	for _, action := range c.Clientset.(*kubefake.Clientset).Actions() {
+		if action.GetVerb() == "list" && action.GetNamespace() != "shop" {
+			t.Errorf("listed %s in namespace %q", action.GetResource().Resource, action.GetNamespace())
+		}
+	}
+
+	if w := serve(t, h, http.MethodDelete, "/namespaces/kube-system?confirm=kube-system"); w.Code != http.StatusForbidden {
+		t.Errorf("protected namespace: status %d", w.Code)
+	}
+	if w := serve(t, h, http.MethodDelete, "/namespaces/missing?confirm=missing"); w.Code != http.StatusNotFound {
+		t.Errorf("missing namespace: status %d", w.Code)
+	}
+	w = serve(t, h, http.MethodDelete, "/namespaces/shop?confirm=shop-typo")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "contents") {
+		t.Errorf("unconfirmed delete: status %d: %s", w.Code, w.Body)
+	}
+	if w := serve(t, h, http.MethodDelete, "/namespaces/shop?confirm=shop"); w.Code != http.StatusOK {
+		t.Fatalf("confirmed delete: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := c.Clientset.CoreV1().Namespaces().Get(context.Background(), "shop", metav1.GetOptions{}); err == nil {
+		t.Error("namespace still exists")
+	}
+}
+
+func TestLoadNamespaceTemplates(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	if err := os.WriteFile(good, []byte(`[{"name":"tiny","quota":{"pods":"5"}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := LoadNamespaceTemplates(good)
+	if err != nil || len(templates) != 1 || templates[0].Quota["pods"] != "5" {
+		t.Errorf("templates %+v: %v", templates, err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`[{"name":"tiny","limitRange":[{"type":"Container","resource":"cpu","max":"two"}]}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadNamespaceTemplates(bad); err == nil || !strings.Contains(err.Error(), "tiny") {
+		t.Errorf("expected an error naming the template, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"context"
+	"sort"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// GetNodeDetail returns what `kubectl describe node` shows, with the pods
+// packed on the node and their usage when the metrics server is available
+func (c *Client) GetNodeDetail(ctx context.Context, name string) (*api.NodeDetail, error) {
+	node, err := c.Clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podList, err := c.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &api.NodeDetail{
+		Node:          newNode(*node),
+		Unschedulable: node.Spec.Unschedulable,
+		PodCIDR:       node.Spec.PodCIDR,
+		Labels:        node.Labels,
+		Annotations:   node.Annotations,
+		Taints:        []api.Taint{},
+		Conditions:    []api.Condition{},
+		Addresses:     []api.NodeAddress{},
+		Images:        []api.ContainerImage{},
+		Pods:          []api.NodePod{},
+	}
+	for _, taint := range node.Spec.Taints {
+		detail.Taints = append(detail.Taints, api.Taint{Key: taint.Key, Value: taint.Value, Effect: string(taint.Effect)})
+	}
+	for _, cond := range node.Status.Conditions {
+		detail.Conditions = append(detail.Conditions, api.Condition{
+			Type:               string(cond.Type),
+			Status:             string(cond.Status),
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+			LastTransitionTime: timePtr(&cond.LastTransitionTime),
+		})
+	}
+	for _, address := range node.Status.Addresses {
+		detail.Addresses = append(detail.Addresses, api.NodeAddress{Type: string(address.Type), Address: address.Address})
+	}
+	for _, image := range node.Status.Images {
+		detail.Images = append(detail.Images, api.ContainerImage{Names: image.Names, SizeBytes: image.SizeBytes})
+	}
+	sort.Slice(detail.Images, func(i, j int) bool { return detail.Images[i].SizeBytes > detail.Images[j].SizeBytes })
+
+	// Usage is optional: without a metrics server the detail still shows requests and limits
+	var usage corev1.ResourceList
+	podUsage := make(map[string]api.PodMetric)
+	if nodeMetrics, err := c.MetricsClient.MetricsV1beta1().NodeMetricses().Get(ctx, name, metav1.GetOptions{}); err == nil {
+		detail.MetricsAvailable = true
+		usage = nodeMetrics.Usage
+		if metrics, err := c.GetPodMetrics(ctx, "", metav1.ListOptions{}); err == nil {
+			for _, m := range metrics {
+				podUsage[m.Namespace+"/"+m.Name] = m
+			}
+		}
+	}
+
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	podCount := 0
+	for _, pod := range podList.Items {
+		// The fake clientset used in demo mode ignores field selectors
+		if pod.Spec.NodeName != name || !activePod(pod) {
+			continue
+		}
+		podCount++
+		podRequests, podLimits := podRequests(pod), podLimits(pod)
+		addResources(requests, podRequests)
+		addResources(limits, podLimits)
+
+		metric := podUsage[pod.Namespace+"/"+pod.Name]
+		detail.Pods = append(detail.Pods, api.NodePod{
+			Name:        pod.Name,
+			Namespace:   pod.Namespace,
+			Status:      podStatus(pod),
+			Requests:    resourceListToMap(podRequests),
+			Limits:      resourceListToMap(podLimits),
+			CPUUsage:    metric.CPUUsage,
+			MemoryUsage: metric.MemoryUsage,
+			Age:         formatAge(pod.CreationTimestamp.Time),
+			CreatedAt:   pod.CreationTimestamp.UTC(),
+		})
+	}
+	sort.Slice(detail.Pods, func(i, j int) bool {
+		if detail.Pods[i].Namespace != detail.Pods[j].Namespace {
+			return detail.Pods[i].Namespace < detail.Pods[j].Namespace
+		}
+		return detail.Pods[i].Name < detail.Pods[j].Name
+	})
+	requests[corev1.ResourcePods] = *resource.NewQuantity(int64(podCount), resource.DecimalSI)
+
+	detail.Resources = nodeResources(*node, requests, limits, usage)
+	return detail, nil
+}
+
+// nodeResources builds the capacity table: cpu, memory and pods first, then
+// every other resource the node reports in alphabetical order
+func nodeResources(node corev1.Node, requests, limits, usage corev1.ResourceList) []api.NodeResource {
+	names := []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourcePods}
+	var others []string
+	for name := range node.Status.Capacity {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory && name != corev1.ResourcePods {
+			others = append(others, string(name))
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		names = append(names, corev1.ResourceName(name))
+	}
+
+	var table []api.NodeResource
+	for _, name := range names {
+		allocatable := node.Status.Allocatable[name]
+		capacity := node.Status.Capacity[name]
+		requested, limited := requests[name], limits[name]
+		row := api.NodeResource{
+			Name:            string(name),
+			Capacity:        capacity.String(),
+			Allocatable:     allocatable.String(),
+			Requests:        requested.String(),
+			RequestsPercent: percentOf(requested, allocatable),
+			Limits:          limited.String(),
+			LimitsPercent:   percentOf(limited, allocatable),
+		}
+		if used, ok := usage[name]; ok {
+			row.Usage = used.String()
+			row.UsagePercent = percentOf(used, allocatable)
+		}
+		table = append(table, row)
+	}
+	return table
+}
@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+func TestGetNodeDetail(t *testing.T) {
+	node := schedulingNode("node-1", "a", "4", nil)
+	node.Status.Capacity = corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("4"),
+		corev1.ResourceMemory:           resource.MustParse("8Gi"),
+		corev1.ResourcePods:             resource.MustParse("110"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+	}
+	node.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "web", Effect: corev1.TaintEffectNoSchedule}}
+	node.Status.Images = []corev1.ContainerImage{
+		{Names: []string{"nginx:1.25"}, SizeBytes: 10},
+		{Names: []string{"envoy:1.28"}, SizeBytes: 20},
+	}
+
+	web := requesting(testPod("shop", "web-1", corev1.PodRunning, nil), "1")
+	api1 := requesting(testPod("shop", "api-1", corev1.PodRunning, nil), "500m")
+	done := requesting(testPod("shop", "job-1", corev1.PodSucceeded, nil), "2")
+	elsewhere := requesting(testPod("shop", "web-2", corev1.PodRunning, nil), "2")
+	elsewhere.Spec.NodeName = "node-2"
+	metrics := &metricsv1beta1.NodeMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Usage:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+	}
+
+	h := NewHandler(newFakeClient(t, node, web, api1, done, elsewhere, metrics))
+	w := serve(t, h, http.MethodGet, "/nodes/node-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var detail api.NodeDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Name != "node-1" || !detail.MetricsAvailable || len(detail.Taints) != 1 || len(detail.Conditions) != 1 {
+		t.Errorf("unexpected detail %+v", detail)
+	}
+	if len(detail.Pods) != 2 || detail.Pods[0].Name != "api-1" || detail.Pods[1].Name != "web-1" {
+		t.Errorf("unexpected pods %+v", detail.Pods)
+	}
+	if len(detail.Images) != 2 || detail.Images[0].Names[0] != "envoy:1.28" {
+		t.Errorf("images not sorted by size: %+v", detail.Images)
+	}
+
+	var names []string
+	for _, row := range detail.Resources {
+		names = append(names, row.Name)
+	}
+	if !equalStrings(names, []string{"cpu", "memory", "pods", "ephemeral-storage"}) {
+		t.Fatalf("resources %v", names)
+	}
+	cpu, pods := detail.Resources[0], detail.Resources[2]
+	if cpu.Requests != "1500m" || cpu.RequestsPercent != 37 || cpu.Usage != "1" || cpu.UsagePercent != 25 {
+		t.Errorf("unexpected cpu row %+v", cpu)
+	}
+	if pods.Requests != "2" || pods.Allocatable != "110" {
+		t.Errorf("unexpected pods row %+v", pods)
+	}
+
+	if w := serve(t, h, http.MethodGet, "/nodes/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("missing node: status %d", w.Code)
+	}
+}
@@ -0,0 +1,225 @@
+package k8s
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"kubelens/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionPrefix is the versioned prefix every route is served under
+const APIVersionPrefix = "/api/v1"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	bytesType      = reflect.TypeOf([]byte{})
+)
+
+// OpenAPISpec generates the OpenAPI 3 document of the routes as served under APIVersionPrefix
+func OpenAPISpec(routes []Route) map[string]interface{} {
+	gen := &schemaGenerator{schemas: make(map[string]interface{})}
+	errorSchema := gen.schema(reflect.TypeOf(api.ErrorResponse{}))
+
+	paths := make(map[string]map[string]interface{})
+	for _, route := range routes {
+		path, pathParams := openAPIPath(route.Path)
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		var parameters []interface{}
+		for _, name := range pathParams {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range route.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"description": param.Description,
+				"schema":      map[string]interface{}{"type": param.Type},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"operationId": route.OperationID,
+			"summary":     route.Summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						contentType: map[string]interface{}{"schema": gen.schema(reflect.TypeOf(route.Response))},
+					},
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": errorSchema},
+					},
+				},
+			},
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": gen.schema(reflect.TypeOf(route.Request))},
+				},
+			}
+		}
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "KubeLens API",
+			"version": "v1",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": APIVersionPrefix},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": gen.schemas,
+		},
+	}
+}
+
+// OpenAPIHandlerFunc serves the generated OpenAPI document
+func OpenAPIHandlerFunc(routes []Route) gin.HandlerFunc {
+	spec := OpenAPISpec(routes)
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	}
+}
+
+// openAPIPath converts a gin path such as /pods/:namespace/:podName to /pods/{namespace}/{podName}
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+// schema returns the JSON schema of t, registering named structs as components
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	case bytesType:
+		return map[string]interface{}{"type": "string", "format": "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, ok := g.schemas[name]; ok {
+			return ref
+		}
+		// Reserve the name first so that recursive types terminate
+		g.schemas[name] = map[string]interface{}{}
+
+		properties := make(map[string]interface{})
+		var required []string
+		g.addFields(t, properties, &required)
+
+		definition := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			definition["required"] = required
+		}
+		g.schemas[name] = definition
+		return ref
+	}
+
+	return map[string]interface{}{}
+}
+
+// addFields adds the JSON fields of struct t, inlining embedded structs as encoding/json does
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldName, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if fieldName == "-" {
+			continue
+		}
+		if fieldName == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			g.addFields(field.Type, properties, required)
+			continue
+		}
+		if fieldName == "" {
+			fieldName = field.Name
+		}
+		properties[fieldName] = g.schema(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, fieldName)
+		}
+	}
+}
+
+// schemaName names a component after its Go type. The only generic type is the
+// list envelope, so ListResponse[api.Pod] becomes PodList.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	_, arg, generic := strings.Cut(name, "[")
+	if !generic {
+		return name
+	}
+	arg = strings.TrimSuffix(arg, "]")
+	if i := strings.LastIndex(arg, "."); i >= 0 {
+		arg = arg[i+1:]
+	}
+	return arg + "List"
+}
@@ -0,0 +1,426 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AuditStore persists the audit log of changes made through KubeLens
+type AuditStore interface {
+	RecordAudit(ctx context.Context, entries []api.AuditEntry) error
+	ListAudit(ctx context.Context, since time.Time) ([]api.AuditEntry, error)
+}
+
+// Audit log actions
+const (
+	AuditDelete   = "delete"
+	AuditCopyFrom = "copy-from"
+	AuditCopyTo   = "copy-to"
+)
+
+// defaultRevisionHistoryLimit is what the API server sets when a Deployment does not
+const defaultRevisionHistoryLimit = 10
+
+// rootCAConfigMap is published into every namespace and mounted by projected service account volumes
+const rootCAConfigMap = "kube-root-ca.crt"
+
+// managedSecretTypes are secrets whose users are not pods: the token controller,
+// Helm's release history and bootstrap tokens
+var managedSecretTypes = map[corev1.SecretType]bool{
+	corev1.SecretTypeServiceAccountToken: true,
+	corev1.SecretTypeBootstrapToken:      true,
+	"helm.sh/release.v1":                 true,
+}
+
+// FindOrphans lists ConfigMaps and Secrets no pod or workload references, PVCs
+// no pod or workload mounts, Released and Available PVs, Services selecting no
+// pods and scaled down ReplicaSets beyond their Deployment's revision history
+// limit. PVs are only reported for the whole cluster. Nothing in the protected
+// namespaces is reported: the cluster keeps objects there, such as
+// kube-system/extension-apiserver-authentication, that no pod references.
+func (c *Client) FindOrphans(ctx context.Context, namespace string, protected map[string]bool) ([]api.OrphanedResource, error) {
+	if protected[namespace] {
+		return []api.OrphanedResource{}, nil
+	}
+	refs, pods, err := c.podReferences(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []api.OrphanedResource
+	add := func(kind string, obj metav1.ObjectMeta, reason string) {
+		if !protected[obj.Namespace] {
+			orphans = append(orphans, newOrphan(kind, obj, reason))
+		}
+	}
+
+	configMaps, err := c.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, cm := range configMaps.Items {
+		if cm.Name != rootCAConfigMap && len(cm.OwnerReferences) == 0 && !refs["ConfigMap/"+cm.Namespace+"/"+cm.Name] {
+			add("ConfigMap", cm.ObjectMeta, "not referenced by any pod or workload")
+		}
+	}
+	secrets, err := c.Clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		if !managedSecretTypes[secret.Type] && len(secret.OwnerReferences) == 0 && !refs["Secret/"+secret.Namespace+"/"+secret.Name] {
+			add("Secret", secret.ObjectMeta, "not referenced by any pod, workload, service account or ingress")
+		}
+	}
+	claims, err := c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range claims.Items {
+		if !refs["PersistentVolumeClaim/"+pvc.Namespace+"/"+pvc.Name] {
+			add("PersistentVolumeClaim", pvc.ObjectMeta, "not mounted by any pod or workload")
+		}
+	}
+
+	if namespace == "" {
+		pvs, err := c.GetPVs(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, pv := range pvs {
+			reason := ""
+			switch corev1.PersistentVolumePhase(pv.Phase) {
+			case corev1.VolumeReleased:
+				reason = "Released: its claim was deleted"
+			case corev1.VolumeAvailable:
+				reason = "Available: not bound to any claim"
+			default:
+				continue
+			}
+			orphans = append(orphans, api.OrphanedResource{Kind: "PersistentVolume", Name: pv.Name, Reason: reason, Age: pv.Age, CreatedAt: pv.CreatedAt})
+		}
+	}
+
+	services, err := c.Clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 || svc.Spec.Type == corev1.ServiceTypeExternalName {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		matched := false
+		for _, pod := range pods {
+			if pod.Namespace == svc.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			add("Service", svc.ObjectMeta, fmt.Sprintf("selector %s matches no pods", selector))
+		}
+	}
+
+	stale, err := c.staleReplicaSets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range stale {
+		if !protected[o.Namespace] {
+			orphans = append(orphans, o)
+		}
+	}
+
+	sort.SliceStable(orphans, func(i, j int) bool {
+		a, b := orphans[i], orphans[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return orphans, nil
+}
+
+// podReferences returns the "Kind/namespace/name" keys of the ConfigMaps,
+// Secrets and PVCs used by pods, workload templates, service accounts and
+// ingresses, along with the pods
+func (c *Client) podReferences(ctx context.Context, namespace string) (map[string]bool, []corev1.Pod, error) {
+	refs := make(map[string]bool)
+	podList, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pod := range podList.Items {
+		addSpecReferences(refs, pod.Namespace, pod.Spec)
+	}
+	// Templates keep references of workloads that are scaled down or between runs
+	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range deployments.Items {
+		addSpecReferences(refs, d.Namespace, d.Spec.Template.Spec)
+	}
+	statefulSets, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	// A StatefulSet keeps the claims of its volumeClaimTemplates, named
+	// <template>-<statefulset>-<ordinal>, when it is scaled down
+	claimPrefixes := make(map[string]bool)
+	for _, s := range statefulSets.Items {
+		addSpecReferences(refs, s.Namespace, s.Spec.Template.Spec)
+		for _, template := range s.Spec.VolumeClaimTemplates {
+			claimPrefixes[s.Namespace+"/"+template.Name+"-"+s.Name+"-"] = true
+		}
+	}
+	if len(claimPrefixes) > 0 {
+		claims, err := c.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pvc := range claims.Items {
+			if isStatefulSetClaim(pvc.Namespace+"/"+pvc.Name, claimPrefixes) {
+				refs["PersistentVolumeClaim/"+pvc.Namespace+"/"+pvc.Name] = true
+			}
+		}
+	}
+	daemonSets, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range daemonSets.Items {
+		addSpecReferences(refs, d.Namespace, d.Spec.Template.Spec)
+	}
+	cronJobs, err := c.Clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, cj := range cronJobs.Items {
+		addSpecReferences(refs, cj.Namespace, cj.Spec.JobTemplate.Spec.Template.Spec)
+	}
+
+	serviceAccounts, err := c.Clientset.CoreV1().ServiceAccounts(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, sa := range serviceAccounts.Items {
+		for _, secret := range sa.Secrets {
+			refs["Secret/"+sa.Namespace+"/"+secret.Name] = true
+		}
+		for _, secret := range sa.ImagePullSecrets {
+			refs["Secret/"+sa.Namespace+"/"+secret.Name] = true
+		}
+	}
+	ingresses, err := c.Clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ing := range ingresses.Items {
+		for _, tls := range ing.Spec.TLS {
+			refs["Secret/"+ing.Namespace+"/"+tls.SecretName] = true
+		}
+	}
+	return refs, podList.Items, nil
+}
+
+// isStatefulSetClaim reports whether "namespace/name" is one of the prefixes
+// followed by an ordinal
+func isStatefulSetClaim(key string, prefixes map[string]bool) bool {
+	for i := len(key) - 1; i > 0 && key[i] >= '0' && key[i] <= '9'; i-- {
+		if prefixes[key[:i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// addSpecReferences adds the ConfigMaps, Secrets and PVCs a pod spec uses
+// through volumes, environment variables and image pull secrets
+func addSpecReferences(refs map[string]bool, namespace string, spec corev1.PodSpec) {
+	ref := func(kind, name string) { refs[kind+"/"+namespace+"/"+name] = true }
+	for _, volume := range spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			ref("ConfigMap", volume.ConfigMap.Name)
+		case volume.Secret != nil:
+			ref("Secret", volume.Secret.SecretName)
+		case volume.PersistentVolumeClaim != nil:
+			ref("PersistentVolumeClaim", volume.PersistentVolumeClaim.ClaimName)
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					ref("ConfigMap", source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					ref("Secret", source.Secret.Name)
+				}
+			}
+		}
+	}
+	for _, secret := range spec.ImagePullSecrets {
+		ref("Secret", secret.Name)
+	}
+	containers := allContainers(spec)
+	for _, ephemeral := range spec.EphemeralContainers {
+		containers = append(containers, corev1.Container{Env: ephemeral.Env, EnvFrom: ephemeral.EnvFrom})
+	}
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if from := env.ValueFrom; from != nil {
+				if from.ConfigMapKeyRef != nil {
+					ref("ConfigMap", from.ConfigMapKeyRef.Name)
+				}
+				if from.SecretKeyRef != nil {
+					ref("Secret", from.SecretKeyRef.Name)
+				}
+			}
+		}
+		for _, from := range container.EnvFrom {
+			if from.ConfigMapRef != nil {
+				ref("ConfigMap", from.ConfigMapRef.Name)
+			}
+			if from.SecretRef != nil {
+				ref("Secret", from.SecretRef.Name)
+			}
+		}
+	}
+}
+
+// staleReplicaSets returns the scaled down ReplicaSets a Deployment keeps beyond
+// its revisionHistoryLimit, oldest revisions first, and those whose Deployment is gone
+func (c *Client) staleReplicaSets(ctx context.Context, namespace string) ([]api.OrphanedResource, error) {
+	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	limits := make(map[string]int)
+	for _, d := range deployments.Items {
+		limit := defaultRevisionHistoryLimit
+		if d.Spec.RevisionHistoryLimit != nil {
+			limit = int(*d.Spec.RevisionHistoryLimit)
+		}
+		limits[d.Namespace+"/"+d.Name] = limit
+	}
+	replicaSets, err := c.Clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	type revision struct {
+		obj      metav1.ObjectMeta
+		revision int
+	}
+	scaledDown := make(map[string][]revision)
+	var orphans []api.OrphanedResource
+	for _, rs := range replicaSets.Items {
+		if replicaCount(rs.Spec.Replicas) != 0 || rs.Status.Replicas != 0 {
+			continue
+		}
+		ref := controllerRef(rs.OwnerReferences)
+		if ref == nil || ref.Kind != "Deployment" {
+			continue
+		}
+		owner := rs.Namespace + "/" + ref.Name
+		if _, ok := limits[owner]; !ok {
+			orphans = append(orphans, newOrphan("ReplicaSet", rs.ObjectMeta, fmt.Sprintf("scaled to zero and Deployment %s no longer exists", ref.Name)))
+			continue
+		}
+		n, _ := strconv.Atoi(rs.Annotations["deployment.kubernetes.io/revision"])
+		scaledDown[owner] = append(scaledDown[owner], revision{rs.ObjectMeta, n})
+	}
+	for owner, revisions := range scaledDown {
+		limit := limits[owner]
+		if len(revisions) <= limit {
+			continue
+		}
+		sort.Slice(revisions, func(i, j int) bool { return revisions[i].revision > revisions[j].revision })
+		name := owner[strings.Index(owner, "/")+1:]
+		for _, r := range revisions[limit:] {
+			reason := fmt.Sprintf("revision %d of Deployment %s is beyond its revision history limit of %d", r.revision, name, limit)
+			orphans = append(orphans, newOrphan("ReplicaSet", r.obj, reason))
+		}
+	}
+	return orphans, nil
+}
+
+func newOrphan(kind string, obj metav1.ObjectMeta, reason string) api.OrphanedResource {
+	return api.OrphanedResource{
+		Kind: kind, Namespace: obj.Namespace, Name: obj.Name, Reason: reason,
+		Age: formatAge(obj.CreationTimestamp.Time), CreatedAt: obj.CreationTimestamp.UTC(),
+	}
+}
+
+// CheckOrphans splits items into those still orphaned, which a dry run would
+// delete, and those that are not
+func (c *Client) CheckOrphans(ctx context.Context, items []api.ObjectRef, protected map[string]bool) (*api.OrphanDeleteResult, error) {
+	orphans, err := c.FindOrphans(ctx, "", protected)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[api.ObjectRef]bool, len(orphans))
+	for _, o := range orphans {
+		current[api.ObjectRef{Kind: o.Kind, Namespace: o.Namespace, Name: o.Name}] = true
+	}
+
+	result := &api.OrphanDeleteResult{DryRun: true, Deleted: []api.ObjectRef{}, Failed: []api.DeleteError{}}
+	for _, ref := range items {
+		if !current[ref] {
+			result.Failed = append(result.Failed, api.DeleteError{ObjectRef: ref, Error: "not orphaned"})
+			continue
+		}
+		result.Deleted = append(result.Deleted, ref)
+	}
+	return result, nil
+}
+
+// DeleteOrphans deletes the orphans CheckOrphans found and returns the
+// deletions that failed, which it moves from Deleted to Failed
+func (c *Client) DeleteOrphans(ctx context.Context, result *api.OrphanDeleteResult) []api.DeleteError {
+	result.DryRun = false
+	var failed []api.DeleteError
+	deleted := []api.ObjectRef{}
+	for _, ref := range result.Deleted {
+		if err := c.deleteObject(ctx, ref); err != nil {
+			failed = append(failed, api.DeleteError{ObjectRef: ref, Error: err.Error()})
+			continue
+		}
+		deleted = append(deleted, ref)
+	}
+	result.Deleted = deleted
+	result.Failed = append(result.Failed, failed...)
+	return failed
+}
+
+// deleteObject deletes an object of one of the kinds FindOrphans reports
+func (c *Client) deleteObject(ctx context.Context, ref api.ObjectRef) error {
+	opts := metav1.DeleteOptions{}
+	switch ref.Kind {
+	case "ConfigMap":
+		return c.Clientset.CoreV1().ConfigMaps(ref.Namespace).Delete(ctx, ref.Name, opts)
+	case "Secret":
+		return c.Clientset.CoreV1().Secrets(ref.Namespace).Delete(ctx, ref.Name, opts)
+	case "PersistentVolumeClaim":
+		return c.Clientset.CoreV1().PersistentVolumeClaims(ref.Namespace).Delete(ctx, ref.Name, opts)
+	case "PersistentVolume":
+		return c.Clientset.CoreV1().PersistentVolumes().Delete(ctx, ref.Name, opts)
+	case "Service":
+		return c.Clientset.CoreV1().Services(ref.Namespace).Delete(ctx, ref.Name, opts)
+	case "ReplicaSet":
+		return c.Clientset.AppsV1().ReplicaSets(ref.Namespace).Delete(ctx, ref.Name, opts)
+	}
+	return fmt.Errorf("unsupported kind: %s", ref.Kind)
+}
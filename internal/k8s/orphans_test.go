@@ -0,0 +1,226 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"kubelens/pkg/api"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// memoryAuditStore keeps the audit log in memory for tests
+type memoryAuditStore struct {
+	entries []api.AuditEntry
+}
+
+func (m *memoryAuditStore) RecordAudit(ctx context.Context, entries []api.AuditEntry) error {
+	m.entries = append(m.entries, entries...)
+	return nil
+}
+
+func (m *memoryAuditStore) ListAudit(ctx context.Context, since time.Time) ([]api.AuditEntry, error) {
+	var entries []api.AuditEntry
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if !m.entries[i].Time.Before(since) {
+			entries = append(entries, m.entries[i])
+		}
+	}
+	return entries, nil
+}
+
+// failingAuditStore cannot write the audit log
+type failingAuditStore struct{}
+
+func (failingAuditStore) RecordAudit(ctx context.Context, entries []api.AuditEntry) error {
+	return errors.New("database is down")
+}
+
+func (failingAuditStore) ListAudit(ctx context.Context, since time.Time) ([]api.AuditEntry, error) {
+	return nil, errors.New("database is down")
+}
+
+func scaledDownReplicaSet(namespace, deployment string, revision int) *appsv1.ReplicaSet {
+	zero := int32(0)
+	rs := &appsv1.ReplicaSet{ObjectMeta: objectMeta(namespace, deployment+"-r"+strconv.Itoa(revision), nil), Spec: appsv1.ReplicaSetSpec{Replicas: &zero}}
+	rs.Annotations = map[string]string{"deployment.kubernetes.io/revision": strconv.Itoa(revision)}
+	controller := true
+	rs.OwnerReferences = []metav1.OwnerReference{{Kind: "Deployment", Name: deployment, Controller: &controller}}
+	return rs
+}
+
+func orphanObjects() []runtime.Object {
+	pod := testPod("shop", "web-1", corev1.PodRunning, map[string]string{"app": "web"})
+	pod.Spec.Volumes = []corev1.Volume{
+		{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}}}},
+		{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+	}
+	pod.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-env"}}}}
+
+	// A Deployment scaled to zero still needs its ConfigMap
+	report := corev1.PodSpec{Containers: []corev1.Container{{Name: "report", Env: []corev1.EnvVar{{Name: "MODE", ValueFrom: &corev1.EnvVarSource{
+		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "report-config"}, Key: "mode"},
+	}}}}}}
+	d := hardenedDeployment("shop", "report", 0)
+	d.Spec.Template.Spec = report
+	limit := int32(1)
+	web := hardenedDeployment("shop", "web", 1)
+	web.Spec.RevisionHistoryLimit = &limit
+
+	orphanSvc := testService("shop", "legacy")
+	orphanSvc.Spec.Selector = map[string]string{"app": "legacy"}
+
+	// A StatefulSet scaled to zero keeps the claims of its replicas
+	sts := testStatefulSet("shop", "db", 0, 0)
+	sts.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+
+	return []runtime.Object{
+		pod, d, web, testService("shop", "web"), orphanSvc, sts,
+		&corev1.ConfigMap{ObjectMeta: objectMeta("shop", "web-config", nil)},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("shop", "report-config", nil)},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("shop", "old-config", nil)},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("shop", rootCAConfigMap, nil)},
+		&corev1.Secret{ObjectMeta: objectMeta("shop", "web-env", nil)},
+		&corev1.Secret{ObjectMeta: objectMeta("shop", "old-token", nil)},
+		&corev1.Secret{ObjectMeta: objectMeta("shop", "sa-token", nil), Type: corev1.SecretTypeServiceAccountToken},
+		&corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("shop", "data", nil)},
+		&corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("shop", "scratch", nil)},
+		&corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("shop", "data-db-0", nil)},
+		&corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("shop", "data-db-2", nil)},
+		&corev1.PersistentVolumeClaim{ObjectMeta: objectMeta("shop", "data-db-backup", nil)},
+		&corev1.ConfigMap{ObjectMeta: objectMeta("kube-system", "extension-apiserver-authentication", nil)},
+		&corev1.PersistentVolume{ObjectMeta: objectMeta("", "pv-bound", nil), Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound}},
+		&corev1.PersistentVolume{ObjectMeta: objectMeta("", "pv-released", nil), Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased}},
+		scaledDownReplicaSet("shop", "web", 1),
+		scaledDownReplicaSet("shop", "web", 2),
+		scaledDownReplicaSet("shop", "gone", 1),
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	c := newFakeClient(t, orphanObjects()...)
+	protected := stringSet(DefaultProtectedNamespaces)
+	orphans, err := c.FindOrphans(context.Background(), "", protected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range orphans {
+		got = append(got, o.Kind+"/"+o.Name)
+	}
+	want := []string{
+		"ConfigMap/old-config",
+		"PersistentVolume/pv-released",
+		"PersistentVolumeClaim/data-db-backup",
+		"PersistentVolumeClaim/scratch",
+		"ReplicaSet/gone-r1",
+		"ReplicaSet/web-r1",
+		"Secret/old-token",
+		"Service/legacy",
+	}
+	if !equalStrings(got, want) {
+		t.Fatalf("orphans %v, want %v", got, want)
+	}
+
+	// PVs are cluster scoped and left out of a namespace's report
+	orphans, err = c.FindOrphans(context.Background(), "shop", protected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != len(want)-1 {
+		t.Errorf("namespace orphans %+v", orphans)
+	}
+
+	// Without protection the cluster's own objects show up
+	orphans, err = c.FindOrphans(context.Background(), "kube-system", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0].Name != "extension-apiserver-authentication" {
+		t.Errorf("kube-system orphans %+v", orphans)
+	}
+	if orphans, err = c.FindOrphans(context.Background(), "kube-system", protected); err != nil || len(orphans) != 0 {
+		t.Errorf("protected namespace orphans %+v: %v", orphans, err)
+	}
+}
+
+func TestDeleteOrphansHandler(t *testing.T) {
+	c := newFakeClient(t, orphanObjects()...)
+	req := api.OrphanDeleteRequest{Items: []api.ObjectRef{
+		{Kind: "ConfigMap", Namespace: "shop", Name: "old-config"},
+		{Kind: "ConfigMap", Namespace: "shop", Name: "web-config"},
+		{Kind: "ConfigMap", Namespace: "kube-system", Name: "extension-apiserver-authentication"},
+	}}
+
+	// Deleting for real needs somewhere to audit it
+	if w := serveJSON(t, NewHandler(c), http.MethodPost, "/orphans/delete?dryRun=false", req); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without audit store status %d", w.Code)
+	}
+	// Nothing is deleted unless it was audited first
+	if w := serveJSON(t, NewHandler(c, WithAuditStore(failingAuditStore{})), http.MethodPost, "/orphans/delete?dryRun=false", req); w.Code != http.StatusInternalServerError {
+		t.Errorf("failing audit store status %d", w.Code)
+	}
+	if _, err := c.Clientset.CoreV1().ConfigMaps("shop").Get(context.Background(), "old-config", metav1.GetOptions{}); err != nil {
+		t.Fatalf("old-config deleted without an audit entry: %v", err)
+	}
+
+	audit := &memoryAuditStore{}
+	h := NewHandler(c, WithAuditStore(audit))
+	w := serveJSON(t, h, http.MethodPost, "/orphans/delete", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("dry run status %d: %s", w.Code, w.Body.String())
+	}
+	var result api.OrphanDeleteResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || len(result.Deleted) != 1 || len(result.Failed) != 2 || result.Failed[0].Name != "web-config" {
+		t.Fatalf("dry run result %+v", result)
+	}
+	if _, err := c.Clientset.CoreV1().ConfigMaps("shop").Get(context.Background(), "old-config", metav1.GetOptions{}); err != nil {
+		t.Fatalf("dry run deleted old-config: %v", err)
+	}
+
+	w = serveJSON(t, h, http.MethodPost, "/orphans/delete?dryRun=false", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete status %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := c.Clientset.CoreV1().ConfigMaps("shop").Get(context.Background(), "old-config", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("old-config not deleted: %v", err)
+	}
+	if _, err := c.Clientset.CoreV1().ConfigMaps("shop").Get(context.Background(), "web-config", metav1.GetOptions{}); err != nil {
+		t.Errorf("web-config is in use and must stay: %v", err)
+	}
+	if _, err := c.Clientset.CoreV1().ConfigMaps("kube-system").Get(context.Background(), "extension-apiserver-authentication", metav1.GetOptions{}); err != nil {
+		t.Errorf("objects in protected namespaces must stay: %v", err)
+	}
+
+	if len(audit.entries) != 6 {
+		t.Fatalf("audit entries %+v", audit.entries)
+	}
+	last := audit.entries[3]
+	if last.DryRun || last.Action != AuditDelete || last.Name != "old-config" || last.Error != "" {
+		t.Errorf("audit entry %+v", last)
+	}
+	if failed := audit.entries[4]; failed.Name != "web-config" || failed.Error != "not orphaned" {
+		t.Errorf("failed audit entry %+v", failed)
+	}
+
+	w = serve(t, h, http.MethodGet, "/audit")
+	var page api.ListResponse[api.AuditEntry]
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 6 || page.Items[0].Name != "extension-apiserver-authentication" {
+		t.Errorf("audit page %+v", page)
+	}
+}
@@ -0,0 +1,339 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// GetPodDetail returns everything `kubectl describe pod` shows for a single pod
+func (c *Client) GetPodDetail(ctx context.Context, namespace, name string) (*api.PodDetail, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := c.resolveOwnerChain(ctx, namespace, pod.OwnerReferences)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := c.getObjectEvents(ctx, namespace, "Pod", pod.Name, pod.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &api.PodDetail{
+		Name:              pod.Name,
+		Namespace:         pod.Namespace,
+		UID:               string(pod.UID),
+		Status:            podStatus(*pod),
+		Phase:             string(pod.Status.Phase),
+		Reason:            pod.Status.Reason,
+		Message:           pod.Status.Message,
+		Node:              pod.Spec.NodeName,
+		PodIP:             pod.Status.PodIP,
+		HostIP:            pod.Status.HostIP,
+		ServiceAccount:    pod.Spec.ServiceAccountName,
+		PriorityClassName: pod.Spec.PriorityClassName,
+		QOSClass:          string(pod.Status.QOSClass),
+		RestartPolicy:     string(pod.Spec.RestartPolicy),
+		Labels:            pod.Labels,
+		Annotations:       pod.Annotations,
+		NodeSelector:      pod.Spec.NodeSelector,
+		Owners:            owners,
+		Events:            events,
+		Age:               formatAge(pod.CreationTimestamp.Time),
+		CreatedAt:         pod.CreationTimestamp.UTC(),
+		StartTime:         timePtr(pod.Status.StartTime),
+		DeletionTimestamp: timePtr(pod.DeletionTimestamp),
+	}
+
+	for _, container := range pod.Spec.InitContainers {
+		detail.InitContainers = append(detail.InitContainers, describeContainer(container, findContainerStatus(pod.Status.InitContainerStatuses, container.Name)))
+	}
+
+	for _, container := range pod.Spec.Containers {
+		detail.Containers = append(detail.Containers, describeContainer(container, findContainerStatus(pod.Status.ContainerStatuses, container.Name)))
+	}
+
+	for _, ec := range pod.Spec.EphemeralContainers {
+		container := corev1.Container(ec.EphemeralContainerCommon)
+		described := describeContainer(container, findContainerStatus(pod.Status.EphemeralContainerStatuses, container.Name))
+		described.TargetContainerName = ec.TargetContainerName
+		detail.EphemeralContainers = append(detail.EphemeralContainers, described)
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		detail.Conditions = append(detail.Conditions, api.Condition{
+			Type:               string(cond.Type),
+			Status:             string(cond.Status),
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+			LastTransitionTime: timePtr(&cond.LastTransitionTime),
+		})
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		volumeType, source := describeVolumeSource(volume.VolumeSource)
+		detail.Volumes = append(detail.Volumes, api.Volume{
+			Name:   volume.Name,
+			Type:   volumeType,
+			Source: source,
+		})
+	}
+
+	for _, toleration := range pod.Spec.Tolerations {
+		detail.Tolerations = append(detail.Tolerations, api.Toleration{
+			Key:               toleration.Key,
+			Operator:          string(toleration.Operator),
+			Value:             toleration.Value,
+			Effect:            string(toleration.Effect),
+			TolerationSeconds: toleration.TolerationSeconds,
+		})
+	}
+
+	return detail, nil
+}
+
+// resolveOwnerChain follows controller owner references upwards,
+// e.g. Pod -> ReplicaSet -> Deployment or Pod -> Job -> CronJob. The chain
+// ends at an owner that no longer exists.
+func (c *Client) resolveOwnerChain(ctx context.Context, namespace string, refs []metav1.OwnerReference) ([]api.OwnerReference, error) {
+	var chain []api.OwnerReference
+
+	for depth := 0; depth < 10; depth++ {
+		ref := controllerRef(refs)
+		if ref == nil {
+			break
+		}
+		chain = append(chain, api.OwnerReference{
+			Kind: ref.Kind,
+			Name: ref.Name,
+			UID:  string(ref.UID),
+		})
+
+		var next []metav1.OwnerReference
+		switch ref.Kind {
+		case "ReplicaSet":
+			rs, err := c.Clientset.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return chain, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			next = rs.OwnerReferences
+		case "Job":
+			job, err := c.Clientset.BatchV1().Jobs(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return chain, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			next = job.OwnerReferences
+		}
+		refs = next
+	}
+
+	return chain, nil
+}
+
+// getObjectEvents returns the events whose involved object matches the given object
+func (c *Client) getObjectEvents(ctx context.Context, namespace, kind, name string, uid types.UID) ([]api.ObjectEvent, error) {
+	selector := fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", kind, name)
+	eventList, err := c.Clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	items := eventList.Items
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(items[i]).Before(eventTime(items[j]))
+	})
+
+	var events []api.ObjectEvent
+	for _, event := range items {
+		if event.InvolvedObject.Kind != kind || event.InvolvedObject.Name != name {
+			continue
+		}
+		if uid != "" && event.InvolvedObject.UID != "" && event.InvolvedObject.UID != uid {
+			continue
+		}
+		events = append(events, newObjectEvent(event))
+	}
+
+	return events, nil
+}
+
+func newObjectEvent(event corev1.Event) api.ObjectEvent {
+	return api.ObjectEvent{
+		Type:     event.Type,
+		Reason:   event.Reason,
+		Message:  event.Message,
+		Count:    event.Count,
+		Source:   event.Source.Component,
+		Age:      formatAge(eventTime(event)),
+		LastSeen: eventTime(event).UTC(),
+	}
+}
+
+func describeContainer(container corev1.Container, status *corev1.ContainerStatus) api.ContainerDetail {
+	detail := api.ContainerDetail{
+		Name:            container.Name,
+		Image:           container.Image,
+		ImagePullPolicy: string(container.ImagePullPolicy),
+		Command:         container.Command,
+		Args:            container.Args,
+		Resources: api.ResourceRequirements{
+			Requests: resourceListToMap(container.Resources.Requests),
+			Limits:   resourceListToMap(container.Resources.Limits),
+		},
+		LivenessProbe:  container.LivenessProbe != nil,
+		ReadinessProbe: container.ReadinessProbe != nil,
+		StartupProbe:   container.StartupProbe != nil,
+	}
+
+	for _, port := range container.Ports {
+		detail.Ports = append(detail.Ports, api.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.ContainerPort,
+			Protocol:      string(port.Protocol),
+		})
+	}
+
+	for _, mount := range container.VolumeMounts {
+		detail.VolumeMounts = append(detail.VolumeMounts, api.VolumeMount{
+			Name:      mount.Name,
+			MountPath: mount.MountPath,
+			SubPath:   mount.SubPath,
+			ReadOnly:  mount.ReadOnly,
+		})
+	}
+
+	if status != nil {
+		detail.ImageID = status.ImageID
+		detail.ContainerID = status.ContainerID
+		detail.Ready = status.Ready
+		detail.Started = status.Started
+		detail.RestartCount = status.RestartCount
+		detail.State = describeContainerState(status.State)
+		detail.LastState = describeContainerState(status.LastTerminationState)
+	}
+
+	return detail
+}
+
+func describeContainerState(state corev1.ContainerState) *api.ContainerState {
+	switch {
+	case state.Waiting != nil:
+		return &api.ContainerState{
+			State:   "Waiting",
+			Reason:  state.Waiting.Reason,
+			Message: state.Waiting.Message,
+		}
+	case state.Running != nil:
+		return &api.ContainerState{
+			State:     "Running",
+			StartedAt: timePtr(&state.Running.StartedAt),
+		}
+	case state.Terminated != nil:
+		exitCode := state.Terminated.ExitCode
+		return &api.ContainerState{
+			State:      "Terminated",
+			Reason:     state.Terminated.Reason,
+			Message:    state.Terminated.Message,
+			ExitCode:   &exitCode,
+			Signal:     state.Terminated.Signal,
+			StartedAt:  timePtr(&state.Terminated.StartedAt),
+			FinishedAt: timePtr(&state.Terminated.FinishedAt),
+		}
+	}
+	return nil
+}
+
+func describeVolumeSource(source corev1.VolumeSource) (string, string) {
+	switch {
+	case source.PersistentVolumeClaim != nil:
+		return "PersistentVolumeClaim", source.PersistentVolumeClaim.ClaimName
+	case source.ConfigMap != nil:
+		return "ConfigMap", source.ConfigMap.Name
+	case source.Secret != nil:
+		return "Secret", source.Secret.SecretName
+	case source.EmptyDir != nil:
+		return "EmptyDir", string(source.EmptyDir.Medium)
+	case source.HostPath != nil:
+		return "HostPath", source.HostPath.Path
+	case source.Projected != nil:
+		return "Projected", ""
+	case source.DownwardAPI != nil:
+		return "DownwardAPI", ""
+	case source.NFS != nil:
+		return "NFS", fmt.Sprintf("%s:%s", source.NFS.Server, source.NFS.Path)
+	case source.CSI != nil:
+		return "CSI", source.CSI.Driver
+	case source.Ephemeral != nil:
+		return "Ephemeral", ""
+	}
+	return "Other", ""
+}
+
+func findContainerStatus(statuses []corev1.ContainerStatus, name string) *corev1.ContainerStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+func controllerRef(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	if len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
+
+func resourceListToMap(list corev1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(list))
+	for name, quantity := range list {
+		result[string(name)] = quantity.String()
+	}
+	return result
+}
+
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// timePtr converts an optional Kubernetes timestamp, returning nil when unset
+func timePtr(t *metav1.Time) *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
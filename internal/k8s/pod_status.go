@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodeLostReason is set by the node lifecycle controller on pods of an unreachable node
+const nodeLostReason = "NodeLost"
+
+// podStatus returns the status kubectl get pods shows: the phase refined by the
+// pod's reason, init container progress, container waiting and terminated
+// reasons, and Terminating once a pod that has not finished is being deleted
+func podStatus(pod corev1.Pod) string {
+	reason := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Reason == corev1.PodReasonSchedulingGated {
+			reason = corev1.PodReasonSchedulingGated
+		}
+	}
+
+	initializing := false
+	for i, container := range pod.Status.InitContainerStatuses {
+		switch {
+		case container.State.Terminated != nil && container.State.Terminated.ExitCode == 0:
+			continue
+		case isSidecar(pod, container.Name) && container.Started != nil && *container.Started:
+			continue
+		case container.State.Terminated != nil:
+			terminated := container.State.Terminated
+			switch {
+			case terminated.Reason != "":
+				reason = "Init:" + terminated.Reason
+			case terminated.Signal != 0:
+				reason = fmt.Sprintf("Init:Signal:%d", terminated.Signal)
+			default:
+				reason = fmt.Sprintf("Init:ExitCode:%d", terminated.ExitCode)
+			}
+		case container.State.Waiting != nil && container.State.Waiting.Reason != "" && container.State.Waiting.Reason != "PodInitializing":
+			reason = "Init:" + container.State.Waiting.Reason
+		default:
+			reason = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		initializing = true
+		break
+	}
+
+	if !initializing || podConditionTrue(pod, corev1.PodInitialized) {
+		hasRunning := false
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			container := pod.Status.ContainerStatuses[i]
+			switch {
+			case container.State.Waiting != nil && container.State.Waiting.Reason != "":
+				reason = container.State.Waiting.Reason
+			case container.State.Terminated != nil && container.State.Terminated.Reason != "":
+				reason = container.State.Terminated.Reason
+			case container.State.Terminated != nil && container.State.Terminated.Signal != 0:
+				reason = fmt.Sprintf("Signal:%d", container.State.Terminated.Signal)
+			case container.State.Terminated != nil:
+				reason = fmt.Sprintf("ExitCode:%d", container.State.Terminated.ExitCode)
+			case container.Ready && container.State.Running != nil:
+				hasRunning = true
+			}
+		}
+		// A completed container next to running ones, e.g. a finished sidecar, does not complete the pod
+		if reason == "Completed" && hasRunning {
+			if podConditionTrue(pod, corev1.PodReady) {
+				reason = "Running"
+			} else {
+				reason = "NotReady"
+			}
+		}
+	}
+
+	// Finished pods keep their status while their deletion is pending
+	terminal := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+	if pod.DeletionTimestamp != nil && pod.Status.Reason == nodeLostReason {
+		reason = "Unknown"
+	} else if pod.DeletionTimestamp != nil && !terminal {
+		reason = "Terminating"
+	}
+	return reason
+}
+
+// isSidecar reports whether an init container keeps running next to the main containers
+func isSidecar(pod corev1.Pod, name string) bool {
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == name {
+			return container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+		}
+	}
+	return false
+}
+
+func podConditionTrue(pod corev1.Pod, conditionType corev1.PodConditionType) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodStatus(t *testing.T) {
+	waiting := func(reason string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}
+	}
+	terminated := func(reason string, code, signal int32) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: reason, ExitCode: code, Signal: signal}}
+	}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	always := corev1.ContainerRestartPolicyAlways
+	started := true
+	deleted := metav1.NewTime(time.Now())
+
+	tests := []struct {
+		name   string
+		modify func(pod *corev1.Pod)
+		want   string
+	}{
+		{"running", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = running
+			pod.Status.ContainerStatuses[1].State = running
+		}, "Running"},
+		{"crash loop in running phase", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = waiting("CrashLoopBackOff")
+			pod.Status.ContainerStatuses[1].State = running
+		}, "CrashLoopBackOff"},
+		{"container creating", func(pod *corev1.Pod) {
+			pod.Status.Phase = corev1.PodPending
+			pod.Status.ContainerStatuses[0].State = waiting("ContainerCreating")
+		}, "ContainerCreating"},
+		{"pending without statuses", func(pod *corev1.Pod) {
+			pod.Status.Phase = corev1.PodPending
+			pod.Status.ContainerStatuses = nil
+		}, "Pending"},
+		{"evicted", func(pod *corev1.Pod) {
+			pod.Status.Phase, pod.Status.Reason = corev1.PodFailed, "Evicted"
+			pod.Status.ContainerStatuses = nil
+		}, "Evicted"},
+		{"exit code without reason", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = terminated("", 2, 0)
+		}, "ExitCode:2"},
+		{"signal without reason", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = terminated("", 0, 9)
+		}, "Signal:9"},
+		{"completed container next to a running one", func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].State = terminated("Completed", 0, 0)
+			pod.Status.ContainerStatuses[1].State = running
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}}
+		}, "NotReady"},
+		{"init container running", func(pod *corev1.Pod) {
+			pod.Status.Phase = corev1.PodPending
+			pod.Spec.InitContainers = []corev1.Container{{Name: "migrate"}, {Name: "warm"}}
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+				{Name: "migrate", State: terminated("Completed", 0, 0)},
+				{Name: "warm", State: running},
+			}
+			pod.Status.ContainerStatuses[0].State = waiting("PodInitializing")
+		}, "Init:1/2"},
+		{"init container crash loop", func(pod *corev1.Pod) {
+			pod.Spec.InitContainers = []corev1.Container{{Name: "migrate"}}
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{Name: "migrate", State: waiting("CrashLoopBackOff")}}
+		}, "Init:CrashLoopBackOff"},
+		{"init container failed", func(pod *corev1.Pod) {
+			pod.Spec.InitContainers = []corev1.Container{{Name: "migrate"}}
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{Name: "migrate", State: terminated("", 3, 0)}}
+		}, "Init:ExitCode:3"},
+		{"started sidecar", func(pod *corev1.Pod) {
+			pod.Spec.InitContainers = []corev1.Container{{Name: "proxy", RestartPolicy: &always}}
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{Name: "proxy", State: running, Started: &started}}
+			pod.Status.ContainerStatuses[0].State = running
+			pod.Status.ContainerStatuses[1].State = running
+		}, "Running"},
+		{"terminating", func(pod *corev1.Pod) {
+			pod.DeletionTimestamp = &deleted
+		}, "Terminating"},
+		{"deleted after completing", func(pod *corev1.Pod) {
+			pod.DeletionTimestamp = &deleted
+			pod.Status.Phase = corev1.PodSucceeded
+			pod.Status.ContainerStatuses[0].State = terminated("Completed", 0, 0)
+			pod.Status.ContainerStatuses[1].State = terminated("Completed", 0, 0)
+		}, "Completed"},
+		{"deleted after failing", func(pod *corev1.Pod) {
+			pod.DeletionTimestamp = &deleted
+			pod.Status.Phase = corev1.PodFailed
+			pod.Status.ContainerStatuses[0].State = terminated("Error", 1, 0)
+		}, "Error"},
+		{"node lost", func(pod *corev1.Pod) {
+			pod.DeletionTimestamp = &deleted
+			pod.Status.Reason = nodeLostReason
+		}, "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := testPod("shop", "web-1", corev1.PodRunning, nil)
+			tt.modify(pod)
+			if got := podStatus(*pod); got != tt.want {
+				t.Errorf("status %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/rest"
+)
+
+// ErrProxyUnavailable is returned by clients without a connection to a real API server
+var ErrProxyUnavailable = errors.New("the proxy needs a connection to a cluster")
+
+// ErrInvalidProxyTarget is returned for malformed namespaces, names or ports
+var ErrInvalidProxyTarget = errors.New("invalid proxy target")
+
+// proxyPort is a port number or name, optionally with the scheme the API
+// server should use to reach it, e.g. https:8443
+var proxyPort = regexp.MustCompile(`^(https?:)?[a-z0-9]([-a-z0-9]{0,13}[a-z0-9])?$`)
+
+// proxySandbox is the Content-Security-Policy of proxied pages: scripts and
+// forms work, but not as KubeLens' origin
+const proxySandbox = "sandbox allow-scripts allow-forms allow-popups allow-downloads"
+
+// Proxy returns a reverse proxy to a port of a pod or service ("pods" or
+// "services") through the API server's proxy subresource. Requests are sent to
+// suffix below the port, with KubeLens' own credentials for the API server.
+func (c *Client) Proxy(resource, namespace, name, port, suffix string) (*httputil.ReverseProxy, error) {
+	if c.Config == nil {
+		return nil, ErrProxyUnavailable
+	}
+	if resource != "pods" && resource != "services" {
+		return nil, fmt.Errorf("%w: cannot proxy to %s", ErrInvalidProxyTarget, resource)
+	}
+	if len(validation.IsDNS1123Label(namespace)) > 0 || len(validation.IsDNS1123Subdomain(name)) > 0 || !proxyPort.MatchString(port) {
+		return nil, fmt.Errorf("%w: %s/%s port %s", ErrInvalidProxyTarget, namespace, name, port)
+	}
+
+	server, _, err := rest.DefaultServerUrlFor(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := rest.TransportFor(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	base := path.Join(server.Path, "/api/v1/namespaces", namespace, resource, name+":"+port, "proxy")
+	target, err := proxyPath(base, suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = server.Scheme
+			req.URL.Host = server.Host
+			req.URL.Path = target
+			req.URL.RawPath = ""
+			req.Host = server.Host
+		},
+		Transport: transport,
+		// Pages of the pod are served from KubeLens' origin, so they run
+		// sandboxed in an origin of their own, away from its API and cookies
+		ModifyResponse: func(resp *http.Response) error {
+			if isDocument(resp.Header.Get("Content-Type")) {
+				resp.Header.Add("Content-Security-Policy", proxySandbox)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("Error proxying to %s %s/%s:%s: %v", resource, namespace, name, port, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		},
+	}, nil
+}
+
+// proxyPath joins the suffix to base, cleaned, and rejects suffixes that leave
+// base, also once percent-decoded, so ".." or "%2e%2e" cannot reach other API
+// server paths with KubeLens' credentials
+func proxyPath(base, suffix string) (string, error) {
+	candidates := []string{suffix}
+	if decoded, err := url.PathUnescape(suffix); err == nil && decoded != suffix {
+		candidates = append(candidates, decoded)
+	}
+	for _, candidate := range candidates {
+		if target := path.Join(base, candidate); target != base && !strings.HasPrefix(target, base+"/") {
+			return "", fmt.Errorf("%w: path %q leaves the proxied port", ErrInvalidProxyTarget, suffix)
+		}
+	}
+	target := path.Join(base, suffix)
+	if suffix == "" || strings.HasSuffix(suffix, "/") {
+		target += "/"
+	}
+	return target, nil
+}
+
+// isDocument reports whether a content type can run scripts when opened in a browser
+func isDocument(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Browsers sniff responses without a usable type
+		return true
+	}
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "image/svg+xml", "text/xml", "application/xml":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+// fakeAPIServer records the proxied request and answers with its path
+func fakeAPIServer(t *testing.T) (*httptest.Server, *http.Request) {
+	t.Helper()
+	var received http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = *r.Clone(r.Context())
+		w.Header().Set("Content-Type", "text/plain")
+		if strings.HasSuffix(r.URL.Path, ".html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		io.WriteString(w, r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &received
+}
+
+func TestProxyHandler(t *testing.T) {
+	srv, received := fakeAPIServer(t)
+	c := newFakeClient(t)
+	c.Config = &rest.Config{Host: srv.URL, BearerToken: "cluster-token"}
+	h := NewHandler(c, WithProxyToken("s3cret"))
+
+	if w := serve(t, NewHandler(c), http.MethodGet, "/proxy/pods/shop/web-1/8080/"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without token configured status %d", w.Code)
+	}
+	if w := serve(t, h, http.MethodGet, "/proxy/pods/shop/web-1/8080/"); w.Code != http.StatusUnauthorized {
+		t.Errorf("without token status %d", w.Code)
+	}
+
+	w := serve(t, h, http.MethodGet, "/proxy/pods/shop/web-1/8080/metrics?token=s3cret&format=text")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Body.String(); got != "/api/v1/namespaces/shop/pods/web-1:8080/proxy/metrics" {
+		t.Errorf("proxied path %s", got)
+	}
+	if received.URL.RawQuery != "format=text" {
+		t.Errorf("query %q, the token must not be forwarded", received.URL.RawQuery)
+	}
+	if auth := received.Header.Get("Authorization"); auth != "Bearer cluster-token" {
+		t.Errorf("API server authorization %q", auth)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != proxyTokenCookie || cookies[0].Path != "/proxy/pods/shop/web-1/8080" || !cookies[0].HttpOnly {
+		t.Errorf("cookies %+v", cookies)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); csp != "" {
+		t.Errorf("plain text response with policy %q", csp)
+	}
+
+	// Later requests of the proxied page carry the cookie, which is not forwarded either
+	w = serveJSON(t, h, http.MethodPost, "/proxy/services/shop/web/https:admin/api/reload", nil, func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: proxyTokenCookie, Value: "s3cret"})
+		req.AddCookie(&http.Cookie{Name: "session", Value: "app"})
+	})
+	if w.Code != http.StatusOK || w.Body.String() != "/api/v1/namespaces/shop/services/web:https:admin/proxy/api/reload" {
+		t.Errorf("service proxy %d %s", w.Code, w.Body.String())
+	}
+	if received.Method != http.MethodPost || received.Header.Get("Cookie") != "session=app" {
+		t.Errorf("forwarded %s with cookies %q", received.Method, received.Header.Get("Cookie"))
+	}
+
+	if w := serve(t, h, http.MethodGet, "/proxy/pods/shop/web-1/not_a_port/?token=s3cret"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid port status %d", w.Code)
+	}
+
+	// Pages run sandboxed, away from KubeLens' origin
+	w = serve(t, h, http.MethodGet, "/proxy/pods/shop/web-1/8080/admin/index.html?token=s3cret")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Security-Policy") != proxySandbox {
+		t.Errorf("page status %d, headers %v", w.Code, w.Header())
+	}
+
+	// Paths cannot climb out of the proxied port, however they are encoded
+	received.URL.Path = ""
+	for _, target := range []string{
+		"/proxy/pods/shop/web-1/8080/../../../../secrets",
+		"/proxy/pods/shop/web-1/8080/%2e%2e/%2e%2e/%2e%2e/%2e%2e/secrets",
+		"/proxy/pods/shop/web-1/8080/..%2f..%2f..%2f..%2fsecrets",
+		"/proxy/pods/shop/web-1/8080/%252e%252e/%252e%252e/%252e%252e/%252e%252e/secrets",
+	} {
+		if w := serve(t, h, http.MethodGet, target+"?token=s3cret"); w.Code != http.StatusBadRequest {
+			t.Errorf("%s status %d", target, w.Code)
+		}
+	}
+	if received.URL.Path != "" {
+		t.Errorf("traversal reached the API server at %s", received.URL.Path)
+	}
+	w = serve(t, h, http.MethodGet, "/proxy/pods/shop/web-1/8080/static/../app.js?token=s3cret")
+	if w.Body.String() != "/api/v1/namespaces/shop/pods/web-1:8080/proxy/app.js" {
+		t.Errorf("cleaned path %s", w.Body.String())
+	}
+}
+
+func TestProxyNeedsCluster(t *testing.T) {
+	c := newFakeClient(t)
+	if _, err := c.Proxy("pods", "shop", "web-1", "80", "/"); !errors.Is(err, ErrProxyUnavailable) {
+		t.Errorf("fake client error %v", err)
+	}
+	c.Config = &rest.Config{Host: "https://cluster.example.com"}
+	for _, target := range [][3]string{{"Shop", "web", "80"}, {"shop", "web/../x", "80"}, {"shop", "web", "80/x"}} {
+		if _, err := c.Proxy("pods", target[0], target[1], target[2], "/"); !errors.Is(err, ErrInvalidProxyTarget) {
+			t.Errorf("Proxy(%v) error %v", target, err)
+		}
+	}
+	if _, err := c.Proxy("secrets", "shop", "web", "80", "/"); !errors.Is(err, ErrInvalidProxyTarget) {
+		t.Errorf("proxy to secrets error %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// podRequests returns the effective requests of a pod as the scheduler counts
+// them: the larger of the containers' sum and any single init container, plus overhead
+func podRequests(pod corev1.Pod) corev1.ResourceList {
+	return podResources(pod, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Requests })
+}
+
+// podLimits returns the effective limits of a pod, computed like podRequests
+func podLimits(pod corev1.Pod) corev1.ResourceList {
+	return podResources(pod, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Limits })
+}
+
+func podResources(pod corev1.Pod, pick func(corev1.ResourceRequirements) corev1.ResourceList) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(total, pick(container.Resources))
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range pick(container.Resources) {
+			if current, ok := total[name]; !ok || quantity.Cmp(current) > 0 {
+				total[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	addResources(total, pod.Spec.Overhead)
+	return total
+}
+
+func addResources(total, add corev1.ResourceList) {
+	for name, quantity := range add {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
+
+// percentOf returns used as a whole percentage of total, 0 when total is zero
+func percentOf(used, total resource.Quantity) int {
+	if total.IsZero() {
+		return 0
+	}
+	return int(used.MilliValue() * 100 / total.MilliValue())
+}
+
+// activePod reports whether a pod still holds its node's resources
+func activePod(pod corev1.Pod) bool {
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
@@ -0,0 +1,308 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Statuses of a right-sizing recommendation
+const (
+	RightSized       = "RightSized"
+	OverProvisioned  = "OverProvisioned"
+	UnderProvisioned = "UnderProvisioned"
+	InsufficientData = "InsufficientData"
+)
+
+const (
+	// minRecommendationSamples is how many sampling rounds a container needs before it gets a suggestion
+	minRecommendationSamples = 10
+	// requestHeadroom is added on top of the usage percentile a request is based on
+	requestHeadroom = 1.15
+	// overProvisionedRatio flags requests this many times larger than the suggestion
+	overProvisionedRatio = 1.5
+	// Differences below these are not worth a redeploy
+	minCPUDifference    = 50               // millicores
+	minMemoryDifference = 64 * 1024 * 1024 // bytes
+)
+
+// UsageStore persists container usage samples, in Postgres or in memory
+type UsageStore interface {
+	RecordUsage(ctx context.Context, samples []api.UsageSample) error
+	ListUsage(ctx context.Context, namespace string, since time.Time) ([]api.UsageSample, error)
+	DeleteUsageBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+// SampleUsage reads the current usage of every container from the metrics
+// server, attributed to the workload owning its pod
+func (c *Client) SampleUsage(ctx context.Context) ([]api.UsageSample, error) {
+	metrics, err := c.GetPodMetrics(ctx, "", metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podList, err := c.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	owners, err := c.workloadOwners(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	pods := make(map[string]corev1.Pod, len(podList.Items))
+	for _, pod := range podList.Items {
+		pods[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	now := time.Now().UTC()
+	var samples []api.UsageSample
+	for _, m := range metrics {
+		kind, name := "Pod", m.Name
+		if pod, ok := pods[m.Namespace+"/"+m.Name]; ok {
+			kind, name = podWorkload(pod, owners)
+		}
+		for _, container := range m.Containers {
+			samples = append(samples, api.UsageSample{
+				Time:         now,
+				Namespace:    m.Namespace,
+				Pod:          m.Name,
+				Container:    container.Name,
+				WorkloadKind: kind,
+				Workload:     name,
+				CPUUsage:     container.CPUUsage,
+				MemoryUsage:  container.MemoryUsage,
+			})
+		}
+	}
+	return samples, nil
+}
+
+// RunUsageSampler records the usage of every container now and then every
+// interval until ctx is done, deleting samples older than retain
+func (c *Client) RunUsageSampler(ctx context.Context, store UsageStore, interval, retain time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		samples, err := c.SampleUsage(ctx)
+		if err == nil {
+			err = store.RecordUsage(ctx, samples)
+		}
+		if err != nil {
+			log.Printf("[warn] usage sampler: %v", err)
+		}
+		if _, err := store.DeleteUsageBefore(ctx, time.Now().Add(-retain)); err != nil {
+			log.Printf("[warn] usage sampler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// workloadOwners maps "ReplicaSet/namespace/name" and "Job/namespace/name" to
+// the controller owning them, so a pod can be attributed to its Deployment or CronJob
+func (c *Client) workloadOwners(ctx context.Context, namespace string) (map[string]metav1.OwnerReference, error) {
+	owners := make(map[string]metav1.OwnerReference)
+	replicaSets, err := c.Clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, rs := range replicaSets.Items {
+		if ref := controllerRef(rs.OwnerReferences); ref != nil {
+			owners["ReplicaSet/"+rs.Namespace+"/"+rs.Name] = *ref
+		}
+	}
+	jobs, err := c.Clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs.Items {
+		if ref := controllerRef(job.OwnerReferences); ref != nil {
+			owners["Job/"+job.Namespace+"/"+job.Name] = *ref
+		}
+	}
+	return owners, nil
+}
+
+// podWorkload returns the top-level controller of a pod, or the pod itself when it has none
+func podWorkload(pod corev1.Pod, owners map[string]metav1.OwnerReference) (string, string) {
+	ref := controllerRef(pod.OwnerReferences)
+	if ref == nil {
+		return "Pod", pod.Name
+	}
+	if owner, ok := owners[ref.Kind+"/"+pod.Namespace+"/"+ref.Name]; ok {
+		return owner.Kind, owner.Name
+	}
+	return ref.Kind, ref.Name
+}
+
+// Recommend suggests requests and limits for every container of the running
+// workloads from the usage recorded over window. CPU requests follow the 90th
+// percentile and memory requests the 95th, plus headroom; limits follow the peak.
+func (c *Client) Recommend(ctx context.Context, store UsageStore, namespace string, window time.Duration) ([]api.Recommendation, error) {
+	samples, err := store.ListUsage(ctx, namespace, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	podList, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	owners, err := c.workloadOwners(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every replica adds a value per sampling round, but only rounds count as samples
+	type usage struct {
+		cpu, memory []int64
+		rounds      map[int64]bool
+	}
+	history := make(map[string]*usage)
+	for _, sample := range samples {
+		key := fmt.Sprintf("%s/%s/%s/%s", sample.Namespace, sample.WorkloadKind, sample.Workload, sample.Container)
+		if history[key] == nil {
+			history[key] = &usage{rounds: make(map[int64]bool)}
+		}
+		history[key].cpu = append(history[key].cpu, sample.CPUUsage)
+		history[key].memory = append(history[key].memory, sample.MemoryUsage)
+		history[key].rounds[sample.Time.UnixNano()] = true
+	}
+
+	// One row per container of each workload that still runs, with the spec of its first pod
+	pods := podList.Items
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	rows := make(map[string]*api.Recommendation)
+	var order []string
+	for _, pod := range pods {
+		if !activePod(pod) {
+			continue
+		}
+		kind, name := podWorkload(pod, owners)
+		for _, container := range pod.Spec.Containers {
+			key := fmt.Sprintf("%s/%s/%s/%s", pod.Namespace, kind, name, container.Name)
+			if row, ok := rows[key]; ok {
+				row.Replicas++
+				continue
+			}
+			rows[key] = &api.Recommendation{
+				Namespace:    pod.Namespace,
+				WorkloadKind: kind,
+				Workload:     name,
+				Container:    container.Name,
+				Replicas:     1,
+				CPU: api.ResourceRecommendation{
+					Request: container.Resources.Requests.Cpu().MilliValue(),
+					Limit:   container.Resources.Limits.Cpu().MilliValue(),
+				},
+				Memory: api.ResourceRecommendation{
+					Request: container.Resources.Requests.Memory().Value(),
+					Limit:   container.Resources.Limits.Memory().Value(),
+				},
+			}
+			order = append(order, key)
+		}
+	}
+
+	var recommendations []api.Recommendation
+	for _, key := range order {
+		row := rows[key]
+		if h := history[key]; h != nil {
+			row.Samples = len(h.rounds)
+			recommendResource(&row.CPU, h.cpu, 5, 10, minCPUDifference, false)
+			recommendResource(&row.Memory, h.memory, 1024*1024, 16*1024*1024, minMemoryDifference, true)
+		}
+		row.Status = recommendationStatus(row)
+		if row.Status != InsufficientData {
+			row.CPUSavings = savings(row.CPU, row.Replicas)
+			row.MemorySavings = savings(row.Memory, row.Replicas)
+		}
+		recommendations = append(recommendations, *row)
+	}
+	return recommendations, nil
+}
+
+// recommendResource fills the percentiles of values and the suggestion derived
+// from them. CPU is requested at the 90th percentile, memory at the 95th,
+// since running short of memory kills the container rather than slowing it down.
+// Suggestions are rounded up to unit and never below minimum.
+func recommendResource(r *api.ResourceRecommendation, values []int64, unit, minimum, minDifference int64, memory bool) {
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	r.P50 = percentile(sorted, 50)
+	r.P90 = percentile(sorted, 90)
+	r.P95 = percentile(sorted, 95)
+	r.P99 = percentile(sorted, 99)
+	r.Max = sorted[len(sorted)-1]
+
+	base, limitFactor := r.P90, 1.5
+	if memory {
+		base, limitFactor = r.P95, 1.3
+	}
+	r.SuggestedRequest = roundUp(float64(base)*requestHeadroom, unit, minimum)
+	r.SuggestedLimit = roundUp(float64(r.Max)*limitFactor, unit, r.SuggestedRequest)
+
+	switch {
+	case r.Request == 0 || r.P95 > r.Request:
+		r.Status = UnderProvisioned
+	case memory && r.Limit > 0 && r.Max*10 > r.Limit*9:
+		// Peaks within 10% of the memory limit are one spike away from an OOM kill
+		r.Status = UnderProvisioned
+	case float64(r.Request) > float64(r.SuggestedRequest)*overProvisionedRatio && r.Request-r.SuggestedRequest >= minDifference:
+		r.Status = OverProvisioned
+	default:
+		r.Status = RightSized
+	}
+}
+
+// recommendationStatus is the worst status of the resources of a container
+func recommendationStatus(row *api.Recommendation) string {
+	switch {
+	case row.Samples < minRecommendationSamples:
+		return InsufficientData
+	case row.CPU.Status == UnderProvisioned || row.Memory.Status == UnderProvisioned:
+		return UnderProvisioned
+	case row.CPU.Status == OverProvisioned || row.Memory.Status == OverProvisioned:
+		return OverProvisioned
+	}
+	return RightSized
+}
+
+// savings is what applying the suggested request frees across all replicas
+func savings(r api.ResourceRecommendation, replicas int) int64 {
+	if r.Request == 0 {
+		return 0
+	}
+	return (r.Request - r.SuggestedRequest) * int64(replicas)
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// roundUp rounds v up to a multiple of unit, at least minimum
+func roundUp(v float64, unit, minimum int64) int64 {
+	rounded := int64(math.Ceil(v/float64(unit))) * unit
+	if rounded < minimum {
+		return minimum
+	}
+	return rounded
+}
@@ -0,0 +1,195 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"kubelens/internal/db"
+	"kubelens/pkg/api"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const mi = 1024 * 1024
+
+func TestRecommendResource(t *testing.T) {
+	flat := func(v int64) []int64 {
+		values := make([]int64, 20)
+		for i := range values {
+			values[i] = v
+		}
+		return values
+	}
+	tests := []struct {
+		name             string
+		values           []int64
+		request, limit   int64
+		memory           bool
+		wantStatus       string
+		wantSuggestedReq int64
+	}{
+		{"cpu over", flat(100), 1000, 0, false, OverProvisioned, 115},
+		{"cpu right", flat(100), 150, 0, false, RightSized, 115},
+		{"cpu small difference", flat(10), 50, 0, false, RightSized, 15},
+		{"cpu under", flat(300), 200, 0, false, UnderProvisioned, 345},
+		{"no request", flat(100), 0, 0, false, UnderProvisioned, 115},
+		{"memory near limit", flat(400 * mi), 512 * mi, 420 * mi, true, UnderProvisioned, 460 * mi},
+		{"memory over", flat(100 * mi), 1024 * mi, 0, true, OverProvisioned, 115 * mi},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := api.ResourceRecommendation{Request: tt.request, Limit: tt.limit}
+			if tt.memory {
+				recommendResource(&r, tt.values, mi, 16*mi, minMemoryDifference, true)
+			} else {
+				recommendResource(&r, tt.values, 5, 10, minCPUDifference, false)
+			}
+			if r.Status != tt.wantStatus || r.SuggestedRequest != tt.wantSuggestedReq {
+				t.Errorf("got %s with request %d, want %s with %d", r.Status, r.SuggestedRequest, tt.wantStatus, tt.wantSuggestedReq)
+			}
+			if r.SuggestedLimit < r.SuggestedRequest {
+				t.Errorf("limit %d below request %d", r.SuggestedLimit, r.SuggestedRequest)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for p, want := range map[int]int64{50: 5, 90: 9, 95: 10, 99: 10, 1: 1} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("p%d = %d, want %d", p, got, want)
+		}
+	}
+}
+
+func TestRecommendations(t *testing.T) {
+	controller := true
+	deployment := testDeployment("shop", "web", 2, 2)
+	rs := &appsv1.ReplicaSet{ObjectMeta: objectMeta("shop", "web-abc", nil)}
+	rs.OwnerReferences = []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}}
+	objects := []runtime.Object{deployment, rs, testPod("shop", "debug", corev1.PodRunning, nil)}
+	workerRS := &appsv1.ReplicaSet{ObjectMeta: objectMeta("shop", "worker-abc", nil)}
+	workerRS.OwnerReferences = []metav1.OwnerReference{{Kind: "Deployment", Name: "worker", Controller: &controller}}
+	objects = append(objects, workerRS)
+	for name, owner := range map[string]string{"web-abc-1": "web-abc", "web-abc-2": "web-abc", "worker-1": "worker-abc", "worker-2": "worker-abc"} {
+		pod := testPod("shop", name, corev1.PodRunning, nil)
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: owner, Controller: &controller}}
+		pod.Spec.Containers = []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+		}}}
+		objects = append(objects, pod)
+	}
+	c := newFakeClient(t, objects...)
+
+	store := db.NewMemoryUsageStore(db.DefaultMemoryUsageSamples)
+	now := time.Now()
+	var samples []api.UsageSample
+	for i := 0; i < 20; i++ {
+		for _, pod := range []string{"web-abc-1", "web-abc-2"} {
+			samples = append(samples, api.UsageSample{
+				Time: now.Add(-time.Duration(i) * time.Minute), Namespace: "shop", Pod: pod, Container: "app",
+				WorkloadKind: "Deployment", Workload: "web", CPUUsage: 100, MemoryUsage: 200 * mi,
+			})
+		}
+	}
+	// Two replicas sampled five times are not enough data
+	for i := 0; i < 5; i++ {
+		for _, pod := range []string{"worker-1", "worker-2"} {
+			samples = append(samples, api.UsageSample{
+				Time: now.Add(-time.Duration(i) * time.Minute), Namespace: "shop", Pod: pod, Container: "app",
+				WorkloadKind: "Deployment", Workload: "worker", CPUUsage: 100, MemoryUsage: 200 * mi,
+			})
+		}
+	}
+	// Outside the window
+	samples = append(samples, api.UsageSample{Time: now.Add(-48 * time.Hour), Namespace: "shop", Container: "app",
+		WorkloadKind: "Deployment", Workload: "web", CPUUsage: 5000, MemoryUsage: 200 * mi})
+	if err := store.RecordUsage(context.Background(), samples); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(c, WithUsageStore(store))
+	w := serve(t, h, http.MethodGet, "/recommendations?namespace=shop&window=24h")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var list api.ListResponse[api.Recommendation]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 4 {
+		t.Fatalf("recommendations %+v", list.Items)
+	}
+	for _, r := range list.Items {
+		switch r.Workload {
+		case "web":
+			if r.WorkloadKind != "Deployment" || r.Replicas != 2 || r.Samples != 20 || r.Status != OverProvisioned ||
+				r.CPU.Max != 100 || r.CPUSavings != (1000-115)*2 || r.Memory.Status != RightSized {
+				t.Errorf("unexpected web recommendation %+v", r)
+			}
+		case "worker":
+			if r.Replicas != 2 || r.Samples != 5 || r.Status != InsufficientData {
+				t.Errorf("unexpected worker recommendation %+v", r)
+			}
+		case "debug":
+			if r.WorkloadKind != "Pod" || r.Status != InsufficientData || r.CPUSavings != 0 {
+				t.Errorf("unexpected bare pod recommendation %+v", r)
+			}
+		default:
+			t.Errorf("unexpected workload %s", r.Workload)
+		}
+	}
+
+	if w := serve(t, h, http.MethodGet, "/recommendations?window=soon"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid window: status %d", w.Code)
+	}
+	if w := serve(t, NewHandler(c), http.MethodGet, "/recommendations"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a store: status %d", w.Code)
+	}
+}
+
+func TestSampleUsage(t *testing.T) {
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := c.SampleUsage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workloads := map[string]bool{}
+	for _, s := range samples {
+		workloads[s.WorkloadKind+"/"+s.Workload] = true
+	}
+	if !workloads["Deployment/web"] || !workloads["StatefulSet/db"] {
+		t.Errorf("samples not attributed to workloads: %v", workloads)
+	}
+}
+
+func TestMemoryUsageStoreLimit(t *testing.T) {
+	store := db.NewMemoryUsageStore(3)
+	now := time.Now()
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		sample := api.UsageSample{Time: now.Add(time.Duration(i) * time.Minute), Namespace: "shop", Pod: fmt.Sprintf("web-%d", i)}
+		if err := store.RecordUsage(ctx, []api.UsageSample{sample}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	samples, err := store.ListUsage(ctx, "", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(samples, func(s api.UsageSample) string { return s.Pod }); !equalStrings(got, []string{"web-2", "web-3", "web-4"}) {
+		t.Errorf("kept samples %v", got)
+	}
+}
@@ -0,0 +1,248 @@
+package k8s
+
+import (
+	"net/http"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route describes an API endpoint. The same table drives the router and the OpenAPI document.
+type Route struct {
+	Method      string
+	Path        string // relative to the API prefix, in gin syntax
+	OperationID string
+	Summary     string
+	Handler     gin.HandlerFunc
+	Query       []QueryParam
+	Request     interface{} // zero value of the request body type, nil if there is none
+	Response    interface{} // zero value of the 200 response type
+	ContentType string      // content type of the 200 response, application/json if empty
+}
+
+// QueryParam documents a query string parameter
+type QueryParam struct {
+	Name        string
+	Type        string // string, integer or boolean
+	Description string
+}
+
+var namespaceParam = QueryParam{Name: "namespace", Type: "string", Description: "Namespace, empty for all namespaces"}
+
+var listParams = []QueryParam{
+	{Name: "limit", Type: "integer", Description: "Maximum number of items to return"},
+	{Name: "continue", Type: "string", Description: "Continue token returned by the previous page"},
+	{Name: "sort", Type: "string", Description: "Comma separated columns to sort by, prefix with - for descending"},
+	{Name: "labelSelector", Type: "string", Description: "Kubernetes label selector"},
+	{Name: "fieldSelector", Type: "string", Description: "Kubernetes field selector"},
+	{Name: "status", Type: "string", Description: "Comma separated status values to keep"},
+}
+
+func namespacedListParams() []QueryParam {
+	return append([]QueryParam{namespaceParam}, listParams...)
+}
+
+// Routes returns every API route, relative to the API prefix
+func (h *Handler) Routes() []Route {
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/health", OperationID: "health", Summary: "Health check",
+			Handler: HealthHandlerFunc, Response: api.HealthResponse{}},
+		{Method: http.MethodGet, Path: "/namespaces", OperationID: "listNamespaces", Summary: "List namespaces",
+			Handler: h.GetNamespacesHandlerFunc, Query: listParams, Response: api.ListResponse[api.Namespace]{}},
+		{Method: http.MethodGet, Path: "/workloads", OperationID: "listWorkloads", Summary: "List Deployments, StatefulSets and DaemonSets",
+			Handler: h.GetWorkloadsHandlerFunc, Query: namespacedListParams(), Response: api.ListResponse[api.Workload]{}},
+		{Method: http.MethodGet, Path: "/pods", OperationID: "listPods", Summary: "List pods",
+			Handler: h.GetPodsHandlerFunc, Query: namespacedListParams(), Response: api.ListResponse[api.Pod]{}},
+		{Method: http.MethodGet, Path: "/nodes", OperationID: "listNodes", Summary: "List nodes",
+			Handler: h.GetNodesHandlerFunc, Query: listParams, Response: api.ListResponse[api.Node]{}},
+		{Method: http.MethodGet, Path: "/events", OperationID: "listEvents", Summary: "List events, newest first by default",
+			Handler: h.GetEventsHandlerFunc, Query: namespacedListParams(), Response: api.ListResponse[api.Event]{}},
+		{Method: http.MethodGet, Path: "/metrics/nodes", OperationID: "listNodeMetrics", Summary: "List node usage from the metrics server",
+			Handler: h.GetNodeMetricsHandlerFunc, Query: listParams, Response: api.ListResponse[api.NodeMetric]{}},
+		{Method: http.MethodGet, Path: "/metrics/pods", OperationID: "listPodMetrics", Summary: "List pod usage from the metrics server",
+			Handler: h.GetPodMetricsHandlerFunc, Query: namespacedListParams(), Response: api.ListResponse[api.PodMetric]{}},
+		{Method: http.MethodGet, Path: "/services", OperationID: "listServices", Summary: "List services",
+			Handler: h.GetServicesHandlerFunc, Query: namespacedListParams(), Response: api.ListResponse[api.Service]{}},
+		{Method: http.MethodGet, Path: "/services/:namespace/:name", OperationID: "getService", Summary: "Describe a service with resolved target ports, endpoint health and selector warnings",
+			Handler: h.GetServiceDetailHandlerFunc, Response: api.ServiceDetail{}},
+		{Method: http.MethodGet, Path: "/configmaps", OperationID: "listConfigMaps", Summary: "List ConfigMaps",
+			Handler: h.GetConfigMapsHandlerFunc, Query: namespacedListParams(), Response: api.ListResponse[api.ConfigMap]{}},
+		{Method: http.MethodGet, Path: "/pvs", OperationID: "listPersistentVolumes", Summary: "List persistent volumes",
+			Handler: h.GetPVsHandlerFunc, Query: listParams, Response: api.ListResponse[api.PersistentVolume]{}},
+		{Method: http.MethodGet, Path: "/pvcs", OperationID: "listPersistentVolumeClaims", Summary: "List persistent volume claims",
+			Handler: h.GetPVCsHandlerFunc, Query: namespacedListParams(), Response: api.ListResponse[api.PersistentVolumeClaim]{}},
+		{Method: http.MethodGet, Path: "/graph", OperationID: "getResourceGraph", Summary: "Resource relationship graph of a namespace",
+			Handler: h.GetResourceGraphHandlerFunc, Query: []QueryParam{namespaceParam}, Response: api.ResourceGraph{}},
+		{Method: http.MethodGet, Path: "/search", OperationID: "search", Summary: "Search names, labels and annotations across all kinds",
+			Handler: h.SearchHandlerFunc, Query: []QueryParam{
+				{Name: "q", Type: "string", Description: "Free text with optional kind:, ns: and label selector terms"},
+				{Name: "labelSelector", Type: "string", Description: "Kubernetes label selector"},
+				{Name: "limit", Type: "integer", Description: "Maximum number of results, default 50"},
+			}, Response: api.ListResponse[api.SearchResult]{}},
+		{Method: http.MethodGet, Path: "/summary", OperationID: "getSummary", Summary: "Cluster summary counts",
+			Handler: h.GetSummaryHandlerFunc, Response: api.Summary{}},
+		{Method: http.MethodGet, Path: "/notifications", OperationID: "listNotifications", Summary: "List notifications",
+			Handler: h.GetNotificationsHandlerFunc, Response: api.ListResponse[api.Notification]{}},
+		{Method: http.MethodGet, Path: "/namespaces/:name", OperationID: "getNamespace", Summary: "Describe a namespace with pod counts, resource totals, quotas and limit ranges",
+			Handler: h.GetNamespaceDetailHandlerFunc, Response: api.NamespaceDetail{}},
+		{Method: http.MethodGet, Path: "/nodes/:name", OperationID: "getNode", Summary: "Describe a node with capacity, allocation, usage and pods",
+			Handler: h.GetNodeDetailHandlerFunc, Response: api.NodeDetail{}},
+		{Method: http.MethodGet, Path: "/pods/:namespace/:podName", OperationID: "getPod", Summary: "Describe a pod",
+			Handler: h.GetPodDetailHandlerFunc, Response: api.PodDetail{}},
+		{Method: http.MethodGet, Path: "/pods/:namespace/:podName/logs", OperationID: "getPodLogs", Summary: "Tail the logs of a pod",
+			Handler: h.GetPodLogsHandlerFunc, Query: []QueryParam{
+				{Name: "tail", Type: "integer", Description: "Number of lines from the end, default 100"},
+				{Name: "follow", Type: "boolean", Description: "Stream the logs as text/plain instead of returning JSON"},
+				{Name: "container", Type: "string", Description: "Container whose logs to return or stream, defaults to the only container"},
+			}, Response: api.PodLogs{}},
+		{Method: http.MethodGet, Path: "/pods/:namespace/:podName/files", OperationID: "downloadPodFiles", Summary: "Download a file or directory of a container as a tar or zip archive",
+			Handler: h.DownloadPodFilesHandlerFunc, Query: []QueryParam{
+				{Name: "path", Type: "string", Description: "Absolute path of the file or directory in the container"},
+				{Name: "container", Type: "string", Description: "Container to copy from, defaults to the pod's default container"},
+				{Name: "format", Type: "string", Description: "tar (default) or zip"},
+				{Name: "token", Type: "string", Description: "Proxy token, also accepted as a bearer token"},
+			}, Response: []byte{}, ContentType: "application/octet-stream"},
+		{Method: http.MethodPost, Path: "/pods/:namespace/:podName/files", OperationID: "uploadPodFiles", Summary: "Upload the files of a multipart form field named file into a container directory",
+			Handler: h.UploadPodFilesHandlerFunc, Query: []QueryParam{
+				{Name: "path", Type: "string", Description: "Absolute path of an existing directory in the container"},
+				{Name: "container", Type: "string", Description: "Container to copy to, defaults to the pod's default container"},
+				{Name: "token", Type: "string", Description: "Proxy token, also accepted as a bearer token"},
+			}, Response: api.CopyResult{}},
+		{Method: http.MethodGet, Path: "/pods/:namespace/:podName/diagnosis", OperationID: "diagnosePod", Summary: "Classify the problems of a pod with evidence and next steps",
+			Handler: h.GetPodDiagnosisHandlerFunc, Response: api.PodDiagnosis{}},
+		{Method: http.MethodGet, Path: "/pods/:namespace/:podName/scheduling", OperationID: "explainScheduling", Summary: "Explain per node why a pending pod cannot be scheduled",
+			Handler: h.GetPodSchedulingHandlerFunc, Response: api.SchedulingExplanation{}},
+		{Method: http.MethodGet, Path: "/problems", OperationID: "listProblems", Summary: "List the problems of all unhealthy pods",
+			Handler: h.GetProblemsHandlerFunc, Query: []QueryParam{
+				namespaceParam,
+				{Name: "limit", Type: "integer", Description: "Maximum number of items to return"},
+				{Name: "continue", Type: "string", Description: "Continue token returned by the previous page"},
+				{Name: "sort", Type: "string", Description: "Comma separated columns to sort by, default severity,namespace,pod"},
+			}, Response: api.ListResponse[api.Problem]{}},
+		{Method: http.MethodGet, Path: "/recommendations", OperationID: "listRecommendations", Summary: "Suggest container requests and limits from usage percentiles",
+			Handler: h.GetRecommendationsHandlerFunc, Query: []QueryParam{
+				namespaceParam,
+				{Name: "window", Type: "string", Description: "How much usage history to use, e.g. 72h, default 168h"},
+				{Name: "status", Type: "string", Description: "OverProvisioned, UnderProvisioned, RightSized or InsufficientData"},
+				{Name: "limit", Type: "integer", Description: "Maximum number of items to return"},
+				{Name: "continue", Type: "string", Description: "Continue token returned by the previous page"},
+				{Name: "sort", Type: "string", Description: "Comma separated columns to sort by, e.g. -cpuSavings, default namespace,workload,container"},
+			}, Response: api.ListResponse[api.Recommendation]{}},
+		{Method: http.MethodGet, Path: "/cost-model", OperationID: "getCostModel", Summary: "Prices used to allocate costs",
+			Handler: h.GetCostModelHandlerFunc, Response: api.CostModel{}},
+		{Method: http.MethodGet, Path: "/costs", OperationID: "listCosts", Summary: "Current hourly cost by namespace, workload or label",
+			Handler: h.GetCostsHandlerFunc, Query: []QueryParam{
+				{Name: "groupBy", Type: "string", Description: "namespace (default), workload or label"},
+				{Name: "label", Type: "string", Description: "Label to group by when groupBy=label, e.g. team"},
+				{Name: "limit", Type: "integer", Description: "Maximum number of items to return"},
+				{Name: "continue", Type: "string", Description: "Continue token returned by the previous page"},
+				{Name: "sort", Type: "string", Description: "Comma separated columns to sort by, default -totalCost"},
+			}, Response: api.ListResponse[api.CostAllocation]{}},
+		{Method: http.MethodGet, Path: "/costs/daily", OperationID: "listDailyCosts", Summary: "Recorded daily costs by namespace, workload or label",
+			Handler: h.GetDailyCostsHandlerFunc, Query: []QueryParam{
+				{Name: "groupBy", Type: "string", Description: "namespace (default), workload or label"},
+				{Name: "label", Type: "string", Description: "Label to group by when groupBy=label, e.g. team"},
+				{Name: "from", Type: "string", Description: "First day, YYYY-MM-DD, default 30 days ago"},
+				{Name: "to", Type: "string", Description: "Last day, YYYY-MM-DD, default today"},
+				{Name: "limit", Type: "integer", Description: "Maximum number of items to return"},
+				{Name: "continue", Type: "string", Description: "Continue token returned by the previous page"},
+				{Name: "sort", Type: "string", Description: "Comma separated columns to sort by, default date,-totalCost"},
+			}, Response: api.ListResponse[api.CostAllocation]{}},
+		{Method: http.MethodGet, Path: "/costs/export", OperationID: "exportCosts", Summary: "Download the daily costs as CSV",
+			Handler: h.ExportCostsHandlerFunc, Query: []QueryParam{
+				{Name: "groupBy", Type: "string", Description: "namespace (default), workload or label"},
+				{Name: "label", Type: "string", Description: "Label to group by when groupBy=label, e.g. team"},
+				{Name: "from", Type: "string", Description: "First day, YYYY-MM-DD, default 30 days ago"},
+				{Name: "to", Type: "string", Description: "Last day, YYYY-MM-DD, default today"},
+			}, Response: []byte{}, ContentType: "text/csv"},
+		{Method: http.MethodGet, Path: "/scan", OperationID: "scanWorkloads", Summary: "Audit workloads against best-practice and security rules",
+			Handler: h.ScanWorkloadsHandlerFunc, Query: []QueryParam{namespaceParam}, Response: api.ScanReport{}},
+		{Method: http.MethodGet, Path: "/deprecations", OperationID: "findDeprecatedAPIs", Summary: "Find APIs and objects removed in a target Kubernetes version",
+			Handler: h.GetDeprecatedAPIsHandlerFunc, Query: []QueryParam{
+				{Name: "target", Type: "string", Description: "Kubernetes version to upgrade to, e.g. 1.25, default the release after the server's"},
+			}, Response: api.DeprecationReport{}},
+		{Method: http.MethodGet, Path: "/orphans", OperationID: "listOrphans", Summary: "List unused ConfigMaps, Secrets, PVCs, PVs, Services and old ReplicaSets",
+			Handler: h.ListOrphansHandlerFunc, Query: append([]QueryParam{
+				namespaceParam,
+				{Name: "kind", Type: "string", Description: "Only orphans of this kind, e.g. configmaps or pvc"},
+			}, listParams...), Response: api.ListResponse[api.OrphanedResource]{}},
+		{Method: http.MethodPost, Path: "/orphans/delete", OperationID: "deleteOrphans", Summary: "Delete orphaned resources, a dry run unless dryRun=false",
+			Handler: h.DeleteOrphansHandlerFunc, Query: []QueryParam{
+				{Name: "dryRun", Type: "boolean", Description: "Only check what would be deleted, default true"},
+			}, Request: api.OrphanDeleteRequest{}, Response: api.OrphanDeleteResult{}},
+		{Method: http.MethodGet, Path: "/audit", OperationID: "listAudit", Summary: "Audit log of changes made through KubeLens",
+			Handler: h.ListAuditHandlerFunc, Query: append([]QueryParam{
+				{Name: "since", Type: "string", Description: "How far back to look, e.g. 24h, default 168h"},
+			}, listParams...), Response: api.ListResponse[api.AuditEntry]{}},
+		{Method: http.MethodGet, Path: "/watch", OperationID: "watch", Summary: "Stream changes of a kind as server-sent events",
+			Handler: h.WatchHandlerFunc, Query: []QueryParam{
+				{Name: "kind", Type: "string", Description: "pods, deployments, statefulsets, daemonsets, nodes, services or events"},
+				namespaceParam,
+			}, Response: api.WatchEvent{}, ContentType: "text/event-stream"},
+		{Method: http.MethodGet, Path: "/snapshots", OperationID: "listSnapshots", Summary: "List stored cluster snapshots",
+			Handler: h.ListSnapshotsHandlerFunc, Query: listParams, Response: api.ListResponse[api.SnapshotInfo]{}},
+		{Method: http.MethodPost, Path: "/snapshots", OperationID: "createSnapshot", Summary: "Take a snapshot of the cluster now",
+			Handler: h.CreateSnapshotHandlerFunc, Response: api.SnapshotInfo{}},
+		{Method: http.MethodGet, Path: "/snapshots/:id/export", OperationID: "exportSnapshot", Summary: "Download a snapshot as a tar.gz of YAML manifests",
+			Handler: h.ExportSnapshotHandlerFunc, Response: []byte{}, ContentType: "application/gzip"},
+		{Method: http.MethodGet, Path: "/snapshots/:id/diff", OperationID: "diffSnapshot", Summary: "Compare a snapshot with a later snapshot or the live cluster",
+			Handler: h.DiffSnapshotHandlerFunc, Query: []QueryParam{
+				{Name: "to", Type: "integer", Description: "Snapshot to compare with, the live cluster if empty"},
+				{Name: "kind", Type: "string", Description: "Comma separated kinds to compare, e.g. deployment,configmap"},
+				{Name: "namespace", Type: "string", Description: "Comma separated namespaces to compare"},
+			}, Response: api.SnapshotDiff{}},
+		{Method: http.MethodGet, Path: "/timeline", OperationID: "getTimeline", Summary: "Recorded spec changes and archived events, newest first",
+			Handler: h.GetTimelineHandlerFunc, Query: []QueryParam{
+				namespaceParam,
+				{Name: "kind", Type: "string", Description: "Kind of the object, e.g. deployment"},
+				{Name: "name", Type: "string", Description: "Name of the object"},
+				{Name: "since", Type: "string", Description: "How far back to look, e.g. 6h, default 24h"},
+				{Name: "status", Type: "string", Description: "change or event to keep only one type of entry"},
+				{Name: "limit", Type: "integer", Description: "Maximum number of items to return"},
+				{Name: "continue", Type: "string", Description: "Continue token returned by the previous page"},
+			}, Response: api.ListResponse[api.TimelineEntry]{}},
+		{Method: http.MethodGet, Path: "/namespace-templates", OperationID: "listNamespaceTemplates", Summary: "List the quota and limit range templates for new namespaces",
+			Handler: h.ListNamespaceTemplatesHandlerFunc, Response: api.ListResponse[api.NamespaceTemplate]{}},
+		{Method: http.MethodPost, Path: "/namespaces", OperationID: "createNamespace", Summary: "Create a namespace with labels, a template quota and limit range, and role bindings",
+			Handler: h.CreateNamespaceHandlerFunc, Request: api.NamespaceCreateRequest{}, Response: api.NamespaceCreateResult{}},
+		{Method: http.MethodGet, Path: "/namespaces/:name/contents", OperationID: "getNamespaceContents", Summary: "List everything deleting a namespace would remove",
+			Handler: h.GetNamespaceContentsHandlerFunc, Response: api.NamespaceContents{}},
+		{Method: http.MethodDelete, Path: "/namespaces/:name", OperationID: "deleteNamespace", Summary: "Delete an unprotected namespace, confirmed by repeating its name",
+			Handler: h.DeleteNamespaceHandlerFunc, Query: []QueryParam{
+				{Name: "confirm", Type: "string", Description: "The namespace name again, required to delete"},
+			}, Response: api.MessageResponse{}},
+		{Method: http.MethodPost, Path: "/workloads/:namespace/:name/:kind/restart", OperationID: "restartWorkload", Summary: "Rolling restart of a Deployment, StatefulSet or DaemonSet",
+			Handler: h.RestartWorkloadHandlerFunc, Response: api.MessageResponse{}},
+	}
+	return append(routes, h.proxyRoutes()...)
+}
+
+// proxyMethods are the methods the proxy forwards
+var proxyMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// proxyRoutes forwards every method to pods and services
+func (h *Handler) proxyRoutes() []Route {
+	tokenParam := QueryParam{Name: "token", Type: "string", Description: "Proxy token, also accepted as a bearer token; sets a cookie for the rest of the session"}
+	var routes []Route
+	for _, method := range proxyMethods {
+		suffix := strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+		routes = append(routes,
+			Route{Method: method, Path: "/proxy/pods/:namespace/:name/:port/*path", OperationID: "proxyPod" + suffix,
+				Summary: "Forward a request to a pod port through the API server", Handler: h.ProxyPodHandlerFunc,
+				Query: []QueryParam{tokenParam}, Response: []byte{}, ContentType: "application/octet-stream"},
+			Route{Method: method, Path: "/proxy/services/:namespace/:name/:port/*path", OperationID: "proxyService" + suffix,
+				Summary: "Forward a request to a service port through the API server", Handler: h.ProxyServiceHandlerFunc,
+				Query: []QueryParam{tokenParam}, Response: []byte{}, ContentType: "application/octet-stream"},
+		)
+	}
+	return routes
+}
+
+// RegisterRoutes adds the routes to a router group
+func RegisterRoutes(r gin.IRoutes, routes []Route) {
+	for _, route := range routes {
+		r.Handle(route.Method, route.Path, route.Handler)
+	}
+}
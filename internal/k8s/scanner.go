@@ -0,0 +1,361 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ScanSkipAnnotation on a workload or its pod template lists the rule IDs not
+// to apply to it, comma separated, or * for all of them
+const ScanSkipAnnotation = "kubelens.io/skip-rules"
+
+// ScanRuleOff disables a rule in the scan configuration
+const ScanRuleOff = "off"
+
+// scanPenalty is what a finding of each severity takes off a workload's score
+var scanPenalty = map[string]int{SeverityCritical: 20, SeverityWarning: 5, SeverityInfo: 1}
+
+// scanTarget is a workload as the rules see it
+type scanTarget struct {
+	namespace   string
+	kind        string
+	name        string
+	annotations map[string]string
+	replicas    int32 // -1 for DaemonSets, which run one pod per node
+	template    corev1.PodTemplateSpec
+}
+
+// scanRule is one best-practice check. Checks return the message of each
+// violation and the container it concerns, if any.
+type scanRule struct {
+	id          string
+	severity    string
+	description string
+	check       func(t scanTarget, pdbs []policyv1.PodDisruptionBudget) []api.ScanFinding
+}
+
+var scanRules = []scanRule{
+	{"privileged", SeverityCritical, "Containers must not run privileged", checkPrivileged},
+	{"host-path", SeverityCritical, "Pods should not mount hostPath volumes", checkHostPath},
+	{"host-network", SeverityCritical, "Pods should not share the host's network, PID or IPC namespace", checkHostNamespaces},
+	{"run-as-root", SeverityWarning, "Containers should run as a non-root user", checkRunAsRoot},
+	{"resource-limits", SeverityWarning, "Containers should set CPU and memory limits", checkResourceLimits},
+	{"liveness-probe", SeverityWarning, "Containers should have a liveness probe", checkLivenessProbe},
+	{"readiness-probe", SeverityWarning, "Containers should have a readiness probe", checkReadinessProbe},
+	{"latest-tag", SeverityWarning, "Images should be pinned to a tag other than latest", checkLatestTag},
+	{"pod-disruption-budget", SeverityWarning, "Workloads with several replicas should have a PodDisruptionBudget", checkDisruptionBudget},
+	{"single-replica", SeverityInfo, "Deployments should run more than one replica", checkSingleReplica},
+}
+
+// ParseScanRules parses a rule configuration like "latest-tag=critical,single-replica=off"
+// into the severity, or ScanRuleOff, of each rule
+func ParseScanRules(spec string) (map[string]string, error) {
+	config := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule setting %q, want rule=severity or rule=off", entry)
+		}
+		if findScanRule(id) == nil {
+			return nil, fmt.Errorf("unknown scan rule: %s", id)
+		}
+		switch value {
+		case SeverityCritical, SeverityWarning, SeverityInfo, ScanRuleOff:
+			config[id] = value
+		default:
+			return nil, fmt.Errorf("invalid setting for %s: %s, want critical, warning, info or off", id, value)
+		}
+	}
+	return config, nil
+}
+
+func findScanRule(id string) *scanRule {
+	for i := range scanRules {
+		if scanRules[i].id == id {
+			return &scanRules[i]
+		}
+	}
+	return nil
+}
+
+// ScanWorkloads audits the Deployments, StatefulSets and DaemonSets of a
+// namespace, or all namespaces, with the rules adjusted by config
+func (c *Client) ScanWorkloads(ctx context.Context, namespace string, config map[string]string) (*api.ScanReport, error) {
+	targets, err := c.scanTargets(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	pdbList, err := c.Clientset.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &api.ScanReport{Findings: map[string]int{}, Results: []api.WorkloadScan{}}
+	var rules []scanRule
+	for _, rule := range scanRules {
+		setting := config[rule.id]
+		if setting != "" && setting != ScanRuleOff {
+			rule.severity = setting
+		}
+		report.Rules = append(report.Rules, api.ScanRule{
+			ID: rule.id, Severity: rule.severity, Enabled: setting != ScanRuleOff, Description: rule.description,
+		})
+		if setting != ScanRuleOff {
+			rules = append(rules, rule)
+		}
+	}
+
+	total := 0
+	for _, target := range targets {
+		result := scanWorkload(target, rules, pdbList.Items)
+		for _, finding := range result.Findings {
+			report.Findings[finding.Severity]++
+		}
+		total += result.Score
+		report.Results = append(report.Results, result)
+	}
+	report.Workloads = len(report.Results)
+	report.Score = 100
+	if report.Workloads > 0 {
+		report.Score = total / report.Workloads
+	}
+	sort.SliceStable(report.Results, func(i, j int) bool { return report.Results[i].Score < report.Results[j].Score })
+	return report, nil
+}
+
+// scanWorkload applies the rules the workload does not skip and scores it
+func scanWorkload(target scanTarget, rules []scanRule, pdbs []policyv1.PodDisruptionBudget) api.WorkloadScan {
+	skip := map[string]bool{}
+	for _, annotations := range []map[string]string{target.annotations, target.template.Annotations} {
+		for _, id := range strings.Split(annotations[ScanSkipAnnotation], ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				skip[id] = true
+			}
+		}
+	}
+
+	result := api.WorkloadScan{Namespace: target.namespace, Kind: target.kind, Name: target.name, Score: 100, Findings: []api.ScanFinding{}}
+	for _, rule := range rules {
+		if skip[rule.id] || skip["*"] {
+			result.Skipped = append(result.Skipped, rule.id)
+			continue
+		}
+		for _, finding := range rule.check(target, pdbs) {
+			finding.Rule, finding.Severity = rule.id, rule.severity
+			result.Findings = append(result.Findings, finding)
+			result.Score -= scanPenalty[rule.severity]
+		}
+	}
+	if result.Score < 0 {
+		result.Score = 0
+	}
+	return result
+}
+
+func (c *Client) scanTargets(ctx context.Context, namespace string) ([]scanTarget, error) {
+	var targets []scanTarget
+	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		targets = append(targets, scanTarget{d.Namespace, "Deployment", d.Name, d.Annotations, replicaCount(d.Spec.Replicas), d.Spec.Template})
+	}
+	statefulSets, err := c.Clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		targets = append(targets, scanTarget{s.Namespace, "StatefulSet", s.Name, s.Annotations, replicaCount(s.Spec.Replicas), s.Spec.Template})
+	}
+	daemonSets, err := c.Clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range daemonSets.Items {
+		targets = append(targets, scanTarget{d.Namespace, "DaemonSet", d.Name, d.Annotations, -1, d.Spec.Template})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].namespace != targets[j].namespace {
+			return targets[i].namespace < targets[j].namespace
+		}
+		return targets[i].name < targets[j].name
+	})
+	return targets, nil
+}
+
+// replicaCount defaults an unset replica count to 1, as the API server does
+func replicaCount(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// allContainers returns the init and regular containers of a pod template
+func allContainers(spec corev1.PodSpec) []corev1.Container {
+	return append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+}
+
+func checkPrivileged(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var findings []api.ScanFinding
+	for _, container := range allContainers(t.template.Spec) {
+		if sc := container.SecurityContext; sc != nil && sc.Privileged != nil && *sc.Privileged {
+			findings = append(findings, api.ScanFinding{Container: container.Name, Message: "runs privileged with full access to the host"})
+		}
+	}
+	return findings
+}
+
+func checkHostPath(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var findings []api.ScanFinding
+	for _, volume := range t.template.Spec.Volumes {
+		if volume.HostPath != nil {
+			findings = append(findings, api.ScanFinding{Message: fmt.Sprintf("volume %s mounts host path %s", volume.Name, volume.HostPath.Path)})
+		}
+	}
+	return findings
+}
+
+func checkHostNamespaces(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var shared []string
+	spec := t.template.Spec
+	for _, ns := range []struct {
+		name string
+		on   bool
+	}{{"hostNetwork", spec.HostNetwork}, {"hostPID", spec.HostPID}, {"hostIPC", spec.HostIPC}} {
+		if ns.on {
+			shared = append(shared, ns.name)
+		}
+	}
+	if len(shared) == 0 {
+		return nil
+	}
+	return []api.ScanFinding{{Message: "uses " + strings.Join(shared, ", ")}}
+}
+
+// checkRunAsRoot flags containers that do not provably run as a non-root user:
+// container settings override the pod's, and without runAsNonRoot or a non-zero
+// runAsUser the image's user applies, which is root for most images
+func checkRunAsRoot(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var findings []api.ScanFinding
+	pod := t.template.Spec.SecurityContext
+	for _, container := range allContainers(t.template.Spec) {
+		var nonRoot *bool
+		var user *int64
+		if pod != nil {
+			nonRoot, user = pod.RunAsNonRoot, pod.RunAsUser
+		}
+		if sc := container.SecurityContext; sc != nil {
+			if sc.RunAsNonRoot != nil {
+				nonRoot = sc.RunAsNonRoot
+			}
+			if sc.RunAsUser != nil {
+				user = sc.RunAsUser
+			}
+		}
+		switch {
+		case user != nil && *user == 0:
+			findings = append(findings, api.ScanFinding{Container: container.Name, Message: "runs as user 0"})
+		case user == nil && (nonRoot == nil || !*nonRoot):
+			findings = append(findings, api.ScanFinding{Container: container.Name, Message: "may run as root: set runAsNonRoot or runAsUser"})
+		}
+	}
+	return findings
+}
+
+func checkResourceLimits(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var findings []api.ScanFinding
+	for _, container := range t.template.Spec.Containers {
+		var missing []string
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if _, ok := container.Resources.Limits[name]; !ok {
+				missing = append(missing, string(name))
+			}
+		}
+		if len(missing) > 0 {
+			findings = append(findings, api.ScanFinding{Container: container.Name, Message: "no " + strings.Join(missing, " or ") + " limit"})
+		}
+	}
+	return findings
+}
+
+func checkLivenessProbe(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var findings []api.ScanFinding
+	for _, container := range t.template.Spec.Containers {
+		if container.LivenessProbe == nil {
+			findings = append(findings, api.ScanFinding{Container: container.Name, Message: "no liveness probe: a hung process is never restarted"})
+		}
+	}
+	return findings
+}
+
+func checkReadinessProbe(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var findings []api.ScanFinding
+	for _, container := range t.template.Spec.Containers {
+		if container.ReadinessProbe == nil {
+			findings = append(findings, api.ScanFinding{Container: container.Name, Message: "no readiness probe: traffic arrives before the app is ready"})
+		}
+	}
+	return findings
+}
+
+func checkLatestTag(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	var findings []api.ScanFinding
+	for _, container := range allContainers(t.template.Spec) {
+		if tag := imageTag(container.Image); tag == "" || tag == "latest" {
+			findings = append(findings, api.ScanFinding{Container: container.Name, Message: fmt.Sprintf("image %s is not pinned to a version", container.Image)})
+		}
+	}
+	return findings
+}
+
+// imageTag returns the tag of an image reference, "" when it has none; images
+// pinned by digest count as tagged
+func imageTag(image string) string {
+	if _, digest, ok := strings.Cut(image, "@"); ok {
+		return digest
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+func checkDisruptionBudget(t scanTarget, pdbs []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	if t.kind == "DaemonSet" || t.replicas < 2 {
+		return nil
+	}
+	podLabels := labels.Set(t.template.Labels)
+	for _, pdb := range pdbs {
+		if pdb.Namespace != t.namespace || pdb.Spec.Selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err == nil && !selector.Empty() && selector.Matches(podLabels) {
+			return nil
+		}
+	}
+	return []api.ScanFinding{{Message: fmt.Sprintf("%d replicas but no PodDisruptionBudget: a node drain may evict all of them at once", t.replicas)}}
+}
+
+func checkSingleReplica(t scanTarget, _ []policyv1.PodDisruptionBudget) []api.ScanFinding {
+	if t.kind != "Deployment" || t.replicas != 1 {
+		return nil
+	}
+	return []api.ScanFinding{{Message: "a single replica is unavailable during every rollout and node drain"}}
+}
@@ -0,0 +1,179 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hardenedDeployment passes every scan rule
+func hardenedDeployment(namespace, name string, replicas int32) *appsv1.Deployment {
+	d := testDeployment(namespace, name, replicas, replicas)
+	nonRoot := true
+	probe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz"}}}
+	d.Spec = appsv1.DeploymentSpec{
+		Replicas: &replicas,
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": name}},
+			Spec: corev1.PodSpec{
+				SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "registry.example.com/" + name + ":1.4.2",
+					Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("256Mi"),
+					}},
+					LivenessProbe:  probe,
+					ReadinessProbe: probe,
+				}},
+			},
+		},
+	}
+	return d
+}
+
+func testPDB(namespace, name string, selector map[string]string) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: objectMeta(namespace, name, nil),
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: selector}},
+	}
+}
+
+func findingRules(scan api.WorkloadScan) []string {
+	var rules []string
+	for _, f := range scan.Findings {
+		rules = append(rules, f.Rule)
+	}
+	return rules
+}
+
+func TestScanWorkloads(t *testing.T) {
+	privileged := true
+	root := int64(0)
+	insecure := hardenedDeployment("shop", "insecure", 1)
+	spec := &insecure.Spec.Template.Spec
+	spec.HostNetwork = true
+	spec.Volumes = []corev1.Volume{{Name: "docker", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}}}
+	spec.Containers[0].Image = "nginx"
+	spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: &privileged, RunAsUser: &root}
+	spec.Containers[0].Resources = corev1.ResourceRequirements{}
+	spec.Containers[0].LivenessProbe = nil
+
+	c := newFakeClient(t,
+		hardenedDeployment("shop", "web", 3),
+		testPDB("shop", "web", map[string]string{"app": "web"}),
+		hardenedDeployment("shop", "api", 2),
+		insecure,
+	)
+	report, err := c.ScanWorkloads(context.Background(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Workloads != 3 || len(report.Results) != 3 {
+		t.Fatalf("workloads %d results %d", report.Workloads, len(report.Results))
+	}
+
+	worst := report.Results[0]
+	wantRules := []string{"privileged", "host-path", "host-network", "run-as-root", "resource-limits", "liveness-probe", "latest-tag", "single-replica"}
+	if worst.Name != "insecure" || !equalStrings(findingRules(worst), wantRules) {
+		t.Fatalf("worst %s findings %v", worst.Name, findingRules(worst))
+	}
+	// 3 critical, 4 warning and 1 info finding
+	if worst.Score != 100-3*20-4*5-1 {
+		t.Errorf("insecure score %d", worst.Score)
+	}
+	if report.Findings[SeverityCritical] != 3 || report.Findings[SeverityWarning] != 5 || report.Findings[SeverityInfo] != 1 {
+		t.Errorf("findings %v", report.Findings)
+	}
+
+	results := map[string]api.WorkloadScan{}
+	for _, r := range report.Results {
+		results[r.Name] = r
+	}
+	if got := findingRules(results["api"]); !equalStrings(got, []string{"pod-disruption-budget"}) {
+		t.Errorf("api findings %v, want missing PDB", got)
+	}
+	if got := results["web"]; len(got.Findings) != 0 || got.Score != 100 {
+		t.Errorf("web %+v, want clean", got)
+	}
+	if report.Score != (worst.Score+95+100)/3 {
+		t.Errorf("cluster score %d", report.Score)
+	}
+}
+
+func TestScanSkipsAndConfiguresRules(t *testing.T) {
+	d := hardenedDeployment("shop", "web", 1)
+	d.Spec.Template.Spec.Containers[0].Image = "web:latest"
+	d.Annotations = map[string]string{ScanSkipAnnotation: "single-replica"}
+	c := newFakeClient(t, d)
+
+	config, err := ParseScanRules("latest-tag=critical, readiness-probe=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := c.ScanWorkloads(context.Background(), "shop", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := report.Results[0]
+	if len(scan.Findings) != 1 || scan.Findings[0].Rule != "latest-tag" || scan.Findings[0].Severity != SeverityCritical {
+		t.Fatalf("findings %+v", scan.Findings)
+	}
+	if !equalStrings(scan.Skipped, []string{"single-replica"}) {
+		t.Errorf("skipped %v", scan.Skipped)
+	}
+	for _, rule := range report.Rules {
+		if rule.ID == "readiness-probe" && rule.Enabled {
+			t.Error("readiness-probe should be disabled")
+		}
+	}
+
+	d.Annotations[ScanSkipAnnotation] = "*"
+	if got := scanWorkload(scanTarget{kind: "Deployment", annotations: d.Annotations, replicas: 1, template: d.Spec.Template}, scanRules, nil); len(got.Findings) != 0 || got.Score != 100 {
+		t.Errorf("skip all: %+v", got)
+	}
+
+	for _, spec := range []string{"latest-tag", "unknown=warning", "latest-tag=urgent"} {
+		if _, err := ParseScanRules(spec); err == nil {
+			t.Errorf("ParseScanRules(%q) should fail", spec)
+		}
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	for image, want := range map[string]string{
+		"nginx":                          "",
+		"nginx:1.25":                     "1.25",
+		"localhost:5000/app":             "",
+		"localhost:5000/app:latest":      "latest",
+		"ghcr.io/org/app@sha256:abc1234": "sha256:abc1234",
+	} {
+		if got := imageTag(image); got != want {
+			t.Errorf("imageTag(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestScanHandler(t *testing.T) {
+	c := newFakeClient(t, hardenedDeployment("shop", "web", 1))
+	w := serve(t, NewHandler(c), http.MethodGet, "/scan?namespace=shop")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var report api.ScanReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Workloads != 1 || report.Score != 99 {
+		t.Errorf("report %+v", report)
+	}
+}
@@ -0,0 +1,468 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// Scheduling checks, in the order they are reported
+const (
+	CheckUnschedulable = "Unschedulable"
+	CheckResources     = "Resources"
+	CheckNodeSelector  = "NodeSelector"
+	CheckNodeAffinity  = "NodeAffinity"
+	CheckPodAffinity   = "PodAffinity"
+	CheckTaints        = "Taints"
+	CheckVolumes       = "Volumes"
+)
+
+// ErrNotPending is returned when explaining the scheduling of a pod that is already scheduled
+var ErrNotPending = errors.New("pod is not pending")
+
+// schedulingCluster is what the checks need to know besides the node itself
+type schedulingCluster struct {
+	pods   []corev1.Pod // every pod, for used resources and inter-pod affinity
+	nodes  map[string]*corev1.Node
+	claims map[string]*corev1.PersistentVolumeClaim // claims of the pod by name, nil if missing
+	pvs    map[string]*corev1.PersistentVolume
+	scs    map[string]*storagev1.StorageClass
+}
+
+// ExplainScheduling evaluates each node against a pending pod the way the
+// scheduler's filters do and returns a row per node with the failing checks
+func (c *Client) ExplainScheduling(ctx context.Context, namespace, name string) (*api.SchedulingExplanation, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if pod.Spec.NodeName != "" || pod.Status.Phase != corev1.PodPending {
+		return nil, fmt.Errorf("%w: %s/%s is %s on node %q", ErrNotPending, namespace, name, pod.Status.Phase, pod.Spec.NodeName)
+	}
+
+	nodes, err := c.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := c.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cluster := &schedulingCluster{
+		pods:   pods.Items,
+		nodes:  make(map[string]*corev1.Node),
+		claims: make(map[string]*corev1.PersistentVolumeClaim),
+		pvs:    make(map[string]*corev1.PersistentVolume),
+		scs:    make(map[string]*storagev1.StorageClass),
+	}
+	for i := range nodes.Items {
+		cluster.nodes[nodes.Items[i].Name] = &nodes.Items[i]
+	}
+	if err := c.loadPodVolumes(ctx, pod, cluster); err != nil {
+		return nil, err
+	}
+
+	explanation := &api.SchedulingExplanation{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Requests:  resourceListToMap(podRequests(*pod)),
+		Nodes:     []api.NodeFit{},
+	}
+	events, err := c.getObjectEvents(ctx, namespace, "Pod", pod.Name, pod.UID)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if event.Reason == "FailedScheduling" {
+			explanation.SchedulerMessage = event.Message
+		}
+	}
+
+	for _, node := range nodes.Items {
+		fit := evaluateNode(*pod, node, cluster)
+		if fit.Fits {
+			explanation.FittingNodes++
+		}
+		explanation.Nodes = append(explanation.Nodes, fit)
+	}
+	sort.SliceStable(explanation.Nodes, func(i, j int) bool { return explanation.Nodes[i].Node < explanation.Nodes[j].Node })
+	return explanation, nil
+}
+
+// loadPodVolumes reads the claims of the pod and their volumes and storage classes
+func (c *Client) loadPodVolumes(ctx context.Context, pod *corev1.Pod, cluster *schedulingCluster) error {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claimName := volume.PersistentVolumeClaim.ClaimName
+		claim, err := c.Clientset.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(ctx, claimName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cluster.claims[claimName] = nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cluster.claims[claimName] = claim
+
+		if claim.Spec.VolumeName != "" {
+			pv, err := c.Clientset.CoreV1().PersistentVolumes().Get(ctx, claim.Spec.VolumeName, metav1.GetOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				cluster.pvs[pv.Name] = pv
+			}
+		}
+		if class := claim.Spec.StorageClassName; class != nil && *class != "" {
+			sc, err := c.Clientset.StorageV1().StorageClasses().Get(ctx, *class, metav1.GetOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				cluster.scs[sc.Name] = sc
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateNode runs every check of pod against node
+func evaluateNode(pod corev1.Pod, node corev1.Node, cluster *schedulingCluster) api.NodeFit {
+	checks := []api.SchedulingCheck{
+		checkUnschedulable(pod, node),
+		checkResources(pod, node, cluster.pods),
+		checkNodeSelector(pod, node),
+		checkNodeAffinity(pod, node),
+		checkPodAffinity(pod, node, cluster),
+		checkTaints(pod, node),
+		checkVolumes(pod, node, cluster),
+	}
+	fit := api.NodeFit{Node: node.Name, Fits: true, Checks: checks}
+	for _, check := range checks {
+		if !check.Passed {
+			fit.Fits = false
+		}
+	}
+	return fit
+}
+
+func passed(name string) api.SchedulingCheck {
+	return api.SchedulingCheck{Name: name, Passed: true}
+}
+
+func failed(name, format string, args ...interface{}) api.SchedulingCheck {
+	return api.SchedulingCheck{Name: name, Reason: fmt.Sprintf(format, args...)}
+}
+
+func checkUnschedulable(pod corev1.Pod, node corev1.Node) api.SchedulingCheck {
+	if !node.Spec.Unschedulable {
+		return passed(CheckUnschedulable)
+	}
+	taint := corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}
+	if toleratesTaint(pod.Spec.Tolerations, taint) {
+		return passed(CheckUnschedulable)
+	}
+	return failed(CheckUnschedulable, "node is cordoned")
+}
+
+// checkResources compares the pod's requests with what the pods already on the node leave free
+func checkResources(pod corev1.Pod, node corev1.Node, pods []corev1.Pod) api.SchedulingCheck {
+	used := corev1.ResourceList{}
+	count := 0
+	for _, other := range pods {
+		if other.Spec.NodeName != node.Name || !activePod(other) {
+			continue
+		}
+		count++
+		addResources(used, podRequests(other))
+	}
+
+	var reasons []string
+	if allowed, ok := node.Status.Allocatable[corev1.ResourcePods]; ok && int64(count) >= allowed.Value() {
+		reasons = append(reasons, fmt.Sprintf("too many pods: %d of %d", count, allowed.Value()))
+	}
+	requests := podRequests(pod)
+	names := make([]string, 0, len(requests))
+	for name := range requests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		requested := requests[corev1.ResourceName(name)]
+		if requested.IsZero() {
+			continue
+		}
+		allocatable := node.Status.Allocatable[corev1.ResourceName(name)]
+		free := allocatable.DeepCopy()
+		free.Sub(used[corev1.ResourceName(name)])
+		if requested.Cmp(free) > 0 {
+			usedQuantity := used[corev1.ResourceName(name)]
+			reasons = append(reasons, fmt.Sprintf("insufficient %s: requests %s, %s free (allocatable %s, requested by other pods %s)",
+				name, requested.String(), free.String(), allocatable.String(), usedQuantity.String()))
+		}
+	}
+	if len(reasons) > 0 {
+		return failed(CheckResources, "%s", strings.Join(reasons, "; "))
+	}
+	return passed(CheckResources)
+}
+
+func checkNodeSelector(pod corev1.Pod, node corev1.Node) api.SchedulingCheck {
+	var missing []string
+	for key, value := range pod.Spec.NodeSelector {
+		if actual, ok := node.Labels[key]; !ok || actual != value {
+			missing = append(missing, key+"="+value)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return failed(CheckNodeSelector, "node lacks labels %s", strings.Join(missing, ", "))
+	}
+	return passed(CheckNodeSelector)
+}
+
+// checkNodeAffinity evaluates the required node affinity: one of the terms must match
+func checkNodeAffinity(pod corev1.Pod, node corev1.Node) api.SchedulingCheck {
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return passed(CheckNodeAffinity)
+	}
+	ok, reason := matchNodeSelectorTerms(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms, node)
+	if !ok {
+		return failed(CheckNodeAffinity, "%s", reason)
+	}
+	return passed(CheckNodeAffinity)
+}
+
+// matchNodeSelectorTerms reports whether any term matches node, and why none did
+func matchNodeSelectorTerms(terms []corev1.NodeSelectorTerm, node corev1.Node) (bool, string) {
+	var reasons []string
+	for _, term := range terms {
+		unmatched := unmatchedRequirements(term, node)
+		if len(unmatched) == 0 {
+			return true, ""
+		}
+		reasons = append(reasons, strings.Join(unmatched, " and "))
+	}
+	if len(reasons) == 0 {
+		return false, "no node selector terms"
+	}
+	return false, "does not match " + strings.Join(reasons, " or ")
+}
+
+// unmatchedRequirements lists the requirements of a node selector term that node does not meet
+func unmatchedRequirements(term corev1.NodeSelectorTerm, node corev1.Node) []string {
+	var unmatched []string
+	check := func(requirements []corev1.NodeSelectorRequirement, values labels.Set) {
+		for _, req := range requirements {
+			if !matchNodeSelectorRequirement(req, values) {
+				unmatched = append(unmatched, fmt.Sprintf("%s %s %v", req.Key, req.Operator, req.Values))
+			}
+		}
+	}
+	check(term.MatchExpressions, node.Labels)
+	check(term.MatchFields, labels.Set{"metadata.name": node.Name})
+	return unmatched
+}
+
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+func matchNodeSelectorRequirement(req corev1.NodeSelectorRequirement, values labels.Set) bool {
+	op, ok := nodeSelectorOperators[req.Operator]
+	if !ok {
+		return false
+	}
+	requirement, err := labels.NewRequirement(req.Key, op, req.Values)
+	if err != nil {
+		return false
+	}
+	return requirement.Matches(values)
+}
+
+// checkPodAffinity evaluates the required pod affinity and anti-affinity terms
+// against the pods running in the node's topology domain
+func checkPodAffinity(pod corev1.Pod, node corev1.Node, cluster *schedulingCluster) api.SchedulingCheck {
+	affinity := pod.Spec.Affinity
+	if affinity == nil {
+		return passed(CheckPodAffinity)
+	}
+	var reasons []string
+	if affinity.PodAffinity != nil {
+		for _, term := range affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if len(matchingPodsInDomain(pod, term, node, cluster)) == 0 {
+				reasons = append(reasons, fmt.Sprintf("no pod matching %s in the same %s", metav1.FormatLabelSelector(term.LabelSelector), term.TopologyKey))
+			}
+		}
+	}
+	if affinity.PodAntiAffinity != nil {
+		for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if matched := matchingPodsInDomain(pod, term, node, cluster); len(matched) > 0 {
+				reasons = append(reasons, fmt.Sprintf("anti-affinity with pod %s in the same %s", matched[0], term.TopologyKey))
+			}
+		}
+	}
+	if len(reasons) > 0 {
+		return failed(CheckPodAffinity, "%s", strings.Join(reasons, "; "))
+	}
+	return passed(CheckPodAffinity)
+}
+
+// matchingPodsInDomain returns the pods matching an affinity term that run on a
+// node sharing the term's topology value with node
+func matchingPodsInDomain(pod corev1.Pod, term corev1.PodAffinityTerm, node corev1.Node, cluster *schedulingCluster) []string {
+	domain, ok := node.Labels[term.TopologyKey]
+	if !ok {
+		return nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+	if err != nil {
+		return nil
+	}
+	// Namespace selectors are not resolved: a term with one matches pods of every namespace
+	allNamespaces := term.NamespaceSelector != nil
+	namespaces := map[string]bool{}
+	for _, ns := range term.Namespaces {
+		namespaces[ns] = true
+	}
+	if len(namespaces) == 0 {
+		namespaces[pod.Namespace] = true
+	}
+
+	var matched []string
+	for _, other := range cluster.pods {
+		if other.Spec.NodeName == "" || (other.Namespace == pod.Namespace && other.Name == pod.Name) {
+			continue
+		}
+		if !allNamespaces && !namespaces[other.Namespace] {
+			continue
+		}
+		if !selector.Matches(labels.Set(other.Labels)) {
+			continue
+		}
+		otherNode := cluster.nodes[other.Spec.NodeName]
+		if otherNode != nil && otherNode.Labels[term.TopologyKey] == domain {
+			matched = append(matched, other.Namespace+"/"+other.Name)
+		}
+	}
+	return matched
+}
+
+// checkTaints fails for each NoSchedule or NoExecute taint the pod does not tolerate
+func checkTaints(pod corev1.Pod, node corev1.Node) api.SchedulingCheck {
+	var untolerated []string
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(pod.Spec.Tolerations, taint) {
+			untolerated = append(untolerated, taint.ToString())
+		}
+	}
+	if len(untolerated) > 0 {
+		return failed(CheckTaints, "untolerated taints %s", strings.Join(untolerated, ", "))
+	}
+	return passed(CheckTaints)
+}
+
+func toleratesTaint(tolerations []corev1.Toleration, taint corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVolumes checks that the pod's claims exist and that bound volumes, or
+// the allowed topologies of volumes still to be provisioned, include the node
+func checkVolumes(pod corev1.Pod, node corev1.Node, cluster *schedulingCluster) api.SchedulingCheck {
+	var reasons []string
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		name := volume.PersistentVolumeClaim.ClaimName
+		claim := cluster.claims[name]
+		if claim == nil {
+			reasons = append(reasons, fmt.Sprintf("persistentvolumeclaim %q not found", name))
+			continue
+		}
+
+		if claim.Spec.VolumeName != "" {
+			pv := cluster.pvs[claim.Spec.VolumeName]
+			if pv == nil {
+				reasons = append(reasons, fmt.Sprintf("volume %s of claim %s not found", claim.Spec.VolumeName, name))
+				continue
+			}
+			if pv.Spec.NodeAffinity != nil && pv.Spec.NodeAffinity.Required != nil {
+				if ok, why := matchNodeSelectorTerms(pv.Spec.NodeAffinity.Required.NodeSelectorTerms, node); !ok {
+					reasons = append(reasons, fmt.Sprintf("volume %s of claim %s is not reachable from this node: %s", pv.Name, name, why))
+				}
+			}
+			continue
+		}
+
+		var sc *storagev1.StorageClass
+		if claim.Spec.StorageClassName != nil {
+			sc = cluster.scs[*claim.Spec.StorageClassName]
+		}
+		if sc == nil || sc.VolumeBindingMode == nil || *sc.VolumeBindingMode != storagev1.VolumeBindingWaitForFirstConsumer {
+			reasons = append(reasons, fmt.Sprintf("claim %s is not bound", name))
+			continue
+		}
+		// WaitForFirstConsumer: the volume is provisioned where the pod lands, within the allowed topologies
+		if len(sc.AllowedTopologies) > 0 && !matchTopologies(sc.AllowedTopologies, node) {
+			reasons = append(reasons, fmt.Sprintf("storage class %s cannot provision volumes in this node's topology", sc.Name))
+		}
+	}
+	if len(reasons) > 0 {
+		return failed(CheckVolumes, "%s", strings.Join(reasons, "; "))
+	}
+	return passed(CheckVolumes)
+}
+
+func matchTopologies(terms []corev1.TopologySelectorTerm, node corev1.Node) bool {
+	for _, term := range terms {
+		matched := true
+		for _, req := range term.MatchLabelExpressions {
+			value, ok := node.Labels[req.Key]
+			if !ok || !containsString(req.Values, value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
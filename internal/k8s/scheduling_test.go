@@ -0,0 +1,152 @@
+package k8s
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func schedulingNode(name, zone, cpu string, labels map[string]string) *corev1.Node {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["topology.kubernetes.io/zone"] = zone
+	node := testNode(name, corev1.ConditionTrue, labels)
+	node.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+		corev1.ResourcePods:   resource.MustParse("110"),
+	}
+	return node
+}
+
+func requesting(pod *corev1.Pod, cpu string) *corev1.Pod {
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+	}}}
+	return pod
+}
+
+func TestExplainScheduling(t *testing.T) {
+	ssd := map[string]string{"disk": "ssd"}
+	full := schedulingNode("full", "a", "2", map[string]string{"disk": "ssd"})
+	hdd := schedulingNode("hdd", "a", "4", nil)
+	tainted := schedulingNode("tainted", "a", "4", map[string]string{"disk": "ssd"})
+	tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	cordoned := schedulingNode("cordoned", "a", "4", map[string]string{"disk": "ssd"})
+	cordoned.Spec.Unschedulable = true
+	otherZone := schedulingNode("other-zone", "b", "4", map[string]string{"disk": "ssd"})
+	good := schedulingNode("good", "a", "4", map[string]string{"disk": "ssd"})
+
+	running := requesting(testPod("shop", "busy", corev1.PodRunning, nil), "1500m")
+	running.Spec.NodeName = "full"
+
+	pending := requesting(testPod("shop", "db-1", corev1.PodPending, nil), "1")
+	pending.Spec.NodeName = ""
+	pending.Spec.NodeSelector = ssd
+	pending.Spec.Tolerations = []corev1.Toleration{{Key: "node.kubernetes.io/not-ready", Operator: corev1.TolerationOpExists}}
+	pending.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data-db-1"},
+	}}}
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: objectMeta("shop", "data-db-1", nil),
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pv-a"},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: objectMeta("", "pv-a", nil),
+		Spec: corev1.PersistentVolumeSpec{NodeAffinity: &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{
+				{Key: "topology.kubernetes.io/zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}},
+			}}},
+		}}},
+	}
+	scheduled := testPod("shop", "web-1", corev1.PodRunning, nil)
+
+	c := newFakeClient(t, full, hdd, tainted, cordoned, otherZone, good, running, pending, claim, pv, scheduled)
+	h := NewHandler(c)
+
+	w := serve(t, h, http.MethodGet, "/pods/shop/db-1/scheduling")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var explanation api.SchedulingExplanation
+	if err := json.Unmarshal(w.Body.Bytes(), &explanation); err != nil {
+		t.Fatal(err)
+	}
+	if explanation.FittingNodes != 1 || explanation.Requests["cpu"] != "1" {
+		t.Errorf("unexpected explanation %+v", explanation)
+	}
+
+	want := map[string]string{
+		"full":       CheckResources,
+		"hdd":        CheckNodeSelector,
+		"tainted":    CheckTaints,
+		"cordoned":   CheckUnschedulable,
+		"other-zone": CheckVolumes,
+		"good":       "",
+	}
+	for _, fit := range explanation.Nodes {
+		var failing []string
+		for _, check := range fit.Checks {
+			if !check.Passed {
+				failing = append(failing, check.Name)
+				if check.Reason == "" {
+					t.Errorf("%s: %s failed without a reason", fit.Node, check.Name)
+				}
+			}
+		}
+		if strings.Join(failing, ",") != want[fit.Node] || fit.Fits != (want[fit.Node] == "") {
+			t.Errorf("%s: failing checks %v, want %q", fit.Node, failing, want[fit.Node])
+		}
+	}
+
+	if w := serve(t, h, http.MethodGet, "/pods/shop/web-1/scheduling"); w.Code != http.StatusBadRequest {
+		t.Errorf("scheduled pod: status %d", w.Code)
+	}
+	if w := serve(t, h, http.MethodGet, "/pods/shop/missing/scheduling"); w.Code != http.StatusNotFound {
+		t.Errorf("missing pod: status %d", w.Code)
+	}
+}
+
+func TestCheckPodAffinity(t *testing.T) {
+	nodeA := schedulingNode("node-a", "a", "4", nil)
+	nodeB := schedulingNode("node-b", "b", "4", nil)
+	cache := testPod("shop", "cache-0", corev1.PodRunning, map[string]string{"app": "cache"})
+	cache.Spec.NodeName = "node-a"
+	cluster := &schedulingCluster{
+		pods:  []corev1.Pod{*cache},
+		nodes: map[string]*corev1.Node{"node-a": nodeA, "node-b": nodeB},
+	}
+
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "cache"}},
+		TopologyKey:   "topology.kubernetes.io/zone",
+	}
+	pod := testPod("shop", "web-1", corev1.PodPending, nil)
+	pod.Spec.Affinity = &corev1.Affinity{PodAffinity: &corev1.PodAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{term},
+	}}
+	if check := checkPodAffinity(*pod, *nodeA, cluster); !check.Passed {
+		t.Errorf("affinity on node-a: %+v", check)
+	}
+	if check := checkPodAffinity(*pod, *nodeB, cluster); check.Passed {
+		t.Error("affinity on node-b should fail")
+	}
+
+	pod.Spec.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{term},
+	}}
+	if check := checkPodAffinity(*pod, *nodeA, cluster); check.Passed || !strings.Contains(check.Reason, "shop/cache-0") {
+		t.Errorf("anti-affinity on node-a: %+v", check)
+	}
+	if check := checkPodAffinity(*pod, *nodeB, cluster); !check.Passed {
+		t.Errorf("anti-affinity on node-b: %+v", check)
+	}
+}
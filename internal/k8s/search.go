@@ -0,0 +1,326 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kubelens/pkg/api"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// searchKinds maps every accepted kind spelling to its canonical kind
+var searchKinds = map[string]string{
+	"namespace": "Namespace", "namespaces": "Namespace", "ns": "Namespace",
+	"node": "Node", "nodes": "Node", "no": "Node",
+	"pod": "Pod", "pods": "Pod", "po": "Pod",
+	"deployment": "Deployment", "deployments": "Deployment", "deploy": "Deployment",
+	"statefulset": "StatefulSet", "statefulsets": "StatefulSet", "sts": "StatefulSet",
+	"daemonset": "DaemonSet", "daemonsets": "DaemonSet", "ds": "DaemonSet",
+	"replicaset": "ReplicaSet", "replicasets": "ReplicaSet", "rs": "ReplicaSet",
+	"job": "Job", "jobs": "Job",
+	"cronjob": "CronJob", "cronjobs": "CronJob", "cj": "CronJob",
+	"service": "Service", "services": "Service", "svc": "Service",
+	"ingress": "Ingress", "ingresses": "Ingress", "ing": "Ingress",
+	"configmap": "ConfigMap", "configmaps": "ConfigMap", "cm": "ConfigMap",
+	"secret": "Secret", "secrets": "Secret",
+	"persistentvolumeclaim": "PersistentVolumeClaim", "persistentvolumeclaims": "PersistentVolumeClaim", "pvc": "PersistentVolumeClaim",
+	"persistentvolume": "PersistentVolume", "persistentvolumes": "PersistentVolume", "pv": "PersistentVolume",
+}
+
+// ErrInvalidQuery is returned for search queries that cannot be parsed
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// searchPages maps a kind to the UI page that lists it
+var searchPages = map[string]string{
+	"Pod":         "/pods",
+	"Deployment":  "/workloads",
+	"StatefulSet": "/workloads",
+	"DaemonSet":   "/workloads",
+	"Service":     "/services",
+	"Node":        "/nodes",
+}
+
+type searchQuery struct {
+	terms      []string
+	kinds      map[string]bool
+	namespaces map[string]bool
+	selector   labels.Selector
+}
+
+// parseSearchQuery splits a query such as `kind:pod ns:shop app=payment api` into
+// kind and namespace filters, a label selector and free-text terms
+func parseSearchQuery(q, labelSelector string) (*searchQuery, error) {
+	query := &searchQuery{
+		kinds:      make(map[string]bool),
+		namespaces: make(map[string]bool),
+		selector:   labels.Everything(),
+	}
+
+	var selectorTerms []string
+	if labelSelector != "" {
+		selectorTerms = append(selectorTerms, labelSelector)
+	}
+
+	for _, token := range strings.Fields(q) {
+		lower := strings.ToLower(token)
+		switch {
+		case strings.HasPrefix(lower, "kind:"):
+			for _, k := range strings.Split(strings.TrimPrefix(lower, "kind:"), ",") {
+				kind, ok := searchKinds[k]
+				if !ok {
+					return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidQuery, k)
+				}
+				query.kinds[kind] = true
+			}
+		case strings.HasPrefix(lower, "ns:"), strings.HasPrefix(lower, "namespace:"):
+			value := token[strings.Index(token, ":")+1:]
+			for _, ns := range strings.Split(value, ",") {
+				if ns != "" {
+					query.namespaces[ns] = true
+				}
+			}
+		case strings.Contains(token, "=") || strings.HasPrefix(token, "!"):
+			selectorTerms = append(selectorTerms, token)
+		default:
+			query.terms = append(query.terms, lower)
+		}
+	}
+
+	if len(selectorTerms) > 0 {
+		selector, err := labels.Parse(strings.Join(selectorTerms, ","))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+		query.selector = selector
+	}
+
+	return query, nil
+}
+
+// score ranks an object against the free-text terms; 0 means no match.
+// Every term must match somewhere (name, labels or annotations).
+func (q *searchQuery) score(obj searchObject) int {
+	if len(q.terms) == 0 {
+		return 1
+	}
+
+	total := 0
+	name := strings.ToLower(obj.name)
+	for _, term := range q.terms {
+		best := 0
+		switch {
+		case name == term:
+			best = 100
+		case strings.HasPrefix(name, term):
+			best = 60
+		case strings.Contains(name, term):
+			best = 40
+		}
+		for k, v := range obj.labels {
+			k, v = strings.ToLower(k), strings.ToLower(v)
+			switch {
+			case v == term || k == term:
+				best = max(best, 30)
+			case strings.Contains(v, term) || strings.Contains(k, term):
+				best = max(best, 20)
+			}
+		}
+		for k, v := range obj.annotations {
+			if strings.Contains(strings.ToLower(k), term) || strings.Contains(strings.ToLower(v), term) {
+				best = max(best, 10)
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// searchIndexTTL is how long listed objects are reused, so that searching as
+// the user types does not LIST every kind on each keystroke
+const searchIndexTTL = 30 * time.Second
+
+// searchObject is the metadata a search matches against
+type searchObject struct {
+	kind, namespace, name string
+	labels, annotations   map[string]string
+}
+
+// newSearchObject keeps what search needs of an object. Secrets keep no
+// annotations: the last-applied annotation holds their data, and matching it
+// would let a search guess their contents.
+func newSearchObject(kind string, obj metav1.Object) searchObject {
+	o := searchObject{kind: kind, namespace: obj.GetNamespace(), name: obj.GetName(), labels: obj.GetLabels()}
+	if kind != "Secret" {
+		o.annotations = obj.GetAnnotations()
+	}
+	return o
+}
+
+// searchIndex caches the searchable objects of the cluster for searchIndexTTL.
+// The lock is never held while listing: concurrent searches on a stale index
+// wait for the one refresh in flight instead of listing again.
+type searchIndex struct {
+	mu      sync.Mutex
+	built   time.Time
+	objects []searchObject
+	refresh *searchRefresh
+}
+
+// searchRefresh is a listing of the searchable objects in progress
+type searchRefresh struct {
+	done    chan struct{}
+	objects []searchObject
+	err     error
+}
+
+// searchObjects returns the cached index, listing every kind again once it is stale
+func (c *Client) searchObjects(ctx context.Context) ([]searchObject, error) {
+	index := &c.searchIndex
+	index.mu.Lock()
+	if index.objects != nil && time.Since(index.built) < searchIndexTTL {
+		objects := index.objects
+		index.mu.Unlock()
+		return objects, nil
+	}
+	if r := index.refresh; r != nil {
+		index.mu.Unlock()
+		select {
+		case <-r.done:
+			return r.objects, r.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	r := &searchRefresh{done: make(chan struct{})}
+	index.refresh = r
+	index.mu.Unlock()
+
+	r.objects, r.err = c.listSearchObjects(ctx)
+
+	index.mu.Lock()
+	if r.err == nil {
+		index.objects, index.built = r.objects, time.Now()
+	}
+	index.refresh = nil
+	index.mu.Unlock()
+	close(r.done)
+	return r.objects, r.err
+}
+
+// listSearchObjects lists the objects of every searchable kind
+func (c *Client) listSearchObjects(ctx context.Context) ([]searchObject, error) {
+	searchable := make(map[string]bool)
+	for _, kind := range searchKinds {
+		searchable[kind] = true
+	}
+	objects := []searchObject{}
+	for _, kind := range fixtureKinds {
+		if !searchable[kind.gvk.Kind] {
+			continue
+		}
+		items, err := kind.list(ctx, c, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, newSearchObject(kind.gvk.Kind, obj))
+		}
+	}
+	return objects, nil
+}
+
+// Search finds objects of every kind whose name, labels or annotations match the query
+func (c *Client) Search(ctx context.Context, q, labelSelector string, limit int) ([]api.SearchResult, error) {
+	query, err := parseSearchQuery(q, labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := c.searchObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []api.SearchResult
+	for _, o := range objects {
+		if len(query.kinds) > 0 && !query.kinds[o.kind] {
+			continue
+		}
+		if len(query.namespaces) > 0 && !query.namespaces[o.namespace] {
+			continue
+		}
+		if !query.selector.Matches(labels.Set(o.labels)) {
+			continue
+		}
+		score := query.score(o)
+		if score == 0 {
+			continue
+		}
+		results = append(results, api.SearchResult{
+			Kind:      o.kind,
+			Namespace: o.namespace,
+			Name:      o.name,
+			Labels:    o.labels,
+			Score:     score,
+			Key:       searchKey(o.kind, o.namespace, o.name),
+			Link:      searchLink(o.kind, o.namespace, o.name),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searchKey is the stable deep-link key of an object, e.g. pod/shop/payment-api-7d9f
+func searchKey(kind, namespace, name string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s", strings.ToLower(kind), name)
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.ToLower(kind), namespace, name)
+}
+
+// searchLink is the UI route that shows the object, empty if no page lists the kind
+func searchLink(kind, namespace, name string) string {
+	page, ok := searchPages[kind]
+	if !ok {
+		return ""
+	}
+	params := url.Values{}
+	if namespace != "" {
+		params.Set("namespace", namespace)
+	}
+	params.Set("name", name)
+	return page + "?" + params.Encode()
+}
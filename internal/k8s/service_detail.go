@@ -0,0 +1,168 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// GetServiceDetail describes a service: the pods its selector matches, its
+// target ports resolved against their container ports and the endpoints of
+// its EndpointSlices, with warnings when traffic to it cannot reach a pod
+func (c *Client) GetServiceDetail(ctx context.Context, namespace, name string) (*api.ServiceDetail, error) {
+	svc, err := c.Clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	detail := &api.ServiceDetail{
+		Service:      newService(*svc),
+		Selector:     svc.Spec.Selector,
+		Pods:         []string{},
+		PortMappings: []api.ServicePortMapping{},
+		Endpoints:    []api.ServiceEndpoint{},
+		Warnings:     []string{},
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		// ExternalName services are a DNS alias without pods or endpoints
+		return detail, nil
+	}
+
+	var pods []corev1.Pod
+	if len(svc.Spec.Selector) > 0 {
+		podList, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		pods = podList.Items
+		sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+		for _, pod := range pods {
+			detail.Pods = append(detail.Pods, pod.Name)
+		}
+	}
+
+	for _, port := range svc.Spec.Ports {
+		mapping := resolveTargetPort(port, pods)
+		detail.PortMappings = append(detail.PortMappings, mapping)
+		if len(mapping.Unresolved) > 0 {
+			detail.Warnings = append(detail.Warnings, fmt.Sprintf("target port %s is not a container port of %s",
+				mapping.TargetPort, strings.Join(mapping.Unresolved, ", ")))
+		}
+	}
+
+	slices, err := c.Clientset.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			e := newServiceEndpoint(slice.Name, endpoint)
+			if e.Ready {
+				detail.ReadyEndpoints++
+			}
+			detail.Endpoints = append(detail.Endpoints, e)
+		}
+	}
+	sort.SliceStable(detail.Endpoints, func(i, j int) bool { return detail.Endpoints[i].Pod < detail.Endpoints[j].Pod })
+
+	if len(svc.Spec.Selector) > 0 && len(pods) == 0 {
+		detail.Warnings = append(detail.Warnings, fmt.Sprintf("selector %s matches no pods", labels.SelectorFromSet(svc.Spec.Selector)))
+	}
+	if detail.ReadyEndpoints == 0 && (len(svc.Spec.Selector) > 0 || len(detail.Endpoints) > 0) {
+		detail.Warnings = append(detail.Warnings, "no ready endpoints: connections to the service will fail")
+	}
+	return detail, nil
+}
+
+// resolveTargetPort maps a service port to the container ports of pods. A
+// numeric target port is used as is; a named one is looked up in every pod,
+// since the same name may be a different number in each.
+func resolveTargetPort(port corev1.ServicePort, pods []corev1.Pod) api.ServicePortMapping {
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	mapping := api.ServicePortMapping{Name: port.Name, Port: port.Port, Protocol: string(protocol), ResolvedPorts: []int32{}}
+	if port.TargetPort.Type == intstr.Int {
+		target := port.TargetPort.IntVal
+		if target == 0 {
+			target = port.Port
+		}
+		mapping.TargetPort = strconv.Itoa(int(target))
+		mapping.ResolvedPorts = append(mapping.ResolvedPorts, target)
+		return mapping
+	}
+
+	mapping.TargetPort = port.TargetPort.StrVal
+	seen := make(map[int32]bool)
+	for _, pod := range pods {
+		number, ok := containerPort(pod, port.TargetPort.StrVal, protocol)
+		if !ok {
+			mapping.Unresolved = append(mapping.Unresolved, pod.Name)
+			continue
+		}
+		if !seen[number] {
+			seen[number] = true
+			mapping.ResolvedPorts = append(mapping.ResolvedPorts, number)
+		}
+	}
+	sort.Slice(mapping.ResolvedPorts, func(i, j int) bool { return mapping.ResolvedPorts[i] < mapping.ResolvedPorts[j] })
+	return mapping
+}
+
+// containerPort finds the number of a named container port of a pod
+func containerPort(pod corev1.Pod, name string, protocol corev1.Protocol) (int32, bool) {
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			proto := p.Protocol
+			if proto == "" {
+				proto = corev1.ProtocolTCP
+			}
+			if p.Name == name && proto == protocol {
+				return p.ContainerPort, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// newServiceEndpoint reads the conditions of an endpoint. Per the
+// EndpointSlice API an unknown ready condition means ready, and an unknown
+// serving condition follows ready.
+func newServiceEndpoint(slice string, endpoint discoveryv1.Endpoint) api.ServiceEndpoint {
+	e := api.ServiceEndpoint{Slice: slice, Addresses: endpoint.Addresses, Ready: true}
+	conditions := endpoint.Conditions
+	if conditions.Ready != nil {
+		e.Ready = *conditions.Ready
+	}
+	e.Serving = e.Ready
+	if conditions.Serving != nil {
+		e.Serving = *conditions.Serving
+	}
+	if conditions.Terminating != nil {
+		e.Terminating = *conditions.Terminating
+	}
+	if ref := endpoint.TargetRef; ref != nil && ref.Kind == "Pod" {
+		e.Pod = ref.Name
+	}
+	if endpoint.NodeName != nil {
+		e.Node = *endpoint.NodeName
+	}
+	if endpoint.Zone != nil {
+		e.Zone = *endpoint.Zone
+	}
+	return e
+}
@@ -0,0 +1,118 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetServiceDetailDemo(t *testing.T) {
+	c, err := NewDemoClient(demoFixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c)
+
+	w := serve(t, h, http.MethodGet, "/services/shop/web")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var web api.ServiceDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &web); err != nil {
+		t.Fatal(err)
+	}
+	// targetPort: http is the container port named http, 80
+	if web.Ports[0].TargetPortName != "http" || web.Ports[0].TargetPort != 0 {
+		t.Errorf("ports %+v", web.Ports)
+	}
+	mapping := web.PortMappings[0]
+	if mapping.TargetPort != "http" || len(mapping.ResolvedPorts) != 1 || mapping.ResolvedPorts[0] != 80 || len(mapping.Unresolved) != 0 {
+		t.Errorf("mapping %+v", mapping)
+	}
+	if len(web.Pods) != 2 || web.ReadyEndpoints != 2 || len(web.Warnings) != 0 {
+		t.Errorf("web %+v", web)
+	}
+
+	detail, err := c.GetServiceDetail(context.Background(), "shop", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.ReadyEndpoints != 0 || len(detail.Endpoints) != 1 || detail.Endpoints[0].Pod != "api-5f7b8c9d4-mn3pq" {
+		t.Errorf("api endpoints %+v", detail.Endpoints)
+	}
+	if !equalStrings(detail.Warnings, []string{"no ready endpoints: connections to the service will fail"}) {
+		t.Errorf("api warnings %v", detail.Warnings)
+	}
+
+	if w := serve(t, h, http.MethodGet, "/services/shop/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("missing service status %d", w.Code)
+	}
+}
+
+func TestGetServiceDetailWarnings(t *testing.T) {
+	pod := testPod("shop", "web-1", corev1.PodRunning, map[string]string{"app": "web"})
+	pod.Spec.Containers[0].Ports = []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}
+	other := testPod("shop", "web-2", corev1.PodRunning, map[string]string{"app": "web"})
+
+	svc := testService("shop", "web")
+	svc.Spec.Selector = map[string]string{"app": "web"}
+	svc.Spec.Ports = []corev1.ServicePort{
+		{Name: "http", Port: 80, TargetPort: intstr.FromString("http")},
+		{Name: "metrics", Port: 9090},
+	}
+	orphan := testService("shop", "legacy")
+	orphan.Spec.Selector = map[string]string{"app": "legacy"}
+
+	c := newFakeClient(t, pod, other, svc, orphan)
+	detail, err := c.GetServiceDetail(context.Background(), "shop", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	named, metrics := detail.PortMappings[0], detail.PortMappings[1]
+	if len(named.ResolvedPorts) != 1 || named.ResolvedPorts[0] != 8080 || !equalStrings(named.Unresolved, []string{"web-2"}) {
+		t.Errorf("named port mapping %+v", named)
+	}
+	if metrics.TargetPort != "9090" || metrics.ResolvedPorts[0] != 9090 {
+		t.Errorf("unset target port defaults to the port: %+v", metrics)
+	}
+	want := []string{
+		"target port http is not a container port of web-2",
+		"no ready endpoints: connections to the service will fail",
+	}
+	if !equalStrings(detail.Warnings, want) {
+		t.Errorf("web warnings %v", detail.Warnings)
+	}
+
+	detail, err = c.GetServiceDetail(context.Background(), "shop", "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Pods) != 0 || len(detail.Warnings) != 2 || detail.Warnings[0] != "selector app=legacy matches no pods" {
+		t.Errorf("legacy %+v", detail)
+	}
+}
+
+func TestNewServiceEndpoint(t *testing.T) {
+	no, yes := false, true
+	node := "worker-1"
+	e := newServiceEndpoint("web-abc", discoveryv1.Endpoint{
+		Addresses:  []string{"10.0.0.5"},
+		NodeName:   &node,
+		TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: "web-1"},
+		Conditions: discoveryv1.EndpointConditions{Ready: &no, Serving: &yes, Terminating: &yes},
+	})
+	if e.Ready || !e.Serving || !e.Terminating || e.Pod != "web-1" || e.Node != "worker-1" {
+		t.Errorf("terminating endpoint %+v", e)
+	}
+	// Unknown conditions mean ready and serving
+	if e := newServiceEndpoint("web-abc", discoveryv1.Endpoint{}); !e.Ready || !e.Serving || e.Terminating {
+		t.Errorf("unknown conditions %+v", e)
+	}
+}
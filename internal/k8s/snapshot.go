@@ -0,0 +1,338 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubelens/pkg/api"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/yaml"
+)
+
+// Snapshot reasons
+const (
+	SnapshotScheduled = "scheduled"
+	SnapshotManual    = "manual"
+)
+
+// Field change types of a snapshot diff
+const (
+	FieldAdded   = "added"
+	FieldRemoved = "removed"
+	FieldChanged = "changed"
+)
+
+// SnapshotStore persists compressed snapshots, in Postgres or on disk
+type SnapshotStore interface {
+	SaveSnapshot(ctx context.Context, info api.SnapshotInfo, data []byte) (api.SnapshotInfo, error)
+	ListSnapshots(ctx context.Context) ([]api.SnapshotInfo, error)
+	GetSnapshot(ctx context.Context, id int64) (api.SnapshotInfo, []byte, error)
+	DeleteSnapshotsBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+// snapshotSkipped lists the fixture kinds left out of snapshots: they change on
+// every read or follow the pods, so they would drown the diff
+var snapshotSkipped = map[string]bool{"events": true, "endpointslices": true, "nodemetrics": true, "podmetrics": true}
+
+// ignoredDiffFields change without anyone changing the object
+var ignoredDiffFields = []string{"metadata.resourceVersion", "lastHeartbeatTime"}
+
+// snapshotObject is one object of a snapshot, stored as its JSON manifest
+type snapshotObject struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Namespace  string          `json:"namespace,omitempty"`
+	Name       string          `json:"name"`
+	Object     json.RawMessage `json:"object"`
+}
+
+func (o snapshotObject) ref() api.ObjectRef {
+	return api.ObjectRef{Kind: o.Kind, Namespace: o.Namespace, Name: o.Name}
+}
+
+func (o snapshotObject) key() string {
+	return o.Kind + "/" + o.Namespace + "/" + o.Name
+}
+
+// CaptureSnapshot reads every resource KubeLens shows, with secret values redacted
+func (c *Client) CaptureSnapshot(ctx context.Context) ([]snapshotObject, error) {
+	var objects []snapshotObject
+	for _, kind := range fixtureKinds {
+		if snapshotSkipped[kind.file] {
+			continue
+		}
+		items, err := kind.list(ctx, c, "")
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", kind.file, err)
+		}
+		for _, item := range items {
+			item = item.DeepCopyObject()
+			item.GetObjectKind().SetGroupVersionKind(kind.gvk)
+			sanitizeFixture(item)
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				return nil, err
+			}
+			raw, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, snapshotObject{
+				APIVersion: kind.gvk.GroupVersion().String(),
+				Kind:       kind.gvk.Kind,
+				Namespace:  accessor.GetNamespace(),
+				Name:       accessor.GetName(),
+				Object:     raw,
+			})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].key() < objects[j].key() })
+	return objects, nil
+}
+
+// TakeSnapshot captures the cluster and saves it compressed
+func (c *Client) TakeSnapshot(ctx context.Context, store SnapshotStore, reason string) (api.SnapshotInfo, error) {
+	objects, err := c.CaptureSnapshot(ctx)
+	if err != nil {
+		return api.SnapshotInfo{}, err
+	}
+	data, err := encodeSnapshot(objects)
+	if err != nil {
+		return api.SnapshotInfo{}, err
+	}
+	info := api.SnapshotInfo{CreatedAt: time.Now().UTC(), Reason: reason, Objects: len(objects)}
+	return store.SaveSnapshot(ctx, info, data)
+}
+
+// RunSnapshotSchedule takes a snapshot every interval until ctx is done and, if
+// retain is positive, deletes snapshots older than retain
+func (c *Client) RunSnapshotSchedule(ctx context.Context, store SnapshotStore, interval, retain time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := c.TakeSnapshot(ctx, store, SnapshotScheduled)
+		if err != nil {
+			log.Printf("[warn] scheduled snapshot failed: %v", err)
+			continue
+		}
+		log.Printf("Saved snapshot %d with %d objects", info.ID, info.Objects)
+
+		if retain > 0 {
+			if n, err := store.DeleteSnapshotsBefore(ctx, time.Now().Add(-retain)); err != nil {
+				log.Printf("[warn] snapshot cleanup failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d snapshots older than %s", n, retain)
+			}
+		}
+	}
+}
+
+func encodeSnapshot(objects []snapshotObject) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(objects); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSnapshot(data []byte) ([]snapshotObject, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompress snapshot: %w", err)
+	}
+	defer zr.Close()
+	var objects []snapshotObject
+	if err := json.NewDecoder(zr).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return objects, nil
+}
+
+// writeSnapshotTar writes a gzipped tarball with one YAML manifest per object,
+// laid out as <root>/<kind>/<namespace>/<name>.yaml
+func writeSnapshotTar(w io.Writer, root string, modTime time.Time, objects []snapshotObject) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for _, obj := range objects {
+		manifest, err := yaml.JSONToYAML(obj.Object)
+		if err != nil {
+			return err
+		}
+		parts := []string{root, strings.ToLower(obj.Kind)}
+		if obj.Namespace != "" {
+			parts = append(parts, obj.Namespace)
+		}
+		parts = append(parts, obj.Name+".yaml")
+
+		header := &tar.Header{
+			Name:    strings.Join(parts, "/"),
+			Mode:    0o644,
+			Size:    int64(len(manifest)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(manifest); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// snapshotFilter restricts a diff to some kinds and namespaces
+type snapshotFilter struct {
+	kinds      map[string]bool
+	namespaces map[string]bool
+}
+
+func (f snapshotFilter) match(obj snapshotObject) bool {
+	if len(f.kinds) > 0 && !f.kinds[strings.ToLower(obj.Kind)] {
+		return false
+	}
+	return len(f.namespaces) == 0 || f.namespaces[obj.Namespace]
+}
+
+// diffSnapshots compares two snapshots object by object
+func diffSnapshots(from, to []snapshotObject, filter snapshotFilter) (created, deleted []api.ObjectRef, changed []api.ObjectDiff, err error) {
+	before := make(map[string]snapshotObject)
+	for _, obj := range from {
+		if filter.match(obj) {
+			before[obj.key()] = obj
+		}
+	}
+
+	created, deleted, changed = []api.ObjectRef{}, []api.ObjectRef{}, []api.ObjectDiff{}
+	seen := make(map[string]bool)
+	for _, obj := range to {
+		if !filter.match(obj) {
+			continue
+		}
+		seen[obj.key()] = true
+		old, ok := before[obj.key()]
+		if !ok {
+			created = append(created, obj.ref())
+			continue
+		}
+		fields, err := diffManifests(old.Object, obj.Object)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %w", obj.key(), err)
+		}
+		if len(fields) > 0 {
+			changed = append(changed, api.ObjectDiff{ObjectRef: obj.ref(), Fields: fields})
+		}
+	}
+	for _, obj := range from {
+		if filter.match(obj) && !seen[obj.key()] {
+			deleted = append(deleted, obj.ref())
+		}
+	}
+	return created, deleted, changed, nil
+}
+
+// diffManifests returns the leaf fields that differ between two JSON manifests
+func diffManifests(oldRaw, newRaw json.RawMessage) ([]api.FieldChange, error) {
+	var oldObj, newObj interface{}
+	if err := json.Unmarshal(oldRaw, &oldObj); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(newRaw, &newObj); err != nil {
+		return nil, err
+	}
+	oldFields := make(map[string]interface{})
+	newFields := make(map[string]interface{})
+	flattenJSON("", oldObj, oldFields)
+	flattenJSON("", newObj, newFields)
+	for _, fields := range []map[string]interface{}{oldFields, newFields} {
+		for path := range fields {
+			if ignoredDiffField(path) {
+				delete(fields, path)
+			}
+		}
+	}
+	return diffFields(oldFields, newFields), nil
+}
+
+// diffFields compares two flattened objects, sorted by path
+func diffFields(oldFields, newFields map[string]interface{}) []api.FieldChange {
+	var changes []api.FieldChange
+	for path, oldValue := range oldFields {
+		newValue, ok := newFields[path]
+		switch {
+		case !ok:
+			changes = append(changes, api.FieldChange{Path: path, Type: FieldRemoved, Old: oldValue})
+		case !reflect.DeepEqual(oldValue, newValue):
+			changes = append(changes, api.FieldChange{Path: path, Type: FieldChanged, Old: oldValue, New: newValue})
+		}
+	}
+	for path, newValue := range newFields {
+		if _, ok := oldFields[path]; !ok {
+			changes = append(changes, api.FieldChange{Path: path, Type: FieldAdded, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func ignoredDiffField(path string) bool {
+	for _, field := range ignoredDiffFields {
+		if path == field || strings.HasSuffix(path, "."+field) {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenJSON maps every leaf of v to its path, e.g. spec.containers[0].image.
+// Empty maps and lists count as leaves so that clearing them shows up.
+func flattenJSON(prefix string, v interface{}, out map[string]interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if len(value) == 0 && prefix != "" {
+			out[prefix] = value
+			return
+		}
+		for key, child := range value {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenJSON(path, child, out)
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			out[prefix] = value
+			return
+		}
+		for i, child := range value {
+			flattenJSON(prefix+"["+strconv.Itoa(i)+"]", child, out)
+		}
+	default:
+		out[prefix] = value
+	}
+}
@@ -0,0 +1,189 @@
+package k8s
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"kubelens/internal/db"
+	"kubelens/pkg/api"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffManifests(t *testing.T) {
+	oldObj := `{"metadata":{"name":"web","resourceVersion":"1","labels":{"app":"web"}},
+		"spec":{"replicas":2,"template":{"spec":{"containers":[{"name":"web","image":"nginx:1.24"}]}}},
+		"status":{"conditions":[{"type":"Ready","lastHeartbeatTime":"2024-01-01T00:00:00Z"}]}}`
+	newObj := `{"metadata":{"name":"web","resourceVersion":"2","labels":{"app":"web","tier":"frontend"}},
+		"spec":{"template":{"spec":{"containers":[{"name":"web","image":"nginx:1.25"}]}}},
+		"status":{"conditions":[{"type":"Ready","lastHeartbeatTime":"2024-01-01T00:05:00Z"}]}}`
+
+	got, err := diffManifests(json.RawMessage(oldObj), json.RawMessage(newObj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []api.FieldChange{
+		{Path: "metadata.labels.tier", Type: FieldAdded, New: "frontend"},
+		{Path: "spec.replicas", Type: FieldRemoved, Old: float64(2)},
+		{Path: "spec.template.spec.containers[0].image", Type: FieldChanged, Old: "nginx:1.24", New: "nginx:1.25"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSnapshotEndpoints(t *testing.T) {
+	ctx := context.Background()
+	deploy := testDeployment("shop", "web", 1, 1)
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "web", Image: "nginx:1.24"}}
+	c := newFakeClient(t,
+		deploy,
+		&corev1.ConfigMap{ObjectMeta: objectMeta("shop", "old-config", nil)},
+		&corev1.Secret{ObjectMeta: objectMeta("shop", "token", nil), Data: map[string][]byte{"token": []byte("s3cret")}},
+		&corev1.Event{ObjectMeta: objectMeta("shop", "ev", nil)},
+	)
+	store, err := db.NewDiskSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(c, WithSnapshotStore(store))
+
+	first := takeSnapshot(t, h)
+	if first.ID != 1 || first.Reason != SnapshotManual || first.Objects != 3 {
+		t.Errorf("unexpected first snapshot %+v", first)
+	}
+
+	// Change the cluster: new image, a deleted ConfigMap and a new pod
+	apps := c.Clientset.AppsV1().Deployments("shop")
+	live, _ := apps.Get(ctx, "web", metav1.GetOptions{})
+	live.Spec.Template.Spec.Containers[0].Image = "nginx:1.25"
+	if _, err := apps.Update(ctx, live, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Clientset.CoreV1().ConfigMaps("shop").Delete(ctx, "old-config", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Clientset.CoreV1().Pods("shop").Create(ctx, testPod("shop", "web-1", corev1.PodRunning, nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	second := takeSnapshot(t, h)
+
+	for _, target := range []string{"/snapshots/1/diff?to=2", "/snapshots/1/diff"} {
+		w := serve(t, h, http.MethodGet, target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		var diff api.SnapshotDiff
+		if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+			t.Fatal(err)
+		}
+		if len(diff.Created) != 1 || diff.Created[0] != (api.ObjectRef{Kind: "Pod", Namespace: "shop", Name: "web-1"}) {
+			t.Errorf("%s: created %+v", target, diff.Created)
+		}
+		if len(diff.Deleted) != 1 || diff.Deleted[0].Name != "old-config" {
+			t.Errorf("%s: deleted %+v", target, diff.Deleted)
+		}
+		if len(diff.Changed) != 1 || diff.Changed[0].Kind != "Deployment" ||
+			len(diff.Changed[0].Fields) != 1 || diff.Changed[0].Fields[0].New != "nginx:1.25" {
+			t.Errorf("%s: changed %+v", target, diff.Changed)
+		}
+	}
+	if second.ID != 2 {
+		t.Errorf("second snapshot id %d", second.ID)
+	}
+
+	w := serve(t, h, http.MethodGet, "/snapshots/1/diff?to=2&kind=configmap")
+	var filtered api.SnapshotDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &filtered); err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered.Created) != 0 || len(filtered.Changed) != 0 || len(filtered.Deleted) != 1 {
+		t.Errorf("kind filter: %+v", filtered)
+	}
+
+	w = serve(t, h, http.MethodGet, "/snapshots")
+	var list api.ListResponse[api.SnapshotInfo]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 2 || list.Items[0].ID != 2 {
+		t.Errorf("unexpected snapshot list %+v", list)
+	}
+
+	w = serve(t, h, http.MethodGet, "/snapshots/1/export")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("export: status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	files := readTarball(t, w.Body)
+	secret, ok := files["secret/shop/token.yaml"]
+	if !ok {
+		t.Fatalf("secret manifest missing from %v", files)
+	}
+	if strings.Contains(secret, "czNjcmV0") {
+		t.Error("secret value exported unredacted")
+	}
+	if !strings.Contains(files["deployment/shop/web.yaml"], "image: nginx:1.24") {
+		t.Errorf("deployment manifest: %s", files["deployment/shop/web.yaml"])
+	}
+
+	if w := serve(t, h, http.MethodGet, "/snapshots/9/export"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown snapshot: status %d", w.Code)
+	}
+	if w := serve(t, h, http.MethodGet, "/snapshots/x/diff"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status %d", w.Code)
+	}
+	if w := serve(t, NewHandler(c), http.MethodGet, "/snapshots"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without store: status %d", w.Code)
+	}
+}
+
+func takeSnapshot(t *testing.T, h *Handler) api.SnapshotInfo {
+	t.Helper()
+	w := serve(t, h, http.MethodPost, "/snapshots")
+	if w.Code != http.StatusOK {
+		t.Fatalf("create snapshot: status %d: %s", w.Code, w.Body)
+	}
+	var info api.SnapshotInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+// readTarball returns the files of a tar.gz keyed by path without the root directory
+func readTarball(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, path, _ := strings.Cut(header.Name, "/")
+		files[path] = string(content)
+	}
+}
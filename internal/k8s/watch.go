@@ -0,0 +1,110 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"kubelens/pkg/api"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ErrUnsupportedKind is returned when a kind cannot be watched
+var ErrUnsupportedKind = errors.New("unsupported kind")
+
+// Watch streams changes of one kind as the same typed items the list endpoints return.
+// The channel is closed when ctx is done or the API server ends the watch.
+func (c *Client) Watch(ctx context.Context, kind, namespace string) (<-chan api.WatchEvent, error) {
+	if k, ok := searchKinds[strings.ToLower(kind)]; ok {
+		kind = k
+	} else if strings.EqualFold(kind, "event") || strings.EqualFold(kind, "events") {
+		kind = "Event"
+	}
+
+	var w watch.Interface
+	var err error
+	opts := metav1.ListOptions{}
+	switch kind {
+	case "Pod":
+		w, err = c.Clientset.CoreV1().Pods(namespace).Watch(ctx, opts)
+	case "Deployment":
+		w, err = c.Clientset.AppsV1().Deployments(namespace).Watch(ctx, opts)
+	case "StatefulSet":
+		w, err = c.Clientset.AppsV1().StatefulSets(namespace).Watch(ctx, opts)
+	case "DaemonSet":
+		w, err = c.Clientset.AppsV1().DaemonSets(namespace).Watch(ctx, opts)
+	case "Node":
+		w, err = c.Clientset.CoreV1().Nodes().Watch(ctx, opts)
+	case "Service":
+		w, err = c.Clientset.CoreV1().Services(namespace).Watch(ctx, opts)
+	case "Event":
+		w, err = c.Clientset.CoreV1().Events(namespace).Watch(ctx, opts)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKind, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan api.WatchEvent)
+	go func() {
+		defer close(events)
+		defer w.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-w.ResultChan():
+				if !ok {
+					return
+				}
+				if ev.Type != watch.Added && ev.Type != watch.Modified && ev.Type != watch.Deleted {
+					continue
+				}
+				item := watchItem(ev.Object)
+				if item == nil {
+					continue
+				}
+				raw, err := json.Marshal(item)
+				if err != nil {
+					continue
+				}
+				select {
+				case events <- api.WatchEvent{Type: string(ev.Type), Kind: kind, Object: raw}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// watchItem converts a watched object to its list item type
+func watchItem(obj runtime.Object) interface{} {
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		return newPod(*o)
+	case *appsv1.Deployment:
+		return newWorkload(o.ObjectMeta, "Deployment", o.Status.ReadyReplicas, o.Status.Replicas)
+	case *appsv1.StatefulSet:
+		return newWorkload(o.ObjectMeta, "StatefulSet", o.Status.ReadyReplicas, o.Status.Replicas)
+	case *appsv1.DaemonSet:
+		return newWorkload(o.ObjectMeta, "DaemonSet", o.Status.NumberReady, o.Status.DesiredNumberScheduled)
+	case *corev1.Node:
+		return newNode(*o)
+	case *corev1.Service:
+		return newService(*o)
+	case *corev1.Event:
+		return newEvent(*o)
+	}
+	return nil
+}
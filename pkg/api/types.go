@@ -0,0 +1,845 @@
+// Package api defines the request and response types of the KubeLens HTTP API.
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ListResponse is the envelope of every list endpoint
+type ListResponse[T any] struct {
+	Items    []T    `json:"items"`
+	Total    int    `json:"total"`
+	Continue string `json:"continue"`
+}
+
+// ErrorResponse is returned with every non-2xx status
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// MessageResponse is returned by actions that have no other result
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// HealthResponse is returned by GET /health
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type Namespace struct {
+	Name      string    `json:"name"`
+	Age       string    `json:"age"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Workload struct {
+	Name            string    `json:"name"`
+	Namespace       string    `json:"namespace"`
+	Kind            string    `json:"kind"`
+	Ready           string    `json:"ready"`
+	ReadyReplicas   int32     `json:"readyReplicas"`
+	DesiredReplicas int32     `json:"desiredReplicas"`
+	Age             string    `json:"age"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+type Pod struct {
+	Name            string    `json:"name"`
+	Namespace       string    `json:"namespace"`
+	Status          string    `json:"status"` // as shown by kubectl, e.g. CrashLoopBackOff or Init:0/2
+	Phase           string    `json:"phase"`
+	Ready           string    `json:"ready"`
+	ReadyContainers int       `json:"readyContainers"`
+	TotalContainers int       `json:"totalContainers"`
+	Restarts        int32     `json:"restarts"`
+	Age             string    `json:"age"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Node            string    `json:"node"`
+	Containers      []string  `json:"containers"`
+}
+
+// ServicePort is a port of a service. A named target port leaves TargetPort 0
+// and sets TargetPortName; the service detail resolves it against the pods.
+type ServicePort struct {
+	Port           int32  `json:"port"`
+	TargetPort     int32  `json:"targetPort"`
+	TargetPortName string `json:"targetPortName,omitempty"`
+	NodePort       int32  `json:"nodePort"`
+	Protocol       string `json:"protocol"`
+}
+
+type Service struct {
+	Name       string        `json:"name"`
+	Namespace  string        `json:"namespace"`
+	Type       string        `json:"type"`
+	ClusterIP  string        `json:"clusterIP"`
+	ExternalIP string        `json:"externalIP"`
+	Ports      []ServicePort `json:"ports"`
+	Age        string        `json:"age"`
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+type Node struct {
+	Name             string    `json:"name"`
+	Status           string    `json:"status"`
+	Roles            string    `json:"roles"`
+	Age              string    `json:"age"`
+	CreatedAt        time.Time `json:"createdAt"`
+	Version          string    `json:"version"`
+	InternalIP       string    `json:"internalIP"`
+	OSImage          string    `json:"osImage"`
+	ContainerRuntime string    `json:"containerRuntime"`
+	KernelVersion    string    `json:"kernelVersion"`
+	Architecture     string    `json:"architecture"`
+}
+
+// NodeMetric is the current usage of a node; CPU in millicores, memory in bytes
+type NodeMetric struct {
+	Name        string    `json:"name"`
+	CPUUsage    int64     `json:"cpuUsage"`
+	MemoryUsage int64     `json:"memoryUsage"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// ContainerMetric is the current usage of a container; CPU in millicores, memory in bytes
+type ContainerMetric struct {
+	Name        string `json:"name"`
+	CPUUsage    int64  `json:"cpuUsage"`
+	MemoryUsage int64  `json:"memoryUsage"`
+}
+
+type PodMetric struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	CPUUsage    int64             `json:"cpuUsage"`
+	MemoryUsage int64             `json:"memoryUsage"`
+	Containers  []ContainerMetric `json:"containers"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+type Event struct {
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	Object    string    `json:"object"`
+	Message   string    `json:"message"`
+	Count     int32     `json:"count"`
+	Age       string    `json:"age"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Namespace string    `json:"namespace"`
+}
+
+type ConfigMap struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Age       string    `json:"age"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PersistentVolume struct {
+	Name      string    `json:"name"`
+	Phase     string    `json:"phase"`
+	Age       string    `json:"age"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PersistentVolumeClaim struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Status    string    `json:"status"`
+	Age       string    `json:"age"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Summary struct {
+	TotalPods      int `json:"totalPods"`
+	RunningPods    int `json:"runningPods"`
+	TotalNodes     int `json:"totalNodes"`
+	ReadyNodes     int `json:"readyNodes"`
+	TotalServices  int `json:"totalServices"`
+	TotalWorkloads int `json:"totalWorkloads"`
+}
+
+type Notification struct {
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Object    string    `json:"object"`
+	Namespace string    `json:"namespace"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PodLogs struct {
+	Logs string `json:"logs"`
+}
+
+// PodDetail is the describe-style view of a single pod
+type PodDetail struct {
+	Name                string            `json:"name"`
+	Namespace           string            `json:"namespace"`
+	UID                 string            `json:"uid"`
+	Status              string            `json:"status"` // as shown by kubectl
+	Phase               string            `json:"phase"`
+	Reason              string            `json:"reason"`
+	Message             string            `json:"message"`
+	Node                string            `json:"node"`
+	PodIP               string            `json:"podIP"`
+	HostIP              string            `json:"hostIP"`
+	ServiceAccount      string            `json:"serviceAccount"`
+	PriorityClassName   string            `json:"priorityClassName"`
+	QOSClass            string            `json:"qosClass"`
+	RestartPolicy       string            `json:"restartPolicy"`
+	Labels              map[string]string `json:"labels"`
+	Annotations         map[string]string `json:"annotations"`
+	NodeSelector        map[string]string `json:"nodeSelector"`
+	Tolerations         []Toleration      `json:"tolerations"`
+	Conditions          []Condition       `json:"conditions"`
+	Volumes             []Volume          `json:"volumes"`
+	InitContainers      []ContainerDetail `json:"initContainers"`
+	Containers          []ContainerDetail `json:"containers"`
+	EphemeralContainers []ContainerDetail `json:"ephemeralContainers"`
+	Owners              []OwnerReference  `json:"owners"`
+	Events              []ObjectEvent     `json:"events"`
+	Age                 string            `json:"age"`
+	CreatedAt           time.Time         `json:"createdAt"`
+	StartTime           *time.Time        `json:"startTime,omitempty"`
+	DeletionTimestamp   *time.Time        `json:"deletionTimestamp,omitempty"`
+}
+
+type ContainerDetail struct {
+	Name                string               `json:"name"`
+	Image               string               `json:"image"`
+	ImagePullPolicy     string               `json:"imagePullPolicy"`
+	Command             []string             `json:"command"`
+	Args                []string             `json:"args"`
+	Ports               []ContainerPort      `json:"ports"`
+	VolumeMounts        []VolumeMount        `json:"volumeMounts"`
+	Resources           ResourceRequirements `json:"resources"`
+	LivenessProbe       bool                 `json:"livenessProbe"`
+	ReadinessProbe      bool                 `json:"readinessProbe"`
+	StartupProbe        bool                 `json:"startupProbe"`
+	TargetContainerName string               `json:"targetContainerName,omitempty"`
+	ImageID             string               `json:"imageID,omitempty"`
+	ContainerID         string               `json:"containerID,omitempty"`
+	Ready               bool                 `json:"ready"`
+	Started             *bool                `json:"started,omitempty"`
+	RestartCount        int32                `json:"restartCount"`
+	State               *ContainerState      `json:"state,omitempty"`
+	LastState           *ContainerState      `json:"lastState,omitempty"`
+}
+
+type ContainerPort struct {
+	Name          string `json:"name"`
+	ContainerPort int32  `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath"`
+	ReadOnly  bool   `json:"readOnly"`
+}
+
+// ResourceRequirements maps resource names (cpu, memory, ...) to quantities such as "500m" or "1Gi"
+type ResourceRequirements struct {
+	Requests map[string]string `json:"requests"`
+	Limits   map[string]string `json:"limits"`
+}
+
+// ContainerState is one of Waiting, Running or Terminated
+type ContainerState struct {
+	State      string     `json:"state"`
+	Reason     string     `json:"reason,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	ExitCode   *int32     `json:"exitCode,omitempty"`
+	Signal     int32      `json:"signal,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type Condition struct {
+	Type               string     `json:"type"`
+	Status             string     `json:"status"`
+	Reason             string     `json:"reason"`
+	Message            string     `json:"message"`
+	LastTransitionTime *time.Time `json:"lastTransitionTime,omitempty"`
+}
+
+type Volume struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Source string `json:"source"`
+}
+
+type Toleration struct {
+	Key               string `json:"key"`
+	Operator          string `json:"operator"`
+	Value             string `json:"value"`
+	Effect            string `json:"effect"`
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+type OwnerReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+// ObjectEvent is an event attached to a detail view
+type ObjectEvent struct {
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count"`
+	Source   string    `json:"source"`
+	Age      string    `json:"age"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// ResourceGraph is the relationship graph of a namespace
+type ResourceGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status"`
+}
+
+// GraphEdge links two graph nodes; Type is one of owns, selects, routes, mounts, env,
+// imagePullSecret, binds or tls
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+type SearchResult struct {
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"`
+	Score     int               `json:"score"`
+	Key       string            `json:"key"`
+	Link      string            `json:"link"`
+}
+
+// WatchEvent is sent as a server-sent event by GET /watch. Object holds the
+// list item type of Kind, e.g. a Pod or a Workload.
+type WatchEvent struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED or DELETED
+	Kind   string          `json:"kind"`
+	Object json.RawMessage `json:"object"`
+}
+
+// SnapshotInfo describes a stored cluster snapshot
+type SnapshotInfo struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Reason    string    `json:"reason"` // scheduled or manual
+	Objects   int       `json:"objects"`
+	Size      int64     `json:"size"` // compressed bytes
+}
+
+// ObjectRef identifies an object in a snapshot, a timeline or a report
+type ObjectRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// FieldChange is a changed leaf field; Path looks like spec.template.spec.containers[0].image
+type FieldChange struct {
+	Path string      `json:"path"`
+	Type string      `json:"type"` // added, removed or changed
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+type ObjectDiff struct {
+	ObjectRef
+	Fields []FieldChange `json:"fields"`
+}
+
+// SnapshotDiff compares two snapshots; To has ID 0 when compared against the live cluster
+type SnapshotDiff struct {
+	From    SnapshotInfo `json:"from"`
+	To      SnapshotInfo `json:"to"`
+	Created []ObjectRef  `json:"created"`
+	Deleted []ObjectRef  `json:"deleted"`
+	Changed []ObjectDiff `json:"changed"`
+}
+
+// TimelineEntry is either a recorded spec change (Type "change") or an archived
+// Kubernetes event (Type "event") of the object in ObjectRef
+type TimelineEntry struct {
+	Time time.Time `json:"time"`
+	Age  string    `json:"age"`
+	Type string    `json:"type"`
+	ObjectRef
+
+	// Changes
+	Action     string        `json:"action,omitempty"` // created, updated or deleted
+	Manager    string        `json:"manager,omitempty"`
+	Generation int64         `json:"generation,omitempty"`
+	Fields     []FieldChange `json:"fields,omitempty"`
+
+	// Events
+	Level   string `json:"level,omitempty"` // Normal or Warning
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	Count   int32  `json:"count,omitempty"`
+}
+
+// Problem is an unhealthy condition of a pod or one of its containers, with
+// the evidence it was derived from and suggested next steps
+type Problem struct {
+	Namespace    string        `json:"namespace"`
+	Pod          string        `json:"pod"`
+	Container    string        `json:"container,omitempty"`
+	Type         string        `json:"type"`     // CrashLoopBackOff, OOMKilled, ContainerFailed, ImagePullBackOff, ...
+	Severity     string        `json:"severity"` // critical or warning
+	Summary      string        `json:"summary"`
+	ExitCode     *int32        `json:"exitCode,omitempty"`
+	Signal       int32         `json:"signal,omitempty"`
+	RestartCount int32         `json:"restartCount,omitempty"`
+	Message      string        `json:"message,omitempty"`
+	PreviousLogs []string      `json:"previousLogs,omitempty"` // last lines of the crashed run of the container
+	Events       []ObjectEvent `json:"events,omitempty"`
+	NextSteps    []string      `json:"nextSteps"`
+}
+
+// PodDiagnosis is the result of diagnosing a single pod
+type PodDiagnosis struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Phase     string    `json:"phase"`
+	Healthy   bool      `json:"healthy"`
+	Problems  []Problem `json:"problems"`
+}
+
+// SchedulingExplanation evaluates every node for a pending pod
+type SchedulingExplanation struct {
+	Namespace        string            `json:"namespace"`
+	Name             string            `json:"name"`
+	Requests         map[string]string `json:"requests"`
+	SchedulerMessage string            `json:"schedulerMessage,omitempty"` // latest FailedScheduling event
+	FittingNodes     int               `json:"fittingNodes"`
+	Nodes            []NodeFit         `json:"nodes"`
+}
+
+// NodeFit is one row of the scheduling table: whether the pod fits a node and why not
+type NodeFit struct {
+	Node   string            `json:"node"`
+	Fits   bool              `json:"fits"`
+	Checks []SchedulingCheck `json:"checks"`
+}
+
+// SchedulingCheck is the outcome of one scheduling predicate on a node
+type SchedulingCheck struct {
+	Name   string `json:"name"` // Unschedulable, Resources, NodeSelector, NodeAffinity, PodAffinity, Taints or Volumes
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// NodeDetail is the describe-style view of a single node
+type NodeDetail struct {
+	Node
+	Unschedulable    bool              `json:"unschedulable"`
+	PodCIDR          string            `json:"podCIDR,omitempty"`
+	Labels           map[string]string `json:"labels"`
+	Annotations      map[string]string `json:"annotations"`
+	Taints           []Taint           `json:"taints"`
+	Conditions       []Condition       `json:"conditions"`
+	Addresses        []NodeAddress     `json:"addresses"`
+	Resources        []NodeResource    `json:"resources"`
+	MetricsAvailable bool              `json:"metricsAvailable"`
+	Images           []ContainerImage  `json:"images"`
+	Pods             []NodePod         `json:"pods"`
+}
+
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+type NodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+// NodeResource compares one resource of a node; percentages are of allocatable
+type NodeResource struct {
+	Name            string `json:"name"` // cpu, memory, pods, ephemeral-storage, ...
+	Capacity        string `json:"capacity"`
+	Allocatable     string `json:"allocatable"`
+	Requests        string `json:"requests"`
+	RequestsPercent int    `json:"requestsPercent"`
+	Limits          string `json:"limits"`
+	LimitsPercent   int    `json:"limitsPercent"`
+	Usage           string `json:"usage,omitempty"` // from the metrics server, cpu and memory only
+	UsagePercent    int    `json:"usagePercent,omitempty"`
+}
+
+// ContainerImage is an image cached on a node
+type ContainerImage struct {
+	Names     []string `json:"names"`
+	SizeBytes int64    `json:"sizeBytes"`
+}
+
+// NodePod is a pod running on a node with what it requests; usage in millicores and bytes
+type NodePod struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Status      string            `json:"status"`
+	Requests    map[string]string `json:"requests"`
+	Limits      map[string]string `json:"limits"`
+	CPUUsage    int64             `json:"cpuUsage,omitempty"`
+	MemoryUsage int64             `json:"memoryUsage,omitempty"`
+	Age         string            `json:"age"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// NamespaceDetail aggregates what runs in a namespace and how close it is to its quota
+type NamespaceDetail struct {
+	Namespace
+	Status           string              `json:"status"` // Active or Terminating
+	Labels           map[string]string   `json:"labels"`
+	Annotations      map[string]string   `json:"annotations"`
+	Pods             int                 `json:"pods"`
+	PodsByStatus     map[string]int      `json:"podsByStatus"` // keyed by the status kubectl get pods shows
+	Workloads        map[string]int      `json:"workloads"`    // keyed by kind
+	Resources        []NamespaceResource `json:"resources"`
+	MetricsAvailable bool                `json:"metricsAvailable"`
+	Quotas           []ResourceQuota     `json:"quotas"`
+	LimitRanges      []LimitRange        `json:"limitRanges"`
+}
+
+// NamespaceResource sums the requests, limits and usage of the active pods for
+// one resource and compares them with the tightest quota; percentages are of the quota
+type NamespaceResource struct {
+	Name            string `json:"name"` // cpu or memory
+	Requests        string `json:"requests"`
+	RequestsQuota   string `json:"requestsQuota,omitempty"`
+	RequestsPercent int    `json:"requestsPercent,omitempty"`
+	Limits          string `json:"limits"`
+	LimitsQuota     string `json:"limitsQuota,omitempty"`
+	LimitsPercent   int    `json:"limitsPercent,omitempty"`
+	Usage           string `json:"usage,omitempty"` // from the metrics server
+}
+
+type ResourceQuota struct {
+	Name   string      `json:"name"`
+	Scopes []string    `json:"scopes,omitempty"`
+	Items  []QuotaItem `json:"items"`
+}
+
+// QuotaItem is one hard limit of a ResourceQuota and how much of it is used
+type QuotaItem struct {
+	Resource string `json:"resource"` // e.g. requests.cpu, limits.memory, pods
+	Hard     string `json:"hard"`
+	Used     string `json:"used"`
+	Percent  int    `json:"percent"`
+}
+
+type LimitRange struct {
+	Name   string           `json:"name"`
+	Limits []LimitRangeItem `json:"limits"`
+}
+
+// LimitRangeItem is the constraint of a LimitRange on one resource of a Container, Pod or PersistentVolumeClaim
+type LimitRangeItem struct {
+	Type                 string `json:"type"`
+	Resource             string `json:"resource"`
+	Min                  string `json:"min,omitempty"`
+	Max                  string `json:"max,omitempty"`
+	Default              string `json:"default,omitempty"`
+	DefaultRequest       string `json:"defaultRequest,omitempty"`
+	MaxLimitRequestRatio string `json:"maxLimitRequestRatio,omitempty"`
+}
+
+// NamespaceCreateRequest is the body of POST /namespaces
+type NamespaceCreateRequest struct {
+	Name         string            `json:"name"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Template     string            `json:"template,omitempty"` // name of a NamespaceTemplate for the default quota and limit range
+	RoleBindings []RoleBinding     `json:"roleBindings,omitempty"`
+}
+
+// RoleBinding grants a ClusterRole, e.g. edit or view, inside the new namespace
+type RoleBinding struct {
+	Name        string    `json:"name,omitempty"` // defaults to the role name
+	ClusterRole string    `json:"clusterRole"`
+	Subjects    []Subject `json:"subjects"`
+}
+
+type Subject struct {
+	Kind      string `json:"kind"` // User, Group or ServiceAccount
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"` // of a ServiceAccount, defaults to the new namespace
+}
+
+// NamespaceTemplate is a default ResourceQuota and LimitRange applied to new namespaces
+type NamespaceTemplate struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Quota       map[string]string `json:"quota,omitempty"` // hard limits, e.g. requests.cpu: "4"
+	LimitRange  []LimitRangeItem  `json:"limitRange,omitempty"`
+}
+
+// NamespaceCreateResult lists the objects created with a namespace
+type NamespaceCreateResult struct {
+	Namespace Namespace   `json:"namespace"`
+	Created   []ObjectRef `json:"created"`
+}
+
+// NamespaceContents is what deleting a namespace would remove
+type NamespaceContents struct {
+	Namespace string         `json:"namespace"`
+	Protected bool           `json:"protected"`
+	Total     int            `json:"total"`
+	Counts    map[string]int `json:"counts"` // by kind
+	Objects   []ObjectRef    `json:"objects"`
+}
+
+// UsageSample is the usage of one container at one point in time, in millicores and bytes
+type UsageSample struct {
+	Time         time.Time `json:"time"`
+	Namespace    string    `json:"namespace"`
+	Pod          string    `json:"pod"`
+	Container    string    `json:"container"`
+	WorkloadKind string    `json:"workloadKind"` // Deployment, StatefulSet, ... or Pod for bare pods
+	Workload     string    `json:"workload"`
+	CPUUsage     int64     `json:"cpuUsage"`
+	MemoryUsage  int64     `json:"memoryUsage"`
+}
+
+// Recommendation suggests requests and limits for one container of a workload
+// from its usage over the window
+type Recommendation struct {
+	Namespace     string                 `json:"namespace"`
+	WorkloadKind  string                 `json:"workloadKind"`
+	Workload      string                 `json:"workload"`
+	Container     string                 `json:"container"`
+	Replicas      int                    `json:"replicas"`
+	Samples       int                    `json:"samples"`       // sampling rounds, however many replicas each covered
+	Status        string                 `json:"status"`        // OverProvisioned, UnderProvisioned, RightSized or InsufficientData
+	CPU           ResourceRecommendation `json:"cpu"`           // millicores
+	Memory        ResourceRecommendation `json:"memory"`        // bytes
+	CPUSavings    int64                  `json:"cpuSavings"`    // millicores freed across replicas, negative when more is needed
+	MemorySavings int64                  `json:"memorySavings"` // bytes freed across replicas, negative when more is needed
+}
+
+// ResourceRecommendation compares the usage percentiles of one resource with
+// the current and suggested request and limit; 0 means unset
+type ResourceRecommendation struct {
+	P50              int64  `json:"p50"`
+	P90              int64  `json:"p90"`
+	P95              int64  `json:"p95"`
+	P99              int64  `json:"p99"`
+	Max              int64  `json:"max"`
+	Request          int64  `json:"request"`
+	Limit            int64  `json:"limit"`
+	SuggestedRequest int64  `json:"suggestedRequest"`
+	SuggestedLimit   int64  `json:"suggestedLimit"`
+	Status           string `json:"status"`
+}
+
+// CostModel prices CPU and memory; tiers override the prices of the nodes they select
+type CostModel struct {
+	Currency       string      `json:"currency"`
+	CPUHourly      float64     `json:"cpuHourly"`      // per core-hour
+	MemoryGBHourly float64     `json:"memoryGBHourly"` // per GiB-hour
+	Tiers          []PriceTier `json:"tiers,omitempty"`
+}
+
+// PriceTier prices the nodes whose labels match NodeSelector, e.g. spot or GPU
+// nodes. A NodeHourly price is split between CPU and memory in the ratio of
+// the CPU and memory prices; zero prices fall back to the model's.
+type PriceTier struct {
+	Name           string            `json:"name"`
+	NodeSelector   map[string]string `json:"nodeSelector"`
+	NodeHourly     float64           `json:"nodeHourly,omitempty"`
+	CPUHourly      float64           `json:"cpuHourly,omitempty"`
+	MemoryGBHourly float64           `json:"memoryGBHourly,omitempty"`
+}
+
+// WorkloadCost is the cost of one workload, per hour when live or for a whole day when recorded
+type WorkloadCost struct {
+	Date          string            `json:"date,omitempty"` // YYYY-MM-DD of recorded costs
+	Namespace     string            `json:"namespace"`
+	WorkloadKind  string            `json:"workloadKind"`
+	Workload      string            `json:"workload"`
+	Labels        map[string]string `json:"labels,omitempty"` // of the workload's pods
+	Pods          int               `json:"pods"`
+	CPUCoreHours  float64           `json:"cpuCoreHours"`
+	MemoryGBHours float64           `json:"memoryGBHours"`
+	CPUCost       float64           `json:"cpuCost"`
+	MemoryCost    float64           `json:"memoryCost"`
+}
+
+// CostAllocation is the cost of a namespace, workload or label value
+type CostAllocation struct {
+	Group         string  `json:"group"`
+	Date          string  `json:"date,omitempty"` // YYYY-MM-DD of daily costs
+	Pods          int     `json:"pods"`
+	CPUCoreHours  float64 `json:"cpuCoreHours"`
+	MemoryGBHours float64 `json:"memoryGBHours"`
+	CPUCost       float64 `json:"cpuCost"`
+	MemoryCost    float64 `json:"memoryCost"`
+	TotalCost     float64 `json:"totalCost"`
+	MonthlyCost   float64 `json:"monthlyCost,omitempty"` // live hourly cost projected over 730 hours
+}
+
+// ScanReport is the result of auditing workloads against the best-practice rules
+type ScanReport struct {
+	Score     int            `json:"score"` // 0-100, the average of the workload scores
+	Workloads int            `json:"workloads"`
+	Findings  map[string]int `json:"findings"` // by severity
+	Rules     []ScanRule     `json:"rules"`
+	Results   []WorkloadScan `json:"results"`
+}
+
+type ScanRule struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+}
+
+// WorkloadScan lists the findings of one workload; Skipped are the rules its annotation turns off
+type WorkloadScan struct {
+	Namespace string        `json:"namespace"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Score     int           `json:"score"`
+	Findings  []ScanFinding `json:"findings"`
+	Skipped   []string      `json:"skipped,omitempty"`
+}
+
+type ScanFinding struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	Container string `json:"container,omitempty"`
+	Message   string `json:"message"`
+}
+
+// DeprecationReport lists the APIs removed by TargetVersion that the cluster
+// still serves and the objects last written through them
+type DeprecationReport struct {
+	ServerVersion string            `json:"serverVersion"`
+	TargetVersion string            `json:"targetVersion"`
+	ServedAPIs    []DeprecatedAPI   `json:"servedAPIs"`
+	Usages        []DeprecatedUsage `json:"usages"`
+}
+
+// DeprecatedAPI is an API version of a kind and the release that removes it
+type DeprecatedAPI struct {
+	APIVersion   string `json:"apiVersion"`
+	Kind         string `json:"kind"`
+	DeprecatedIn string `json:"deprecatedIn"`
+	RemovedIn    string `json:"removedIn"`
+	Replacement  string `json:"replacement,omitempty"` // empty when the API is removed without one
+	Note         string `json:"note,omitempty"`
+}
+
+// DeprecatedUsage is an object applied or updated through a removed API. Source
+// is last-applied for the kubectl apply annotation or managedFields, where
+// Manager names the client that wrote it.
+type DeprecatedUsage struct {
+	DeprecatedAPI
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Source    string `json:"source"`
+	Manager   string `json:"manager,omitempty"`
+}
+
+// OrphanedResource is an object nothing appears to use
+type OrphanedResource struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	Age       string    `json:"age"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OrphanDeleteRequest lists the orphaned objects to delete
+type OrphanDeleteRequest struct {
+	Items []ObjectRef `json:"items"`
+}
+
+// OrphanDeleteResult reports what a bulk deletion did, or would do on a dry run
+type OrphanDeleteResult struct {
+	DryRun  bool          `json:"dryRun"`
+	Deleted []ObjectRef   `json:"deleted"`
+	Failed  []DeleteError `json:"failed"`
+}
+
+type DeleteError struct {
+	ObjectRef
+	Error string `json:"error"`
+}
+
+// AuditEntry records a change made through KubeLens
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	DryRun    bool      `json:"dryRun"`
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ServiceDetail is a service with its selected pods, resolved ports and endpoints
+type ServiceDetail struct {
+	Service
+	Selector       map[string]string    `json:"selector"`
+	Pods           []string             `json:"pods"` // pods matching the selector
+	PortMappings   []ServicePortMapping `json:"portMappings"`
+	Endpoints      []ServiceEndpoint    `json:"endpoints"`
+	ReadyEndpoints int                  `json:"readyEndpoints"`
+	Warnings       []string             `json:"warnings"`
+}
+
+// ServicePortMapping resolves a service port's target port, which may name a
+// container port, to the port numbers of the selected pods. Unresolved lists
+// the pods that declare no container port of that name.
+type ServicePortMapping struct {
+	Name          string   `json:"name,omitempty"`
+	Port          int32    `json:"port"`
+	TargetPort    string   `json:"targetPort"`
+	Protocol      string   `json:"protocol"`
+	ResolvedPorts []int32  `json:"resolvedPorts"`
+	Unresolved    []string `json:"unresolved,omitempty"`
+}
+
+// ServiceEndpoint is an endpoint of one of the service's EndpointSlices
+type ServiceEndpoint struct {
+	Slice       string   `json:"slice"`
+	Addresses   []string `json:"addresses"`
+	Ready       bool     `json:"ready"`
+	Serving     bool     `json:"serving"`
+	Terminating bool     `json:"terminating"`
+	Pod         string   `json:"pod,omitempty"`
+	Node        string   `json:"node,omitempty"`
+	Zone        string   `json:"zone,omitempty"`
+}
+
+// CopyResult lists the files uploaded into a container directory
+type CopyResult struct {
+	Container string   `json:"container"`
+	Path      string   `json:"path"`
+	Files     []string `json:"files"`
+	Bytes     int64    `json:"bytes"`
+}
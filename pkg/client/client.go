@@ -0,0 +1,335 @@
+// Package client is a Go client for the KubeLens HTTP API.
+//
+//	c, err := client.New("http://kubelens:8082", client.WithToken(token))
+//	pods, err := c.ListPods(ctx, &client.ListOptions{Namespace: "shop"})
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"kubelens/pkg/api"
+)
+
+// APIPrefix is the versioned API prefix the client talks to
+const APIPrefix = "/api/v1"
+
+// Client calls a KubeLens server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	userAgent  string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken sends the token as a bearer token with every request
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient replaces http.DefaultClient, e.g. to set timeouts or TLS options
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New returns a client for the server at serverURL, e.g. http://localhost:8082
+func New(serverURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(serverURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid server url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server url %q: scheme must be http or https", serverURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		userAgent:  "kubelens-go-client",
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// APIError is returned for every non-2xx response
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("kubelens: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is an APIError with status 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// ListOptions are the pagination, sorting and filtering parameters of list calls.
+// Namespace is ignored by cluster-scoped kinds.
+type ListOptions struct {
+	Namespace     string
+	Limit         int
+	Continue      string
+	Sort          string
+	LabelSelector string
+	FieldSelector string
+	Status        string
+}
+
+func (o *ListOptions) values() url.Values {
+	v := url.Values{}
+	if o == nil {
+		return v
+	}
+	setIf(v, "namespace", o.Namespace)
+	setIf(v, "continue", o.Continue)
+	setIf(v, "sort", o.Sort)
+	setIf(v, "labelSelector", o.LabelSelector)
+	setIf(v, "fieldSelector", o.FieldSelector)
+	setIf(v, "status", o.Status)
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return v
+}
+
+// LogOptions selects the log lines of GetPodLogs and StreamPodLogs
+type LogOptions struct {
+	Container string
+	TailLines int64
+}
+
+func (o *LogOptions) values() url.Values {
+	v := url.Values{}
+	if o == nil {
+		return v
+	}
+	setIf(v, "container", o.Container)
+	if o.TailLines > 0 {
+		v.Set("tail", strconv.FormatInt(o.TailLines, 10))
+	}
+	return v
+}
+
+func setIf(v url.Values, key, value string) {
+	if value != "" {
+		v.Set(key, value)
+	}
+}
+
+// Health checks that the server is up
+func (c *Client) Health(ctx context.Context) (*api.HealthResponse, error) {
+	return get[api.HealthResponse](ctx, c, "/health", nil)
+}
+
+func (c *Client) ListNamespaces(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.Namespace], error) {
+	return get[api.ListResponse[api.Namespace]](ctx, c, "/namespaces", opts.values())
+}
+
+func (c *Client) ListWorkloads(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.Workload], error) {
+	return get[api.ListResponse[api.Workload]](ctx, c, "/workloads", opts.values())
+}
+
+func (c *Client) ListPods(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.Pod], error) {
+	return get[api.ListResponse[api.Pod]](ctx, c, "/pods", opts.values())
+}
+
+func (c *Client) ListNodes(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.Node], error) {
+	return get[api.ListResponse[api.Node]](ctx, c, "/nodes", opts.values())
+}
+
+func (c *Client) ListEvents(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.Event], error) {
+	return get[api.ListResponse[api.Event]](ctx, c, "/events", opts.values())
+}
+
+func (c *Client) ListNodeMetrics(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.NodeMetric], error) {
+	return get[api.ListResponse[api.NodeMetric]](ctx, c, "/metrics/nodes", opts.values())
+}
+
+func (c *Client) ListPodMetrics(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.PodMetric], error) {
+	return get[api.ListResponse[api.PodMetric]](ctx, c, "/metrics/pods", opts.values())
+}
+
+func (c *Client) ListServices(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.Service], error) {
+	return get[api.ListResponse[api.Service]](ctx, c, "/services", opts.values())
+}
+
+func (c *Client) ListConfigMaps(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.ConfigMap], error) {
+	return get[api.ListResponse[api.ConfigMap]](ctx, c, "/configmaps", opts.values())
+}
+
+func (c *Client) ListPersistentVolumes(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.PersistentVolume], error) {
+	return get[api.ListResponse[api.PersistentVolume]](ctx, c, "/pvs", opts.values())
+}
+
+func (c *Client) ListPersistentVolumeClaims(ctx context.Context, opts *ListOptions) (*api.ListResponse[api.PersistentVolumeClaim], error) {
+	return get[api.ListResponse[api.PersistentVolumeClaim]](ctx, c, "/pvcs", opts.values())
+}
+
+func (c *Client) ListNotifications(ctx context.Context) (*api.ListResponse[api.Notification], error) {
+	return get[api.ListResponse[api.Notification]](ctx, c, "/notifications", nil)
+}
+
+func (c *Client) GetSummary(ctx context.Context) (*api.Summary, error) {
+	return get[api.Summary](ctx, c, "/summary", nil)
+}
+
+// GetResourceGraph returns the relationship graph of a namespace, all namespaces if empty
+func (c *Client) GetResourceGraph(ctx context.Context, namespace string) (*api.ResourceGraph, error) {
+	v := url.Values{}
+	setIf(v, "namespace", namespace)
+	return get[api.ResourceGraph](ctx, c, "/graph", v)
+}
+
+// Search runs a cross-kind search such as `kind:pod ns:shop payment`
+func (c *Client) Search(ctx context.Context, query string, limit int) (*api.ListResponse[api.SearchResult], error) {
+	v := url.Values{"q": {query}}
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+	return get[api.ListResponse[api.SearchResult]](ctx, c, "/search", v)
+}
+
+// GetPod describes a single pod
+func (c *Client) GetPod(ctx context.Context, namespace, name string) (*api.PodDetail, error) {
+	return get[api.PodDetail](ctx, c, podPath(namespace, name), nil)
+}
+
+// GetPodLogs returns the last lines of a pod's logs
+func (c *Client) GetPodLogs(ctx context.Context, namespace, name string, opts *LogOptions) (string, error) {
+	var out api.PodLogs
+	if err := c.get(ctx, podPath(namespace, name)+"/logs", opts.values(), &out); err != nil {
+		return "", err
+	}
+	return out.Logs, nil
+}
+
+// StreamPodLogs follows a pod's logs until ctx is cancelled or the container exits.
+// The caller must close the returned reader.
+func (c *Client) StreamPodLogs(ctx context.Context, namespace, name string, opts *LogOptions) (io.ReadCloser, error) {
+	v := opts.values()
+	v.Set("follow", "true")
+	resp, err := c.do(ctx, http.MethodGet, podPath(namespace, name)+"/logs", v, "text/plain")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// RestartWorkload triggers a rolling restart of a Deployment, StatefulSet or DaemonSet
+func (c *Client) RestartWorkload(ctx context.Context, namespace, name, kind string) (*api.MessageResponse, error) {
+	var out api.MessageResponse
+	p := "/workloads/" + namespace + "/" + name + "/" + kind + "/restart"
+	if err := c.send(ctx, http.MethodPost, p, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func podPath(namespace, name string) string {
+	return "/pods/" + namespace + "/" + name
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return c.send(ctx, http.MethodGet, path, query, nil, out)
+}
+
+// get performs a GET request and decodes the response into a new T
+func get[T any](ctx context.Context, c *Client, path string, query url.Values) (*T, error) {
+	var out T
+	if err := c.get(ctx, path, query, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// send performs a JSON request and decodes the JSON response into out
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.doBody(ctx, method, path, query, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, accept string) (*http.Response, error) {
+	return c.doBody(ctx, method, path, query, nil, accept)
+}
+
+// doBody sends the request and turns non-2xx responses into an APIError
+func (c *Client) doBody(ctx context.Context, method, path string, query url.Values, body interface{}, accept string) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + APIPrefix + path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encode request body: %w", err)
+		}
+		reader = strings.NewReader(string(raw))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+func decodeError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body api.ErrorResponse
+	if err := json.Unmarshal(raw, &body); err == nil && body.Error != "" {
+		return &APIError{StatusCode: resp.StatusCode, Message: body.Error}
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+}
@@ -0,0 +1,290 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kubelens/pkg/api"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestNewRejectsInvalidURL(t *testing.T) {
+	for _, u := range []string{"localhost:8082", "ftp://host", "://"} {
+		if _, err := New(u); err == nil {
+			t.Errorf("New(%q) succeeded, want error", u)
+		}
+	}
+}
+
+func TestListPodsSendsQueryAndToken(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		want := map[string]string{
+			"namespace":     "shop",
+			"limit":         "10",
+			"continue":      "abc",
+			"sort":          "-restarts,name",
+			"labelSelector": "app=web",
+			"status":        "Running",
+		}
+		for k, v := range want {
+			if got := r.URL.Query().Get(k); got != v {
+				t.Errorf("query %s = %q, want %q", k, got, v)
+			}
+		}
+		if r.URL.Query().Has("fieldSelector") {
+			t.Error("empty fieldSelector should not be sent")
+		}
+		writeJSON(w, http.StatusOK, api.ListResponse[api.Pod]{
+			Items: []api.Pod{{Name: "web-1", Namespace: "shop", Status: "Running"}},
+			Total: 1,
+		})
+	}, WithToken("secret"))
+
+	pods, err := c.ListPods(context.Background(), &ListOptions{
+		Namespace:     "shop",
+		Limit:         10,
+		Continue:      "abc",
+		Sort:          "-restarts,name",
+		LabelSelector: "app=web",
+		Status:        "Running",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods.Total != 1 || len(pods.Items) != 1 || pods.Items[0].Name != "web-1" {
+		t.Errorf("unexpected response %+v", pods)
+	}
+}
+
+func TestListRoutes(t *testing.T) {
+	ctx := context.Background()
+	calls := []struct {
+		path string
+		call func(*Client) error
+	}{
+		{"/api/v1/namespaces", func(c *Client) error { _, err := c.ListNamespaces(ctx, nil); return err }},
+		{"/api/v1/workloads", func(c *Client) error { _, err := c.ListWorkloads(ctx, nil); return err }},
+		{"/api/v1/nodes", func(c *Client) error { _, err := c.ListNodes(ctx, nil); return err }},
+		{"/api/v1/events", func(c *Client) error { _, err := c.ListEvents(ctx, nil); return err }},
+		{"/api/v1/metrics/nodes", func(c *Client) error { _, err := c.ListNodeMetrics(ctx, nil); return err }},
+		{"/api/v1/metrics/pods", func(c *Client) error { _, err := c.ListPodMetrics(ctx, nil); return err }},
+		{"/api/v1/services", func(c *Client) error { _, err := c.ListServices(ctx, nil); return err }},
+		{"/api/v1/configmaps", func(c *Client) error { _, err := c.ListConfigMaps(ctx, nil); return err }},
+		{"/api/v1/pvs", func(c *Client) error { _, err := c.ListPersistentVolumes(ctx, nil); return err }},
+		{"/api/v1/pvcs", func(c *Client) error { _, err := c.ListPersistentVolumeClaims(ctx, nil); return err }},
+		{"/api/v1/notifications", func(c *Client) error { _, err := c.ListNotifications(ctx); return err }},
+		{"/api/v1/summary", func(c *Client) error { _, err := c.GetSummary(ctx); return err }},
+		{"/api/v1/graph", func(c *Client) error { _, err := c.GetResourceGraph(ctx, "shop"); return err }},
+		{"/api/v1/search", func(c *Client) error { _, err := c.Search(ctx, "kind:pod web", 5); return err }},
+		{"/api/v1/pods/shop/web-1", func(c *Client) error { _, err := c.GetPod(ctx, "shop", "web-1"); return err }},
+		{"/api/v1/health", func(c *Client) error { _, err := c.Health(ctx); return err }},
+	}
+	for _, tc := range calls {
+		t.Run(tc.path, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != tc.path {
+					t.Errorf("got %s %s, want GET %s", r.Method, r.URL.Path, tc.path)
+				}
+				writeJSON(w, http.StatusOK, map[string]interface{}{})
+			})
+			if err := tc.call(c); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, api.ErrorResponse{Error: `pods "web-1" not found`})
+	})
+
+	pod, err := c.GetPod(context.Background(), "shop", "web-1")
+	if pod != nil {
+		t.Errorf("expected nil pod, got %+v", pod)
+	}
+	if !IsNotFound(err) {
+		t.Fatalf("IsNotFound(%v) = false", err)
+	}
+	apiErr := err.(*APIError)
+	if apiErr.Message != `pods "web-1" not found` {
+		t.Errorf("Message = %q", apiErr.Message)
+	}
+}
+
+func TestAPIErrorPlainBody(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	_, err := c.GetSummary(context.Background())
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadGateway || apiErr.Message != "bad gateway" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	if IsNotFound(err) {
+		t.Error("IsNotFound should be false for 502")
+	}
+}
+
+func TestGetPodLogs(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods/shop/web-1/logs" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("tail"); got != "20" {
+			t.Errorf("tail = %q", got)
+		}
+		if r.URL.Query().Has("follow") {
+			t.Error("follow should not be set")
+		}
+		writeJSON(w, http.StatusOK, api.PodLogs{Logs: "line 1\nline 2\n"})
+	})
+
+	logs, err := c.GetPodLogs(context.Background(), "shop", "web-1", &LogOptions{TailLines: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs != "line 1\nline 2\n" {
+		t.Errorf("logs = %q", logs)
+	}
+}
+
+func TestStreamPodLogs(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("follow") != "true" || q.Get("container") != "app" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "line %d\n", i)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	body, err := c.StreamPodLogs(context.Background(), "shop", "web-1", &LogOptions{Container: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "line 1\nline 2\nline 3\n" {
+		t.Errorf("logs = %q", raw)
+	}
+}
+
+func TestRestartWorkload(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/workloads/shop/web/Deployment/restart" {
+			t.Errorf("got %s %s", r.Method, r.URL.Path)
+		}
+		writeJSON(w, http.StatusOK, api.MessageResponse{Message: "ok"})
+	})
+
+	resp, err := c.RestartWorkload(context.Background(), "shop", "web", "Deployment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "ok" {
+		t.Errorf("message = %q", resp.Message)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/watch" || r.URL.Query().Get("kind") != "pods" {
+			t.Errorf("got %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		if got := r.Header.Get("Accept"); got != "text/event-stream" {
+			t.Errorf("Accept = %q", got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, name := range []string{"web-1", "web-2"} {
+			pod, _ := json.Marshal(api.Pod{Name: name, Namespace: "shop"})
+			event, _ := json.Marshal(api.WatchEvent{Type: "ADDED", Kind: "Pod", Object: pod})
+			fmt.Fprintf(w, "event:ADDED\ndata:%s\n\n", event)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watcher, err := c.Watch(ctx, "pods", "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	var names []string
+	for event := range watcher.Events() {
+		if event.Type != "ADDED" || event.Kind != "Pod" {
+			t.Errorf("unexpected event %+v", event)
+		}
+		pod, err := Decode[api.Pod](event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, pod.Name)
+	}
+	if err := watcher.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "web-1" || names[1] != "web-2" {
+		t.Errorf("names = %v", names)
+	}
+}
+
+func TestWatchStop(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	watcher, err := c.Watch(context.Background(), "nodes", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Stop()
+	if _, open := <-watcher.Events(); open {
+		t.Error("events channel still open after Stop")
+	}
+	if err := watcher.Err(); err != nil {
+		t.Errorf("Err after Stop = %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"kubelens/pkg/api"
+)
+
+// Watcher delivers the events of a Watch call
+type Watcher struct {
+	events chan api.WatchEvent
+	err    error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Events returns the event channel; it is closed when the watch ends
+func (w *Watcher) Events() <-chan api.WatchEvent { return w.events }
+
+// Err returns the error that ended the watch, if any, once Events is closed
+func (w *Watcher) Err() error {
+	<-w.done
+	return w.err
+}
+
+// Stop ends the watch
+func (w *Watcher) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+// Watch streams changes of a kind (pods, deployments, statefulsets, daemonsets,
+// nodes, services or events) in a namespace, all namespaces if empty
+func (c *Client) Watch(ctx context.Context, kind, namespace string) (*Watcher, error) {
+	v := url.Values{"kind": {kind}}
+	setIf(v, "namespace", namespace)
+
+	ctx, cancel := context.WithCancel(ctx)
+	resp, err := c.do(ctx, http.MethodGet, "/watch", v, "text/event-stream")
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	w := &Watcher{
+		events: make(chan api.WatchEvent),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+		defer close(w.events)
+		defer resp.Body.Close()
+		w.err = readEvents(ctx, bufio.NewScanner(resp.Body), w.events)
+	}()
+	return w, nil
+}
+
+// readEvents parses a server-sent event stream whose data lines are WatchEvents
+func readEvents(ctx context.Context, scanner *bufio.Scanner, events chan<- api.WatchEvent) error {
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+			var event api.WatchEvent
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return fmt.Errorf("decode watch event: %w", err)
+			}
+			data.Reset()
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return nil
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+// Decode unmarshals the object of a watch event into the list item type of its kind
+func Decode[T any](event api.WatchEvent) (T, error) {
+	var out T
+	err := json.Unmarshal(event.Object, &out)
+	return out, err
+}